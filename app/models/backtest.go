@@ -1,118 +1,295 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 
 	"github.com/oarkflow/nepse/app/models/indicator"
+	"github.com/oarkflow/nepse/config"
+	"github.com/oarkflow/nepse/fees"
+	"github.com/oarkflow/nepse/stock"
+	"github.com/oarkflow/nepse/techan"
 )
 
-// BackTestParam recieves some parameters used for backtest at json
+// DefaultShares are the shares of the trades of a backtest not giving them, the minimum lot of NEPSE
+const DefaultShares = 10
+
+// BackTestParam recieves some parameters used for backtest at json,
+// Period is the number of trading sessions backtested.
+// As json the ranges of each strategy are given under its name, like
+// {"symbol": "NABIL", "period": 500, "ema": {"short_low": 5, "short_high": 15, "long_low": 15, "long_high": 30}}
 type BackTestParam struct {
-	Symbol string                        `json:"symbol"`
-	Period int                           `json:"period"`
-	Ema    *indicator.EmaBacktestParam   `json:"ema"`
-	BB     *indicator.BBBacktestParam    `json:"bb"`
-	Macd   *indicator.MacdBacktestParam  `json:"macd"`
-	Rsi    *indicator.RsiBacktestParam   `json:"rsi"`
-	Willr  *indicator.WillrBacktestParam `json:"willr"`
+	Symbol string
+	Period int
+	// Shares are the shares bought by every trade, DefaultShares when zero
+	Shares float64
+	// Fees are the fees and tax of the trades, those of the [fees] section of config.ini when nil
+	Fees *fees.Schedule
+	// Capital is the cash the equity curve of the metrics starts at, the price of Shares at the highest close when zero
+	Capital float64
+	// Search is how the params of every strategy are searched, the whole grid of the ranges by default
+	Search indicator.Search
+	// Ranges are the ranges of the params searched for each strategy by name,
+	// the strategies left out are not backtested
+	Ranges map[string]indicator.Ranges
 }
 
-// BackTest excecutes backtest
+func (bt BackTestParam) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{"symbol": bt.Symbol, "period": bt.Period}
+	if bt.Shares != 0 {
+		fields["shares"] = bt.Shares
+	}
+	if bt.Fees != nil {
+		fields["fees"] = bt.Fees
+	}
+	if bt.Capital != 0 {
+		fields["capital"] = bt.Capital
+	}
+	if bt.Search != (indicator.Search{}) {
+		fields["search"] = bt.Search
+	}
+	for name, ranges := range bt.Ranges {
+		fields[name] = ranges
+	}
+	return json.Marshal(fields)
+}
+
+func (bt *BackTestParam) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	parsed := BackTestParam{Ranges: make(map[string]indicator.Ranges)}
+	for key, value := range fields {
+		var err error
+		switch key {
+		case "symbol":
+			err = json.Unmarshal(value, &parsed.Symbol)
+		case "period":
+			err = json.Unmarshal(value, &parsed.Period)
+		case "shares":
+			err = json.Unmarshal(value, &parsed.Shares)
+		case "fees":
+			err = json.Unmarshal(value, &parsed.Fees)
+		case "capital":
+			err = json.Unmarshal(value, &parsed.Capital)
+		case "search":
+			err = json.Unmarshal(value, &parsed.Search)
+		default:
+			if _, ok := indicator.Lookup(key); !ok {
+				return fmt.Errorf("unknown strategy: %s", key)
+			}
+			var ranges indicator.Ranges
+			err = json.Unmarshal(value, &ranges)
+			parsed.Ranges[key] = ranges
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	*bt = parsed
+	return nil
+}
+
+// BackTest excecutes backtest on the candles of the DB
 // Caution, the Symbol in BackTestParam is the same to ticker symbol of the candle data,
 // if those are different, deal with frontend process
 func (bt *BackTestParam) BackTest() *OptimizedParam {
+	return bt.BackTestFrame(GetCandleFrame(bt.Symbol, bt.Period))
+}
+
+// BackTestClient excecutes backtest on the last Period sessions of Symbol read by client,
+// the candles are not stored in the DB
+func (bt *BackTestParam) BackTestClient(client *stock.Client) (*OptimizedParam, error) {
+	candles, _, err := LoadCandles(client, bt.Symbol, bt.Period, techan.Daily)
+	if err != nil {
+		return nil, err
+	}
+	return bt.BackTestFrame(&CandleFrame{Symbol: bt.Symbol, Candles: *candles}), nil
+}
+
+// BackTestFrame excecutes backtest on the candles of cframe,
+// too few candles for a strategy leave its signals empty
+func (bt *BackTestParam) BackTestFrame(cframe *CandleFrame) *OptimizedParam {
 	DeleteBacktestResult(bt.Symbol)
 
-	cframe := GetCandleFrame(bt.Symbol, bt.Period)
 	logrus.Infof("backtest start: %v, %v", bt.Symbol, bt.Period)
 
-	bpEma, bpEmaShort, bpEmaLong := cframe.optimizeEma(
-		bt.Ema.EmaShortLow, bt.Ema.EmaShortHigh, bt.Ema.EmaLongLow, bt.Ema.EmaLongHigh)
-	bpBB, bpBBn, bpBBk := cframe.optimizeBB(
-		bt.BB.BBnLow, bt.BB.BBnHigh, bt.BB.BBkLow, bt.BB.BBkHigh)
-	bpMacd, bpMacdFast, bpMacdSlow, bpMacdSignal := cframe.optimizeMacd(
-		bt.Macd.MacdFastLow, bt.Macd.MacdFastHigh, bt.Macd.MacdSlowLow, bt.Macd.MacdSlowHigh,
-		bt.Macd.MacdSignalLow, bt.Macd.MacdSignalHigh)
-	bpRsi, bpRsiPeriod, bpRsiBuy, bpRsiSell := cframe.optimizeRsi(
-		bt.Rsi.RsiPeriodLow, bt.Rsi.RsiPeriodHigh, bt.Rsi.RsiBuyThreadLow, bt.Rsi.RsiBuyThreadHigh,
-		bt.Rsi.RsiSellThreadLow, bt.Rsi.RsiSellThreadHigh)
-	bpWillr, bpWillrPeriod, bpWillrBuy, bpWillrSell := cframe.optimizeWillr(
-		bt.Willr.WillrPeriodLow, bt.Willr.WillrPeriodHigh, bt.Willr.WillrBuyThreadLow, bt.Willr.WillrBuyThreadHigh,
-		bt.Willr.WillrSellThreadLow, bt.Willr.WillrSellThreadHigh)
-
 	op := OptimizedParam{
-		Timestamp:        time.Now().Unix() * 1000,
-		Symbol:           bt.Symbol,
-		EmaPerformance:   math.Round(bpEma*100) / 100,
-		EmaShort:         bpEmaShort,
-		EmaLong:          bpEmaLong,
-		BBPerformance:    math.Round(bpBB*100) / 100,
-		BBn:              bpBBn,
-		BBk:              math.Round(bpBBk*10) / 10,
-		MacdPerformance:  math.Round(bpMacd*100) / 100,
-		MacdFast:         bpMacdFast,
-		MacdSlow:         bpMacdSlow,
-		MacdSignal:       bpMacdSignal,
-		RsiPerformance:   math.Round(bpRsi*100) / 100,
-		RsiPeriod:        bpRsiPeriod,
-		RsiBuyThread:     bpRsiBuy,
-		RsiSellThread:    bpRsiSell,
-		WillrPerformance: math.Round(bpWillr*100) / 100,
-		WillrPeriod:      bpWillrPeriod,
-		WillrBuyThread:   bpWillrBuy,
-		WillrSellThread:  bpWillrSell,
-		EmaSignals:       cframe.backtestEma(1, bpEmaShort, bpEmaLong, nil).EmaSignals,
-		BBSignals:        cframe.backtestBB(1, bpBBn, bpBBk, nil).BBSignals,
-		MacdSignals:      cframe.backtestMacd(1, bpMacdFast, bpMacdSlow, bpMacdSignal, nil).MacdSignals,
-		RsiSignals:       cframe.backtestRsi(1, bpRsiPeriod, bpRsiBuy, bpRsiSell, nil).RsiSignals,
-		WillrSignals:     cframe.backtestWillr(1, bpWillrPeriod, bpWillrBuy, bpWillrSell, nil).WillrSignals,
+		Timestamp: time.Now().Unix() * 1000,
+		Symbol:    bt.Symbol,
+	}
+	candles := cframe.Bars()
+	costs, shares := bt.costs()
+	optimizer := indicator.Optimizer{Search: bt.Search, Costs: costs, Shares: shares}
+	for _, strategy := range indicator.Strategies() {
+		ranges, ok := bt.Ranges[strategy.Name]
+		if !ok {
+			continue
+		}
+		logrus.Infof("%s backtest start: params -> %v", strategy.DisplayName, ranges)
+		opt := optimizer.Optimize(strategy, bt.Symbol, candles, ranges)
+		best, performance := opt.Best, opt.Performance
+		logrus.Infof("%s backtest end: results -> %v, %v, %d evaluations", strategy.DisplayName, performance, best, len(opt.Evaluations))
+		if opt.Stopped {
+			logrus.Infof("%s backtest stopped by the search caps", strategy.DisplayName)
+		}
+
+		result := StrategyResult{
+			Strategy:    strategy.Name,
+			DisplayName: strategy.DisplayName,
+			Params:      best,
+			ParamsHash:  best.Hash(),
+			Performance: round(performance),
+			Evaluations: len(opt.Evaluations),
+			Stopped:     opt.Stopped,
+			Robustness:  round(strategy.Robustness(opt.Evaluations, best, performance, 1)),
+		}
+		for _, e := range opt.Evaluations {
+			result.Surface = append(result.Surface, SurfacePoint{Params: e.Params, Performance: e.Performance,
+				Gross: e.Gross, Fees: e.Fees, Tax: e.Tax, Trades: e.Trades})
+		}
+		if signals := strategy.Backtest(bt.Symbol, candles, best, 1, nil); signals != nil {
+			op.Signals = append(op.Signals, signals.Signals...)
+			result.Trades = signals.Trades(costs, shares)
+			total := fees.Sum(result.Trades)
+			result.Gross, result.Fees, result.Tax = round(total.Gross), round(total.Fees), round(total.Tax)
+		}
+		result.Metrics = indicator.Measure(candles, result.Trades, bt.Capital)
+		op.Results = append(op.Results, result)
 	}
 
 	return &op
 }
 
-// OptimizedParam is stored to optimized parameter for backtest,
+// costs returns the fees schedule and the shares of the trades of bt
+func (bt *BackTestParam) costs() (*fees.Schedule, float64) {
+	costs, shares := bt.Fees, bt.Shares
+	if costs == nil {
+		var err error
+		if costs, err = fees.Parse(config.Config.Fees); err != nil {
+			logrus.Warnf("fees error: %v", err)
+			costs = fees.NEPSE()
+		}
+	}
+	if shares == 0 {
+		shares = DefaultShares
+	}
+	return costs, shares
+}
+
+// round rounds v to paisa
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// OptimizedParam is stored to optimized parameter for backtest of every strategy,
 // also has relationships a part of signal results of backtest.
 type OptimizedParam struct {
-	ID               int                     `gorm:"primary_key" json:"-"`
-	Timestamp        int64                   `json:"timestamp"`
-	Symbol           string                  `json:"symbol"`
-	EmaPerformance   float64                 `json:"ema_performance"`
-	EmaShort         int                     `json:"ema_short"`
-	EmaLong          int                     `json:"ema_long"`
-	BBPerformance    float64                 `json:"bb_performance"`
-	BBn              int                     `json:"bb_n"`
-	BBk              float64                 `json:"bb_k"`
-	MacdPerformance  float64                 `json:"macd_performance"`
-	MacdFast         int                     `json:"macd_fast"`
-	MacdSlow         int                     `json:"macd_slow"`
-	MacdSignal       int                     `json:"macd_signal"`
-	RsiPerformance   float64                 `json:"rsi_performance"`
-	RsiPeriod        int                     `json:"rsi_period"`
-	RsiBuyThread     float64                 `json:"rsi_buythread"`
-	RsiSellThread    float64                 `json:"rsi_sellthread"`
-	WillrPerformance float64                 `json:"willr_performance"`
-	WillrPeriod      int                     `json:"willr_period"`
-	WillrBuyThread   float64                 `json:"willr_buythread"`
-	WillrSellThread  float64                 `json:"willr_sellthread"`
-	EmaSignals       []indicator.EmaSignal   `gorm:"foreignKey:Symbol;references:Symbol" json:"-"`
-	BBSignals        []indicator.BBSignal    `gorm:"foreignKey:Symbol;references:Symbol" json:"-"`
-	MacdSignals      []indicator.MacdSignal  `gorm:"foreignKey:Symbol;references:Symbol" json:"-"`
-	RsiSignals       []indicator.RsiSignal   `gorm:"foreignKey:Symbol;references:Symbol" json:"-"`
-	WillrSignals     []indicator.WillrSignal `gorm:"foreignKey:Symbol;references:Symbol" json:"-"`
+	ID        int                `gorm:"primary_key" json:"-"`
+	Timestamp int64              `json:"timestamp"`
+	BSDate    string             `gorm:"-" json:"bs_date,omitempty"`
+	Symbol    string             `json:"symbol"`
+	Results   []StrategyResult   `gorm:"foreignKey:OptimizedParamID" json:"strategies"`
+	Signals   []indicator.Signal `gorm:"foreignKey:Symbol;references:Symbol" json:"-"`
+}
+
+// StrategyResult is the best params of a strategy found by a backtest and their performance
+type StrategyResult struct {
+	ID               int    `gorm:"primary_key" json:"-"`
+	OptimizedParamID int    `gorm:"index" json:"-"`
+	Strategy         string `json:"strategy"`
+	// DisplayName is the display name of the strategy in the registry
+	DisplayName string           `gorm:"-" json:"name"`
+	Params      indicator.Params `gorm:"serializer:json" json:"params"`
+	ParamsHash  string           `json:"params_hash"`
+	// Performance is the net profit of the trades, after fees and tax
+	Performance float64 `json:"performance"`
+	// Gross, Fees and Tax are the totals of the trades
+	Gross  float64      `json:"gross"`
+	Fees   float64      `json:"fees"`
+	Tax    float64      `json:"tax"`
+	Trades []fees.Trade `gorm:"serializer:json" json:"trades"`
+	// Evaluations are the params searched, Stopped tells the caps of the search ended it early
+	Evaluations int  `json:"evaluations"`
+	Stopped     bool `json:"stopped"`
+	// Robustness is the mean performance of the params within one step of Params over Performance,
+	// see indicator.Strategy.Robustness
+	Robustness float64 `json:"robustness"`
+	// Metrics are measured on the equity curve of Trades, a column each
+	indicator.Metrics
+	// Surface are the params searched and their performance
+	Surface []SurfacePoint `gorm:"foreignKey:StrategyResultID" json:"-"`
+}
+
+// SurfacePoint is params searched by a backtest and the totals of their trades, see indicator.Evaluation
+type SurfacePoint struct {
+	ID               int              `gorm:"primary_key" json:"-"`
+	StrategyResultID int              `gorm:"index" json:"-"`
+	Params           indicator.Params `gorm:"serializer:json" json:"params"`
+	Performance      float64          `json:"performance"`
+	Gross            float64          `json:"gross"`
+	Fees             float64          `json:"fees"`
+	Tax              float64          `json:"tax"`
+	Trades           int              `json:"trades"`
+}
+
+// Evaluation returns p as an evaluation of its params
+func (p SurfacePoint) Evaluation() indicator.Evaluation {
+	return indicator.Evaluation{Params: p.Params, Performance: p.Performance, Gross: p.Gross, Fees: p.Fees, Tax: p.Tax, Trades: p.Trades}
+}
+
+// Result returns the result of strategy, nil when it was not backtested
+func (op *OptimizedParam) Result(strategy string) *StrategyResult {
+	for i := range op.Results {
+		if op.Results[i].Strategy == strategy {
+			return &op.Results[i]
+		}
+	}
+	return nil
 }
 
 // DeleteBacktestResult deletes all exiting data for symbol
 func DeleteBacktestResult(symbol string) {
-	DB.Delete(OptimizedParam{}, "Symbol LIKE ?", "%"+symbol+"%")
-	DB.Delete(indicator.EmaSignal{}, "Symbol LIKE ?", "%"+symbol+"%")
-	DB.Delete(indicator.BBSignal{}, "Symbol LIKE ?", "%"+symbol+"%")
-	DB.Delete(indicator.MacdSignal{}, "Symbol LIKE ?", "%"+symbol+"%")
-	DB.Delete(indicator.RsiSignal{}, "Symbol LIKE ?", "%"+symbol+"%")
-	DB.Delete(indicator.WillrSignal{}, "Symbol LIKE ?", "%"+symbol+"%")
+	params := DB.Model(&OptimizedParam{}).Select("id").Where("symbol = ?", symbol)
+	DB.Where("strategy_result_id IN (?)", DB.Model(&StrategyResult{}).Select("id").Where("optimized_param_id IN (?)", params)).
+		Delete(&SurfacePoint{})
+	DB.Where("optimized_param_id IN (?)", params).Delete(&StrategyResult{})
+	DB.Delete(OptimizedParam{}, "symbol = ?", symbol)
+	DB.Delete(indicator.Signal{}, "symbol = ?", symbol)
+}
+
+// ErrNoSurface is returned by GetSurface for a symbol or strategy without backtest results
+var ErrNoSurface = errors.New("no backtest result")
+
+// GetSurface returns the surface of the params of strategy searched by the last backtest of symbol on x and y,
+// the other params fixed to their best value, smoothed within radius values. Empty x and y are the first two params.
+func GetSurface(symbol, strategy, x, y string, radius int) (*indicator.Surface, error) {
+	s, ok := indicator.Lookup(strategy)
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy: %s", strategy)
+	}
+	var result StrategyResult
+	err := DB.Preload("Surface").
+		Where("strategy = ? AND optimized_param_id IN (?)", strategy, DB.Model(&OptimizedParam{}).Select("id").Where("symbol = ?", symbol)).
+		First(&result).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoSurface, symbol, strategy)
+	}
+	if err != nil {
+		return nil, err
+	}
+	evaluations := make([]indicator.Evaluation, len(result.Surface))
+	for i, p := range result.Surface {
+		evaluations[i] = p.Evaluation()
+	}
+	return s.Surface(evaluations, x, y, result.Params, radius)
 }
 
 // GetOptimizedParamFrame returns OptimizedParamFrame including OptimizedParam for symbol
@@ -120,20 +297,49 @@ func GetOptimizedParamFrame(symbol string) *OptimizedParamFrame {
 	var op OptimizedParam
 	var opframe OptimizedParamFrame
 
-	err := DB.First(&op, OptimizedParam{Symbol: symbol})
+	err := DB.Preload("Results", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&op, OptimizedParam{Symbol: symbol})
 	if err.Error != nil {
 		// Not Found
 		opframe.Param = nil
 		return &opframe
 	}
+	for i := range op.Results {
+		op.Results[i].DisplayName = op.Results[i].Strategy
+		if strategy, ok := indicator.Lookup(op.Results[i].Strategy); ok {
+			op.Results[i].DisplayName = strategy.DisplayName
+		}
+	}
 
 	opframe.Param = &op
 	return &opframe
 }
 
+// migrateBacktestResults drops the tables of DBs made before the strategy registry, one per strategy,
+// and the optimized params with a column per strategy param. Backtests store them again.
+func migrateBacktestResults(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, table := range []string{"ema_signals", "bb_signals", "macd_signals", "rsi_signals", "willr_signals"} {
+		if migrator.HasTable(table) {
+			logrus.Infof("%s replaced by signals, dropped", table)
+			if err := migrator.DropTable(table); err != nil {
+				return err
+			}
+		}
+	}
+	if migrator.HasTable(&OptimizedParam{}) && migrator.HasColumn(&OptimizedParam{}, "ema_performance") {
+		logrus.Info("optimized params of every strategy dropped")
+		if err := migrator.DropTable(&OptimizedParam{}); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(&OptimizedParam{}, &StrategyResult{}, &SurfacePoint{}, &indicator.Signal{})
+}
+
 // CreateBacktestResult creates new backtest results, but before create, you delete existing data, beforehand
 func (op *OptimizedParam) CreateBacktestResult() error {
-	if err := DB.Create(op).Error; err != nil {
+	// the surfaces of wide ranges are more rows than SQLite takes at once
+	if err := DB.Session(&gorm.Session{CreateBatchSize: 500}).Create(op).Error; err != nil {
 		return err
 	}
 	return nil
@@ -1,7 +1,19 @@
 package models_test
 
 import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
 	"github.com/oarkflow/nepse/app/models"
+	"github.com/oarkflow/nepse/app/models/indicator"
+	"github.com/oarkflow/nepse/fees"
 )
 
 func (suite *ModelsTestSuite) TestCreateBacktestResult() {
@@ -16,6 +28,13 @@ func (suite *ModelsTestSuite) TestGetOptimizedParamFrame() {
 
 	opframe := models.GetOptimizedParamFrame("VOO")
 	suite.NotEmpty(opframe.Param)
+	suite.Len(opframe.Param.Results, len(suite.Op.Results))
+	for i, result := range opframe.Param.Results {
+		suite.Equal(suite.Op.Results[i].Strategy, result.Strategy)
+		suite.Equal(suite.Op.Results[i].DisplayName, result.DisplayName)
+		suite.Equal(suite.Op.Results[i].Params, result.Params)
+		suite.Equal(suite.Op.Results[i].Metrics, result.Metrics)
+	}
 
 	opframe = models.GetOptimizedParamFrame("TEST")
 	suite.Nil(opframe.Param)
@@ -24,3 +43,191 @@ func (suite *ModelsTestSuite) TestGetOptimizedParamFrame() {
 	opframe = models.GetOptimizedParamFrame("VOO")
 	suite.Nil(opframe.Param)
 }
+
+func (suite *ModelsTestSuite) TestBackTestCosts() {
+	for _, result := range suite.Op.Results {
+		total := fees.Sum(result.Trades)
+		suite.InDelta(total.Gross, result.Gross, 0.01, result.Strategy)
+		suite.InDelta(total.Net, result.Gross-result.Fees-result.Tax, 0.02, result.Strategy)
+		for _, trade := range result.Trades {
+			suite.Equal(float64(models.DefaultShares), trade.Shares)
+			suite.Greater(trade.Fees, 0.0)
+		}
+		if len(result.Trades) != 0 && result.Performance != 0 {
+			suite.InDelta(total.Net, result.Performance, 0.01, result.Strategy)
+		}
+	}
+
+	// frictionless trades of one share keep the price differences
+	bt := backTestParam
+	bt.Shares, bt.Fees = 1, &fees.Schedule{}
+	op := bt.BackTest()
+	for _, result := range op.Results {
+		suite.Equal(0.0, result.Fees)
+		suite.Equal(0.0, result.Tax)
+	}
+	// the costs lower the performance
+	for _, result := range suite.Op.Results {
+		suite.LessOrEqual(result.Performance, float64(models.DefaultShares)*op.Result(result.Strategy).Performance+0.01)
+	}
+}
+
+func (suite *ModelsTestSuite) TestBackTestMetrics() {
+	for _, result := range suite.Op.Results {
+		suite.Equal(len(result.Trades), result.TradeCount, result.Strategy)
+		if len(result.Trades) == 0 {
+			suite.Equal(0.0, result.TotalReturn, result.Strategy)
+			continue
+		}
+		// the equity ends at the net profit of the trades
+		suite.Greater(result.Capital, 0.0, result.Strategy)
+		suite.InDelta(fees.Sum(result.Trades).Net/result.Capital*100, result.TotalReturn, 1e-6, result.Strategy)
+		suite.GreaterOrEqual(result.MaxDrawdown, 0.0, result.Strategy)
+		suite.GreaterOrEqual(result.Exposure, 0.0, result.Strategy)
+		suite.LessOrEqual(result.Exposure, 100.0, result.Strategy)
+		suite.InDelta(result.Expectancy, fees.Sum(result.Trades).Net/float64(len(result.Trades)), 1e-6, result.Strategy)
+	}
+
+	// the capital given
+	bt := backTestParam
+	bt.Capital = 1000000
+	for _, result := range bt.BackTest().Results {
+		if len(result.Trades) != 0 {
+			suite.Equal(1000000.0, result.Capital, result.Strategy)
+		}
+	}
+}
+
+func (suite *ModelsTestSuite) TestBackTestSearch() {
+	for _, result := range suite.Op.Results {
+		suite.Greater(result.Evaluations, 0, result.Strategy)
+		suite.False(result.Stopped, result.Strategy)
+	}
+
+	bt := backTestParam
+	bt.Search = indicator.Search{Method: indicator.RandomSearch, Evaluations: 20, Seed: 1}
+	for _, result := range bt.BackTest().Results {
+		suite.LessOrEqual(result.Evaluations, 20, result.Strategy)
+	}
+}
+
+func (suite *ModelsTestSuite) TestGetSurface() {
+	suite.Nil(suite.Op.CreateBacktestResult())
+
+	ema := suite.Op.Result("ema")
+	suite.Len(ema.Surface, ema.Evaluations)
+	surface, err := models.GetSurface("VOO", "ema", "", "", 1)
+	suite.Nil(err)
+	suite.Equal("short", surface.X.Name)
+	suite.Equal("long", surface.Y.Name)
+	suite.Len(surface.X.Values, 11)
+	suite.Len(surface.Y.Values, 16)
+	// the whole grid was searched
+	for j := range surface.Y.Values {
+		for i := range surface.X.Values {
+			suite.NotNil(surface.Performance[j][i])
+		}
+	}
+	cell := surface.Performance[indexOf(surface.Y.Values, ema.Params["long"])][indexOf(surface.X.Values, ema.Params["short"])]
+	suite.InDelta(ema.Performance, *cell, 0.01)
+
+	// macd, signal fixed to its best value
+	macd := suite.Op.Result("macd")
+	surface, err = models.GetSurface("VOO", "macd", "slow", "fast", 2)
+	suite.Nil(err)
+	suite.Equal(indicator.Params{"signal": macd.Params["signal"]}, surface.Fixed)
+	suite.Equal(2, surface.Radius)
+
+	_, err = models.GetSurface("TEST", "ema", "", "", 1)
+	suite.True(errors.Is(err, models.ErrNoSurface))
+	_, err = models.GetSurface("VOO", "sma", "", "", 1)
+	suite.NotNil(err)
+	_, err = models.GetSurface("VOO", "ema", "period", "", 1)
+	suite.NotNil(err)
+
+	// deleted with the results
+	models.DeleteBacktestResult("VOO")
+	var n int64
+	models.DB.Model(&models.SurfacePoint{}).Count(&n)
+	suite.Equal(int64(0), n)
+}
+
+func indexOf(values []float64, v float64) int {
+	for i, value := range values {
+		if value == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func (suite *ModelsTestSuite) TestRobustness() {
+	for _, result := range suite.Op.Results {
+		if result.Performance > 0 {
+			suite.NotEqual(0.0, result.Robustness, result.Strategy)
+			suite.LessOrEqual(result.Robustness, 1.0, result.Strategy)
+		} else {
+			suite.Equal(0.0, result.Robustness, result.Strategy)
+		}
+	}
+}
+
+func TestBackTestParamJSON(t *testing.T) {
+	assert := assert.New(t)
+	var bt models.BackTestParam
+	assert.Nil(json.Unmarshal([]byte(`{"symbol": "NABIL", "period": 500,
+		"ema": {"short_low": 5, "short_high": 15, "long_low": 15, "long_high": 30}}`), &bt))
+	assert.Equal("NABIL", bt.Symbol)
+	assert.Equal(500, bt.Period)
+	assert.Equal(map[string]indicator.Ranges{"ema": {"short": {Low: 5, High: 15}, "long": {Low: 15, High: 30}}}, bt.Ranges)
+
+	data, err := json.Marshal(bt)
+	assert.Nil(err)
+	var again models.BackTestParam
+	assert.Nil(json.Unmarshal(data, &again))
+	assert.Equal(bt, again)
+
+	assert.Nil(json.Unmarshal([]byte(`{"symbol": "NABIL", "shares": 100, "capital": 200000, "fees": {"dp": 5, "cgt_short": 7.5},
+		"search": {"method": "random", "evaluations": 50, "seconds": 2.5}}`), &bt))
+	assert.Equal(100.0, bt.Shares)
+	assert.Equal(200000.0, bt.Capital)
+	assert.Equal(&fees.Schedule{DP: 5, CGTShort: 7.5}, bt.Fees)
+	assert.Equal(indicator.Search{Method: indicator.RandomSearch, Evaluations: 50, Seconds: 2.5}, bt.Search)
+	data, err = json.Marshal(bt)
+	assert.Nil(err)
+	again = models.BackTestParam{}
+	assert.Nil(json.Unmarshal(data, &again))
+	assert.Equal(bt, again)
+
+	assert.EqualError(json.Unmarshal([]byte(`{"symbol": "NABIL", "sma": {}}`), &bt), "unknown strategy: sma")
+	assert.NotNil(json.Unmarshal([]byte(`{"ema": {"short": 5}}`), &bt))
+}
+
+func TestMigrateBacktestResults(t *testing.T) {
+	assert := assert.New(t)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "old.sqlite3")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.Nil(err)
+	// the tables of DBs made before the strategy registry
+	assert.Nil(db.Exec("CREATE TABLE ema_signals (id integer PRIMARY KEY AUTOINCREMENT, symbol text, time integer, action text)").Error)
+	assert.Nil(db.Exec("CREATE TABLE optimized_params (id integer PRIMARY KEY AUTOINCREMENT, symbol text, ema_performance real, ema_short integer)").Error)
+	assert.Nil(db.Exec("INSERT INTO optimized_params (symbol, ema_performance) VALUES ('ADBL', 1.5)").Error)
+
+	assert.Nil(models.MigrateDB(db))
+	assert.False(db.Migrator().HasTable("ema_signals"))
+	assert.False(db.Migrator().HasColumn(&models.OptimizedParam{}, "ema_performance"))
+	assert.True(db.Migrator().HasTable(&models.StrategyResult{}))
+	assert.True(db.Migrator().HasTable(&indicator.Signal{}))
+
+	// migrating again keeps the results
+	op := models.OptimizedParam{Symbol: "ADBL", Results: []models.StrategyResult{
+		{Strategy: "ema", Params: indicator.Params{"short": 7, "long": 14}},
+	}}
+	assert.Nil(db.Create(&op).Error)
+	assert.Nil(models.MigrateDB(db))
+	var results []models.StrategyResult
+	db.Find(&results)
+	assert.Len(results, 1)
+	assert.Equal(indicator.Params{"short": 7, "long": 14}, results[0].Params)
+}
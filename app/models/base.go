@@ -1,7 +1,6 @@
 package models
 
 import (
-	"github.com/oarkflow/nepse/app/models/indicator"
 	"github.com/oarkflow/nepse/config"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/sqlite"
@@ -23,13 +22,18 @@ func InitDB() {
 		logrus.Warnf("database open error: %v", err)
 	}
 
-	DB.AutoMigrate(
-		&Candle{},
-		&OptimizedParam{},
-		&indicator.EmaSignal{},
-		&indicator.BBSignal{},
-		&indicator.MacdSignal{},
-		&indicator.RsiSignal{},
-		&indicator.WillrSignal{},
-	)
+	if err := MigrateDB(DB); err != nil {
+		logrus.Warnf("database migrate error: %v", err)
+	}
+}
+
+// MigrateDB creates or updates the tables of the models in db
+func MigrateDB(db *gorm.DB) error {
+	if err := migrateCandles(db); err != nil {
+		return err
+	}
+	if err := migrateBacktestResults(db); err != nil {
+		return err
+	}
+	return db.AutoMigrate(&Bar{})
 }
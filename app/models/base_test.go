@@ -1,10 +1,15 @@
 package models_test
 
 import (
+	"math"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/nepse"
 	"github.com/oarkflow/nepse/stock"
+	"github.com/oarkflow/nepse/techan"
 	"github.com/sirupsen/logrus"
 
 	"github.com/oarkflow/nepse/app/models"
@@ -18,46 +23,18 @@ import (
 var backTestParam = models.BackTestParam{
 	Symbol: "VOO",
 	Period: 500,
-	Ema: &indicator.EmaBacktestParam{
-		EmaShortLow:  5,
-		EmaShortHigh: 15,
-		EmaLongLow:   15,
-		EmaLongHigh:  30,
-	},
-	BB: &indicator.BBBacktestParam{
-		BBnLow:  10,
-		BBnHigh: 30,
-		BBkLow:  1.5,
-		BBkHigh: 2.5,
-	},
-	Macd: &indicator.MacdBacktestParam{
-		MacdFastLow:    5,
-		MacdFastHigh:   20,
-		MacdSlowLow:    20,
-		MacdSlowHigh:   35,
-		MacdSignalLow:  5,
-		MacdSignalHigh: 20,
-	},
-	Rsi: &indicator.RsiBacktestParam{
-		RsiPeriodLow:      5,
-		RsiPeriodHigh:     50,
-		RsiBuyThreadLow:   20,
-		RsiBuyThreadHigh:  35,
-		RsiSellThreadLow:  65,
-		RsiSellThreadHigh: 80,
-	},
-	Willr: &indicator.WillrBacktestParam{
-		WillrPeriodLow:      5,
-		WillrPeriodHigh:     50,
-		WillrBuyThreadLow:   -90,
-		WillrBuyThreadHigh:  -75,
-		WillrSellThreadLow:  -25,
-		WillrSellThreadHigh: -10,
+	Ranges: map[string]indicator.Ranges{
+		"ema":   {"short": {Low: 5, High: 15}, "long": {Low: 15, High: 30}},
+		"bb":    {"n": {Low: 10, High: 30}, "k": {Low: 1.5, High: 2.5}},
+		"macd":  {"fast": {Low: 5, High: 20}, "slow": {Low: 20, High: 35}, "signal": {Low: 5, High: 20}},
+		"rsi":   {"period": {Low: 5, High: 50}, "buy": {Low: 20, High: 35}, "sell": {Low: 65, High: 80}},
+		"willr": {"period": {Low: 5, High: 50}, "buy": {Low: -90, High: -75}, "sell": {Low: -25, High: -10}},
 	},
 }
 
 type ModelsTestSuite struct {
 	suite.Suite
+	Client  *stock.Client
 	Candles *models.Candles
 	Op      *models.OptimizedParam
 }
@@ -68,19 +45,10 @@ func (suite *ModelsTestSuite) SetupSuite() {
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 
-	models.DB.AutoMigrate(
-		&models.Candle{},
-		&models.OptimizedParam{},
-		&indicator.EmaSignal{},
-		&indicator.BBSignal{},
-		&indicator.MacdSignal{},
-		&indicator.RsiSignal{},
-		&indicator.WillrSignal{},
-	)
+	models.MigrateDB(models.DB)
 
-	adjStock, _ := stock.GetStockData("VOO", 500, true)
-	Stock, _ := stock.GetStockData("VOO", 500, false)
-	suite.Candles = models.NewCandlesFromQuote(adjStock, Stock)
+	suite.Client = fixtureClient()
+	suite.Candles, _, _ = models.LoadCandles(suite.Client, "VOO", 500, techan.Daily)
 }
 
 func (suite *ModelsTestSuite) SetupTest() {
@@ -96,6 +64,27 @@ func (suite *ModelsTestSuite) TearDownSuite() {
 	os.Remove("models_test.sqlite3")
 }
 
+// fixtureClient returns a client reading sessions of VOO and GOOGL over the last three years,
+// their close swinging around a rising trend so the strategies trade
+func fixtureClient() *stock.Client {
+	var rows []nepse.StockData
+	cal := calendar.New()
+	days := cal.TradingDays(time.Now().AddDate(-3, 0, 0), time.Now())
+	for _, symbol := range []string{"VOO", "GOOGL"} {
+		for i, day := range days {
+			price := 100 + 10*math.Sin(float64(i)/8) + float64(i)/20
+			rows = append(rows, nepse.StockData{
+				Symbol: symbol, Date: day,
+				OpenPrice: price - 1, HighPrice: price + 2, LowPrice: price - 2, ClosePrice: price,
+				VWAP: price, Volume: 1000, Turnover: 1000 * price, Transactions: 10,
+			})
+		}
+	}
+	client := stock.NewClient(stock.NewMemorySource(rows...))
+	client.Calendar = cal
+	return client
+}
+
 func TestModels(t *testing.T) {
 	suite.Run(t, new(ModelsTestSuite))
 }
@@ -1,11 +1,19 @@
 package models
 
 import (
+	"fmt"
 	"math"
 	"sort"
+	"sync"
 
 	"github.com/markcheno/go-quote"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/stock"
+	"github.com/oarkflow/nepse/techan"
 )
 
 // Candles is slice of Candle
@@ -15,28 +23,51 @@ type Candles []Candle
 // NewCandlesFromQuote converts Quote to slice of Candle due to creating in database,
 // ex) [Date[1, 2, 3...], Open[1, 2, 3...]...] → [[Date[1], Open[1]...], [Date[2], Open[2]...]...]
 // and return pointer of Candles(used as constructor)
-// Because of using for frondend, this method also converts time to Unixtime
+// Because of using for frondend, this method also converts time to Unixtime.
+// Prices and volume are taken from adjStock so backtests see no drop on book closures,
+// the raw close is kept as RawClose. The candles are those of the symbol of Stock.
 func NewCandlesFromQuote(adjStock *quote.Quote, Stock *quote.Quote) *Candles {
 	candles := Candles{}
 	for i := 0; i < len(Stock.Date); i++ {
 		candles = append(candles, Candle{
-			Time:   Stock.Date[i].Unix() * 1000,
-			Open:   (math.Round(adjStock.Open[i]*100) / 100),
-			High:   (math.Round(adjStock.High[i]*100) / 100),
-			Low:    (math.Round(adjStock.Low[i]*100) / 100),
-			Close:  (math.Round(Stock.Close[i]*100) / 100),
-			Volume: (math.Round(adjStock.Volume[i]*100) / 100),
+			Symbol:   Stock.Symbol,
+			Time:     Stock.Date[i].Unix() * 1000,
+			Open:     (math.Round(adjStock.Open[i]*100) / 100),
+			High:     (math.Round(adjStock.High[i]*100) / 100),
+			Low:      (math.Round(adjStock.Low[i]*100) / 100),
+			Close:    (math.Round(adjStock.Close[i]*100) / 100),
+			RawClose: (math.Round(Stock.Close[i]*100) / 100),
+			Volume:   (math.Round(adjStock.Volume[i]*100) / 100),
 		})
 	}
 
 	return &candles
 }
 
-// GetCandleFrame gets candle data for limit by descending
+// LoadCandles reads the last period sessions of symbol with client, merged into the candles of timeframe,
+// and returns them as Candles, see NewCandlesFromQuote, with the raw symbols they come from.
+// A symbol without sessions gives stock.ErrNoData.
+func LoadCandles(client *stock.Client, symbol string, period int, timeframe techan.Timeframe) (*Candles, []nepse.Segment, error) {
+	// stitched once, the adjusted prices pairing with the raw ones session by session
+	Stock, segments, err := client.GetStitchedData(symbol, period, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(Stock.Date) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", stock.ErrNoData, symbol)
+	}
+	adjStock := client.AdjustQuote(Stock, segments)
+	if !timeframe.IsDaily() {
+		adjStock, Stock = stock.ResampleQuote(adjStock, timeframe), stock.ResampleQuote(Stock, timeframe)
+	}
+	return NewCandlesFromQuote(adjStock, Stock), segments, nil
+}
+
+// GetCandleFrame gets the last limit candles of symbol, by descending
 // After get data, return DataFrame stored in data
 func GetCandleFrame(symbol string, limit int) *CandleFrame {
 	var candles Candles
-	DB.Order("time desc").Limit(limit).Find(&candles)
+	DB.Where("symbol = ?", symbol).Order("time desc").Limit(limit).Find(&candles)
 	sort.Slice(candles, func(i, j int) bool { return candles[i].Time < candles[j].Time })
 
 	cframe := CandleFrame{}
@@ -51,36 +82,70 @@ func AllDeleteCandles() {
 	DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Candle{})
 }
 
-// CreateCandles creates candle data
-func (cs *Candles) CreateCandles() {
-	DB.Create(cs)
+// DeleteCandles deletes the candles of symbol
+func DeleteCandles(symbol string) {
+	DB.Where("symbol = ?", symbol).Delete(&Candle{})
 }
 
-// Candle is daily stock candledata, also used as json
+// candleMutex serializes the writes of candles, SQLite failing writes made at the same time
+var candleMutex sync.Mutex
+
+// CreateCandles stores the candles, a candle replacing the one of the same symbol and time,
+// the candles of other symbols are left as they are
+func (cs *Candles) CreateCandles() error {
+	if len(*cs) == 0 {
+		return nil
+	}
+	candleMutex.Lock()
+	defer candleMutex.Unlock()
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "time"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "raw_close", "volume"}),
+	}).CreateInBatches(cs, 500).Error
+}
+
+// Candle is daily stock candledata of Symbol, also used as json,
+// prices are adjusted for corporate actions except RawClose
 type Candle struct {
-	ID     int     `json:"-"`
-	Time   int64   `json:"time"`
-	Open   float64 `json:"open"`
-	High   float64 `json:"high"`
-	Low    float64 `json:"low"`
-	Close  float64 `json:"close"`
-	Volume float64 `json:"volume"`
+	ID       int     `json:"-"`
+	Symbol   string  `gorm:"uniqueIndex:idx_candles_symbol_time;not null;default:''" json:"-"`
+	Time     int64   `gorm:"uniqueIndex:idx_candles_symbol_time" json:"time"`
+	BSDate   string  `gorm:"-" json:"bs_date,omitempty"`
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	RawClose float64 `json:"raw_close"`
+	Volume   float64 `json:"volume"`
 }
 
-// LastCandleTime returns a time of last candle
-func LastCandleTime() (int64, error) {
+// LastCandleTime returns a time of last candle of symbol
+func LastCandleTime(symbol string) (int64, error) {
 	var candle Candle
-	if err := DB.Last(&candle).Error; err != nil {
+	if err := DB.Where("symbol = ?", symbol).Order("time desc").First(&candle).Error; err != nil {
 		return 0, err
 	}
 	return candle.Time, nil
 }
 
-// MatchTime returns ID mathed to Time field
-func MatchTime(time int64) (int, error) {
+// MatchTime returns ID of the candle of symbol mathed to Time field
+func MatchTime(symbol string, time int64) (int, error) {
 	var candle Candle
-	if err := DB.Where("Time = ?", time).First(&candle).Error; err != nil {
+	if err := DB.Where("symbol = ? AND time = ?", symbol, time).First(&candle).Error; err != nil {
 		return 0, err
 	}
 	return candle.ID, nil
 }
+
+// migrateCandles creates or updates the "candles" table. The candles of DBs made before they had
+// a symbol can not tell which symbol they are, they are dropped and stored again by the next request.
+func migrateCandles(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if migrator.HasTable(&Candle{}) && !migrator.HasColumn(&Candle{}, "Symbol") {
+		logrus.Info("candles without symbol dropped")
+		if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Candle{}).Error; err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(&Candle{})
+}
@@ -1,14 +1,28 @@
 package models_test
 
 import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
 	"github.com/oarkflow/nepse/app/models"
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/nepse"
 	"github.com/oarkflow/nepse/stock"
+	"github.com/oarkflow/nepse/techan"
 )
 
 func (suite *ModelsTestSuite) TestCreateCandles() {
-	adjStock, _ := stock.GetStockData("VOO", 10, true)
-	Stock, _ := stock.GetStockData("VOO", 10, false)
-	candles := models.NewCandlesFromQuote(adjStock, Stock)
+	candles, _, err := models.LoadCandles(suite.Client, "VOO", 10, techan.Daily)
+
+	suite.Nil(err)
 
 	suite.NotEmpty(candles)
 
@@ -30,7 +44,7 @@ func (suite *ModelsTestSuite) TestGetCandleFrame() {
 func (suite *ModelsTestSuite) TestLastCandleTime() {
 	cframe := models.GetCandleFrame("VOO", 500)
 	lastTime := cframe.Candles[len(cframe.Candles)-1].Time
-	lastCandleTime, err := models.LastCandleTime()
+	lastCandleTime, err := models.LastCandleTime("VOO")
 
 	suite.Equal(lastTime, lastCandleTime)
 	suite.Nil(err)
@@ -41,15 +55,15 @@ func (suite *ModelsTestSuite) TestMatchTime() {
 	firstCandle := cframe.Candles[0]
 	lastCandle := cframe.Candles[len(cframe.Candles)-1]
 
-	firstMatch, err1 := models.MatchTime(firstCandle.Time)
-	lastMatch, err2 := models.MatchTime(lastCandle.Time)
+	firstMatch, err1 := models.MatchTime("VOO", firstCandle.Time)
+	lastMatch, err2 := models.MatchTime("VOO", lastCandle.Time)
 
 	suite.Equal(firstCandle.ID, firstMatch)
 	suite.Nil(err1)
 	suite.Equal(lastCandle.ID, lastMatch)
 	suite.Nil(err2)
 
-	wrongMatch, err := models.MatchTime(firstCandle.Time + 1)
+	wrongMatch, err := models.MatchTime("VOO", firstCandle.Time+1)
 
 	suite.Equal(0, wrongMatch)
 	suite.NotNil(err)
@@ -61,3 +75,133 @@ func (suite *ModelsTestSuite) TestAllDeleteCandles() {
 
 	suite.Empty(cframe.Candles)
 }
+
+func (suite *ModelsTestSuite) TestCandlesPerSymbol() {
+	googl, _, err := models.LoadCandles(suite.Client, "GOOGL", 500, techan.Daily)
+	suite.Nil(err)
+	for i := range *googl {
+		(*googl)[i].Close += 1000
+	}
+	suite.Nil(googl.CreateCandles())
+
+	// the candles of VOO are left as they are
+	voo := models.GetCandleFrame("VOO", 500)
+	suite.Len(voo.Candles, 500)
+	suite.Less(voo.Candles[0].Close, 1000.0)
+	suite.Greater(models.GetCandleFrame("GOOGL", 500).Candles[0].Close, 1000.0)
+
+	// storing again updates the candles of the same time
+	latest := (*googl)[len(*googl)-1:]
+	latest[0].Close = 1
+	suite.Nil(latest.CreateCandles())
+	cframe := models.GetCandleFrame("GOOGL", 1000)
+	suite.Len(cframe.Candles, 500)
+	suite.Equal(1.0, cframe.Candles[499].Close)
+	lastTime, _ := models.LastCandleTime("GOOGL")
+	suite.Equal(latest[0].Time, lastTime)
+
+	_, err = models.MatchTime("TEST", latest[0].Time)
+	suite.NotNil(err)
+
+	models.DeleteCandles("GOOGL")
+	suite.Empty(models.GetCandleFrame("GOOGL", 10).Candles)
+	suite.Len(models.GetCandleFrame("VOO", 10).Candles, 10)
+}
+
+func (suite *ModelsTestSuite) TestCreateCandlesConcurrently() {
+	symbols := []string{"VOO", "GOOGL"}
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(symbols))
+	for i := 0; i < 2; i++ {
+		for _, symbol := range symbols {
+			wg.Add(1)
+			go func(symbol string) {
+				defer wg.Done()
+				candles, _, err := models.LoadCandles(suite.Client, symbol, 500, techan.Daily)
+				if err == nil {
+					err = candles.CreateCandles()
+				}
+				errs <- err
+			}(symbol)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		suite.Nil(err)
+	}
+	for _, symbol := range symbols {
+		suite.Len(models.GetCandleFrame(symbol, 1000).Candles, 500)
+	}
+	models.DeleteCandles("GOOGL")
+}
+
+// growingSource is a source getting its last session once the sessions were first read, like when the poller
+// adds a session between two reads
+type growingSource struct {
+	source *stock.StoreSource
+	last   nepse.StockData
+	added  bool
+}
+
+func (g *growingSource) Symbols() ([]string, error) { return g.source.Symbols() }
+
+func (g *growingSource) History(symbol string, from, to time.Time) (*nepse.Series, error) {
+	series, err := g.source.History(symbol, from, to)
+	if !g.added {
+		g.added = true
+		g.source.Add(g.last)
+	}
+	return series, err
+}
+
+func (g *growingSource) Latest(symbol string) (nepse.StockData, error) {
+	return g.source.Latest(symbol)
+}
+
+func (g *growingSource) Subscribe(ctx context.Context) (<-chan nepse.StockData, error) {
+	return g.source.Subscribe(ctx)
+}
+
+func TestLoadCandlesGrowing(t *testing.T) {
+	assert := assert.New(t)
+	cal := calendar.New()
+	var rows []nepse.StockData
+	for i, day := range cal.TradingDays(time.Now().AddDate(0, -1, 0), time.Now()) {
+		price := 100 + float64(i)
+		rows = append(rows, nepse.StockData{Symbol: "VOO", Date: day, OpenPrice: price, HighPrice: price, LowPrice: price, ClosePrice: price})
+	}
+	source := &growingSource{source: stock.NewMemorySource(rows[:len(rows)-1]...), last: rows[len(rows)-1]}
+	client := stock.NewClient(source)
+	client.Calendar = cal
+
+	candles, _, err := models.LoadCandles(client, "VOO", 10, techan.Daily)
+	assert.Nil(err)
+	assert.True(source.added)
+	for _, candle := range *candles {
+		assert.Equal(candle.RawClose, candle.Close)
+	}
+}
+
+func TestMigrateDB(t *testing.T) {
+	assert := assert.New(t)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "old.sqlite3")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.Nil(err)
+	// the candles table of DBs made before candles had a symbol
+	assert.Nil(db.Exec("CREATE TABLE candles (id integer PRIMARY KEY AUTOINCREMENT, time integer, open real, high real, low real, close real, raw_close real, volume real)").Error)
+	assert.Nil(db.Exec("INSERT INTO candles (time, close) VALUES (1, 100), (2, 101)").Error)
+
+	assert.Nil(models.MigrateDB(db))
+	assert.True(db.Migrator().HasColumn(&models.Candle{}, "Symbol"))
+	assert.True(db.Migrator().HasIndex(&models.Candle{}, "idx_candles_symbol_time"))
+	var n int64
+	db.Model(&models.Candle{}).Count(&n)
+	assert.Equal(int64(0), n)
+	// migrating again keeps the candles
+	assert.Nil(db.Create(&models.Candle{Symbol: "ADBL", Time: 1}).Error)
+	assert.Nil(models.MigrateDB(db))
+	db.Model(&models.Candle{}).Count(&n)
+	assert.Equal(int64(1), n)
+}
@@ -1,9 +1,12 @@
 package models
 
 import (
-	"github.com/markcheno/go-talib"
+	"time"
+
 	"github.com/oarkflow/nepse/app/models/indicator"
-	"github.com/sirupsen/logrus"
+	"github.com/oarkflow/nepse/bsdate"
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
 )
 
 // DataFrame is data frame including candles, optimized parameters, signals
@@ -12,6 +15,12 @@ type DataFrame struct {
 	*OptimizedParamFrame
 	*SignalFrame
 	*TradeFrame
+	// Security is the symbol master entry of the symbol, its sector and instrument type
+	Security *listing.Security `json:"security,omitempty"`
+	// Segments are the raw symbols the candles come from, when the symbol was renamed or merged from others
+	Segments []nepse.Segment `json:"segments,omitempty"`
+	// Timeframe is the timeframe the daily candles were merged into, empty for daily candles
+	Timeframe string `json:"timeframe,omitempty"`
 }
 
 // NewDataFrame is constructor of DataFrame
@@ -24,9 +33,9 @@ func (dframe *DataFrame) AddCandleFrame(symbol string, limit int) {
 	dframe.CandleFrame = GetCandleFrame(symbol, limit)
 }
 
-// AddSignalFrame adds SignalFrame in DataFrame
-func (dframe *DataFrame) AddSignalFrame(symbol string, ema, bb, macd, rsi, willr bool) {
-	dframe.SignalFrame = GetSignalFrame(symbol, ema, bb, macd, rsi, willr)
+// AddSignalFrame adds SignalFrame of strategies in DataFrame
+func (dframe *DataFrame) AddSignalFrame(symbol string, strategies ...string) {
+	dframe.SignalFrame = GetSignalFrame(symbol, strategies...)
 }
 
 // AddOptimizedParamFrame adds OptimizedParamFrame in DataFrame
@@ -39,9 +48,51 @@ func (dframe *DataFrame) AddTradeFrame(symbol string) {
 	dframe.TradeFrame = GetTradeState(symbol)
 }
 
+// AddSecurity adds the entry of symbol in master to DataFrame, when master lists it
+func (dframe *DataFrame) AddSecurity(master *listing.Master, symbol string) {
+	if master == nil {
+		return
+	}
+	if security, ok := master.Get(symbol); ok {
+		dframe.Security = &security
+	}
+}
+
+// AddBSDates sets the Bikram Sambat date of the candles, signals and optimized params already in DataFrame,
+// dates the BS calendar does not cover are left empty
+func (dframe *DataFrame) AddBSDates() {
+	if dframe.CandleFrame != nil {
+		for i := range dframe.Candles {
+			dframe.Candles[i].BSDate = bsDate(dframe.Candles[i].Time)
+		}
+	}
+	if dframe.SignalFrame != nil && dframe.Signals != nil {
+		for _, signals := range dframe.Signals {
+			for i := range signals {
+				signals[i].BSDate = bsDate(signals[i].Time)
+			}
+		}
+	}
+	if dframe.OptimizedParamFrame != nil && dframe.Param != nil {
+		// Timestamp is when the backtest ran, not a trading day, so the local day is used
+		if date, err := bsdate.FromAD(time.UnixMilli(dframe.Param.Timestamp)); err == nil {
+			dframe.Param.BSDate = date.String()
+		}
+	}
+}
+
+// bsDate formats the BS date of a candle or signal time, or returns "" when it is out of range
+func bsDate(msec int64) string {
+	date, err := bsdate.FromUnixMilli(msec)
+	if err != nil {
+		return ""
+	}
+	return date.String()
+}
+
 // SignalFrame is dataframe of SignalEvents
 type SignalFrame struct {
-	Signals *SignalEvents `json:"signals,omitempty"`
+	Signals SignalEvents `json:"signals,omitempty"`
 }
 
 // OptimizedParamFrame is optimized params data frame
@@ -49,9 +100,19 @@ type OptimizedParamFrame struct {
 	Param *OptimizedParam `json:"optimized_params,omitempty"`
 }
 
-// TradeFrame is Trade frame
+// TradeFrame is the Trade of every strategy
 type TradeFrame struct {
-	Trade *Trade `json:"trade,omitempty"`
+	Trade []Trade `json:"trade,omitempty"`
+}
+
+// Strategy returns the Trade of strategy, false when the frame does not have it
+func (tframe *TradeFrame) Strategy(strategy string) (Trade, bool) {
+	for _, trade := range tframe.Trade {
+		if trade.Strategy == strategy {
+			return trade, true
+		}
+	}
+	return Trade{}, false
 }
 
 // CandleFrame is candle data frame
@@ -105,320 +166,18 @@ func (cframe *CandleFrame) Volumes() []float64 {
 	return volume
 }
 
-// following, using for backtest
-func (cframe *CandleFrame) optimizeEma(
-	lowShort, highShort, lowLong, highLong int) (bestPerformance float64, bestShort, bestLong int) {
-	logrus.Infof("Ema backtest start: paramas -> %v, %v, %v %v", lowShort, highShort, lowLong, highLong)
-
-	profit := 0.0
-	bestShort = 7
-	bestLong = 14
-
-	for short := lowShort; short <= highShort; short++ {
-		for long := lowLong; long <= highLong; long++ {
-			signals := cframe.backtestEma(1, short, long, nil)
-			if signals == nil {
-				continue
-			}
-
-			profit = signals.Profit()
-			if bestPerformance < profit {
-				bestPerformance = profit
-				bestShort = short
-				bestLong = long
-			}
-		}
-	}
-
-	logrus.Infof("Ema backtest end: results -> %v, %v, %v", bestPerformance, bestShort, bestLong)
-	return bestPerformance, bestShort, bestLong
-}
-
-func (cframe *CandleFrame) backtestEma(startDay, short int, long int, lastSignal *indicator.EmaSignal) *indicator.EmaSignals {
-	candles := cframe.Candles
-	lenCandles := len(candles)
-
-	if short >= lenCandles || long >= lenCandles {
-		return nil
-	}
-
-	signals := indicator.EmaSignals{}
-	// using at SignalTest
-	if lastSignal != nil {
-		signals.EmaSignals = append(signals.EmaSignals, *lastSignal)
-	}
-
-	shortEma := talib.Ema(cframe.Closes(), short)
-	longEma := talib.Ema(cframe.Closes(), long)
-
-	for day := startDay; day < lenCandles; day++ {
-		if day < short || day < long {
-			continue
-		}
-
-		if shortEma[day-1] < longEma[day-1] && shortEma[day] >= longEma[day] {
-			signals.Buy(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-
-		if shortEma[day-1] > longEma[day-1] && shortEma[day] <= longEma[day] {
-			signals.Sell(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-	}
-
-	return &signals
-}
-
-func (cframe *CandleFrame) optimizeBB(
-	lowN, highN int, lowK, highK float64) (bestPerformance float64, bestN int, bestK float64) {
-	logrus.Infof("BB backtest start: paramas -> %v, %v, %v %v", lowN, highN, lowK, highK)
-
-	profit := 0.0
-	bestN = 20
-	bestK = 2.0
-
-	for n := lowN; n <= highN; n++ {
-		for k := lowK; k <= highK; k += 0.1 {
-			signals := cframe.backtestBB(1, n, k, nil)
-			if signals == nil {
-				continue
-			}
-			profit = signals.Profit()
-			if bestPerformance < profit {
-				bestPerformance = profit
-				bestN = n
-				bestK = k
-			}
-		}
-	}
-
-	logrus.Infof("BB backtest end: results -> %v, %v, %v", bestPerformance, bestN, bestK)
-	return bestPerformance, bestN, bestK
-}
-
-func (cframe *CandleFrame) backtestBB(startDay, N int, K float64, lastSignal *indicator.BBSignal) *indicator.BBSignals {
-	candles := cframe.Candles
-	lenCandles := len(candles)
-
-	if N >= lenCandles {
-		return nil
-	}
-
-	signals := indicator.BBSignals{}
-	// using at SignalTest
-	if lastSignal != nil {
-		signals.BBSignals = append(signals.BBSignals, *lastSignal)
-	}
-
-	upBand, _, lowBand := talib.BBands(cframe.Closes(), N, K, K, 0)
-
-	for day := startDay; day < lenCandles; day++ {
-		if day < N {
-			continue
-		}
-
-		if candles[day-1].Close < lowBand[day-1] && candles[day].Close >= lowBand[day] {
-			signals.Buy(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-
-		if candles[day-1].Close > upBand[day-1] && candles[day].Close <= upBand[day] {
-			signals.Sell(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-	}
-
-	return &signals
-}
-
-func (cframe *CandleFrame) optimizeMacd(
-	lowFast, highFast, lowSlow, highSlow, lowSignal, highSignal int) (bestPerformance float64, bestFast, bestSlow, bestSignal int) {
-	logrus.Infof("Macd backtest start: paramas -> %v, %v, %v %v, %v, %v", lowFast, highFast, lowSlow, highSlow, lowSignal, highSignal)
-
-	profit := 0.0
-	bestFast = 12
-	bestSlow = 26
-	bestSignal = 9
-
-	for fast := lowFast; fast <= highFast; fast++ {
-		for slow := lowSlow; slow <= highSlow; slow++ {
-			for signal := lowSignal; signal <= highSignal; signal++ {
-				signals := cframe.backtestMacd(1, fast, slow, signal, nil)
-				if signals == nil {
-					continue
-				}
-				profit = signals.Profit()
-				if bestPerformance < profit {
-					bestPerformance = profit
-					bestFast = fast
-					bestSlow = slow
-					bestSignal = signal
-				}
-
-			}
-		}
-	}
-
-	logrus.Infof("Macd backtest end: results -> %v, %v, %v %v", bestPerformance, bestFast, bestSlow, bestSignal)
-	return bestPerformance, bestFast, bestSlow, bestSignal
-}
-
-func (cframe *CandleFrame) backtestMacd(startDay, fast, slow, signal int, lastSignal *indicator.MacdSignal) *indicator.MacdSignals {
-	candles := cframe.Candles
-	lenCandles := len(candles)
-
-	if fast >= lenCandles || slow >= lenCandles || signal >= lenCandles {
-		return nil
-	}
-
-	signals := indicator.MacdSignals{}
-	// using at SignalTest
-	if lastSignal != nil {
-		signals.MacdSignals = append(signals.MacdSignals, *lastSignal)
-	}
-
-	macd, macdSignal, _ := talib.Macd(cframe.Closes(), fast, slow, signal)
-
-	for day := startDay; day < lenCandles; day++ {
-		if macd[day] < 0 && macdSignal[day] < 0 &&
-			macd[day-1] < macdSignal[day-1] &&
-			macd[day] >= macdSignal[day] {
-			signals.Buy(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-
-		if macd[day] > 0 && macdSignal[day] > 0 &&
-			macd[day-1] > macdSignal[day-1] &&
-			macd[day] <= macdSignal[day] {
-			signals.Sell(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-	}
-
-	return &signals
-}
-
-func (cframe *CandleFrame) optimizeRsi(
-	lowPeriod, highPeriod int,
-	lowBuyThread, highBuyThread, lowSellThread, highSellThread float64) (bestPerformance float64, bestPeriod int, bestBuyThread, bestSellThread float64) {
-	logrus.Infof("Rsi backtest start: paramas -> %v, %v, %v %v, %v, %v", lowPeriod, highPeriod, lowBuyThread, highBuyThread, lowSellThread, highSellThread)
-
-	profit := 0.0
-	bestPeriod = 14
-	bestBuyThread = 30.0
-	bestSellThread = 70.0
-
-	for peirod := lowPeriod; peirod <= highPeriod; peirod++ {
-		for buyThread := lowBuyThread; buyThread <= highBuyThread; buyThread++ {
-			for sellThread := lowSellThread; sellThread <= highSellThread; sellThread++ {
-				signals := cframe.backtestRsi(1, peirod, buyThread, sellThread, nil)
-				if signals == nil {
-					continue
-				}
-				profit = signals.Profit()
-				if bestPerformance < profit {
-					bestPerformance = profit
-					bestPeriod = peirod
-					bestBuyThread = buyThread
-					bestSellThread = sellThread
-				}
-			}
-		}
-	}
-
-	logrus.Infof("Rsi backtest end: results -> %v, %v, %v %v", bestPerformance, bestPeriod, bestBuyThread, bestSellThread)
-	return bestPerformance, bestPeriod, bestBuyThread, bestSellThread
-}
-
-func (cframe *CandleFrame) backtestRsi(startDay, period int, buyThread, sellThread float64, lastSignal *indicator.RsiSignal) *indicator.RsiSignals {
-	candles := cframe.Candles
-	lenCandles := len(candles)
-
-	if period >= lenCandles {
-		return nil
-	}
-
-	signals := indicator.RsiSignals{}
-	// using at SignalTest
-	if lastSignal != nil {
-		signals.RsiSignals = append(signals.RsiSignals, *lastSignal)
-	}
-
-	rsi := talib.Rsi(cframe.Closes(), period)
-
-	for day := startDay; day < lenCandles; day++ {
-		if rsi[day-1] == 0 || rsi[day-1] == 100 {
-			continue
-		}
-
-		if rsi[day-1] < buyThread && rsi[day] >= buyThread {
-			signals.Buy(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-
-		if rsi[day-1] > sellThread && rsi[day] <= sellThread {
-			signals.Sell(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-	}
-
-	return &signals
-}
-
-func (cframe *CandleFrame) optimizeWillr(
-	lowPeriod, highPeriod int,
-	lowBuyThread, highBuyThread, lowSellThread, highSellThread float64) (bestPerformance float64, bestPeriod int, bestBuyThread, bestSellThread float64) {
-	logrus.Infof("Willr backtest start: paramas -> %v, %v, %v %v, %v, %v", lowPeriod, highPeriod, lowBuyThread, highBuyThread, lowSellThread, highSellThread)
-
-	profit := 0.0
-	bestPeriod = 10
-	bestBuyThread = -20.0
-	bestSellThread = -80.0
-
-	for period := lowPeriod; period <= highPeriod; period++ {
-		for buyThread := lowBuyThread; buyThread <= highBuyThread; buyThread++ {
-			for sellThread := lowSellThread; sellThread <= highSellThread; sellThread++ {
-				signals := cframe.backtestWillr(1, period, buyThread, sellThread, nil)
-				if signals == nil {
-					continue
-				}
-				profit = signals.Profit()
-				if bestPerformance < profit {
-					bestPerformance = profit
-					bestPeriod = period
-					bestBuyThread = buyThread
-					bestSellThread = sellThread
-				}
-			}
-		}
-	}
-
-	logrus.Infof("Willr backtest end: results -> %v, %v, %v %v", bestPerformance, bestPeriod, bestBuyThread, bestSellThread)
-	return bestPerformance, bestPeriod, bestBuyThread, bestSellThread
-}
-
-func (cframe *CandleFrame) backtestWillr(startDay, period int, buyThread, sellThread float64, lastSignal *indicator.WillrSignal) *indicator.WillrSignals {
-	candles := cframe.Candles
-	lenCandles := len(candles)
-
-	if period >= lenCandles {
-		return nil
-	}
-
-	signals := indicator.WillrSignals{}
-	// using at SignalTest
-	if lastSignal != nil {
-		signals.WillrSignals = append(signals.WillrSignals, *lastSignal)
+// Bars returns the candles as the prices strategies generate their signals from
+func (cframe *CandleFrame) Bars() *indicator.Candles {
+	times := make([]int64, len(cframe.Candles))
+	for i, candle := range cframe.Candles {
+		times[i] = candle.Time
 	}
-
-	willr := talib.WillR(cframe.Highs(), cframe.Lows(), cframe.Closes(), period)
-
-	for day := startDay; day < lenCandles; day++ {
-		if willr[day-1] == 0 || willr[day-1] == -100 {
-			continue
-		}
-
-		if willr[day-1] < buyThread && willr[day] >= buyThread {
-			signals.Buy(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
-
-		if willr[day-1] > sellThread && willr[day] <= sellThread {
-			signals.Sell(cframe.Symbol, candles[day].Time, candles[day].Close)
-		}
+	return &indicator.Candles{
+		Time:   times,
+		Open:   cframe.Opens(),
+		High:   cframe.Highs(),
+		Low:    cframe.Lows(),
+		Close:  cframe.Closes(),
+		Volume: cframe.Volumes(),
 	}
-
-	return &signals
 }
@@ -1,7 +1,13 @@
 package models_test
 
 import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
 	"github.com/oarkflow/nepse/app/models"
+	"github.com/oarkflow/nepse/app/models/indicator"
 )
 
 func (suite *ModelsTestSuite) TestDataFrame() {
@@ -18,12 +24,12 @@ func (suite *ModelsTestSuite) TestDataFrame() {
 	suite.Equal("VOO", dframe.CandleFrame.Symbol)
 	suite.Len(dframe.CandleFrame.Candles, 100)
 
-	dframe.AddSignalFrame("VOO", true, false, false, false, false)
-	suite.NotEmpty(dframe.SignalFrame.Signals.EmaSignals)
-	suite.Empty(dframe.SignalFrame.Signals.BBSignals)
-	suite.Empty(dframe.SignalFrame.Signals.MacdSignals)
-	suite.Empty(dframe.SignalFrame.Signals.RsiSignals)
-	suite.Empty(dframe.SignalFrame.Signals.WillrSignals)
+	dframe.AddSignalFrame("VOO", "ema")
+	suite.NotEmpty(dframe.SignalFrame.Signals["ema"])
+	suite.Empty(dframe.SignalFrame.Signals["bb"])
+	suite.Empty(dframe.SignalFrame.Signals["macd"])
+	suite.Empty(dframe.SignalFrame.Signals["rsi"])
+	suite.Empty(dframe.SignalFrame.Signals["willr"])
 
 	dframe.AddOptimizedParamFrame("DAMY")
 	suite.Nil(dframe.OptimizedParamFrame.Param)
@@ -33,3 +39,18 @@ func (suite *ModelsTestSuite) TestDataFrame() {
 	dframe.AddTradeFrame("VOO")
 	suite.NotEmpty(dframe.TradeFrame.Trade)
 }
+
+func TestDataFrameBSDates(t *testing.T) {
+	assert := assert.New(t)
+	day := time.Date(2024, 8, 6, 0, 0, 0, 0, time.UTC).Unix() * 1000
+	later := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC).Unix() * 1000
+	dframe := models.NewDataFrame()
+	dframe.CandleFrame = &models.CandleFrame{Symbol: "NABIL", Candles: []models.Candle{{Time: day}, {Time: later}}}
+	dframe.SignalFrame = &models.SignalFrame{Signals: models.SignalEvents{
+		"ema": {{Time: day, Action: indicator.BUY}},
+	}}
+	dframe.AddBSDates()
+	assert.Equal("2081-04-22", dframe.Candles[0].BSDate)
+	assert.Equal("2083-07-02", dframe.Candles[1].BSDate)
+	assert.Equal("2081-04-22", dframe.Signals["ema"][0].BSDate)
+}
@@ -1,127 +1,70 @@
 package models
 
 import (
-	"reflect"
+	"sort"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/oarkflow/nepse/app/models/indicator"
 )
 
-// Trade represents whether today is "buy" or "sell" or "no trade"
+// Trade represents whether the last signal of a strategy is "buy" or "sell" or "no trade",
+// and whether it is today
 type Trade struct {
-	LastEmaTrade   string `json:"last_ema"`
-	IsEmaToday     bool   `json:"today_ema"`
-	LastBBTrade    string `json:"last_bb"`
-	IsBBToday      bool   `json:"today_bb"`
-	LastMacdTrade  string `json:"last_macd"`
-	IsMacdToday    bool   `json:"today_macd"`
-	LastRsiTrade   string `json:"last_rsi"`
-	IsRsiToday     bool   `json:"today_rsi"`
-	LastWillrTrade string `json:"last_willr"`
-	IsWillrToday   bool   `json:"today_willr"`
+	Strategy  string `json:"strategy"`
+	Name      string `json:"name"`
+	LastTrade string `json:"last"`
+	IsToday   bool   `json:"today"`
 }
 
-// GetTradeState returns Trade, after examining today trading,
+// GetTradeState returns the Trade of every strategy, after examining today trading,
 // the symbol argument is certainly the same to the candle symbol
 func GetTradeState(symbol string) *TradeFrame {
-	signalEvents := GetSignalFrame(symbol, true, true, true, true, true).Signals
-	lastCandleTime, err := LastCandleTime()
+	lastCandleTime, err := LastCandleTime(symbol)
 	if err != nil {
 		logrus.Warnf("last candle get error: %v", err)
 		return &TradeFrame{Trade: nil}
 	}
 
-	trade := Trade{
-		LastEmaTrade:   indicator.NOTRADE,
-		IsEmaToday:     false,
-		LastBBTrade:    indicator.NOTRADE,
-		IsBBToday:      false,
-		LastMacdTrade:  indicator.NOTRADE,
-		IsMacdToday:    false,
-		LastRsiTrade:   indicator.NOTRADE,
-		IsRsiToday:     false,
-		LastWillrTrade: indicator.NOTRADE,
-		IsWillrToday:   false,
+	strategies := indicator.Strategies()
+	names := make([]string, len(strategies))
+	for i, strategy := range strategies {
+		names[i] = strategy.Name
 	}
-
-	for signal, time := range signalEvents.LastSignalTimes() {
-		// no signal
-		if time == 0 {
-			continue
-		}
-		switch signal {
-		case "emaTime":
-			lastEma := signalEvents.EmaSignals[len(signalEvents.EmaSignals)-1]
-			trade.LastEmaTrade = lastEma.Action
-			trade.IsEmaToday = (lastEma.Time == lastCandleTime)
-		case "bbTime":
-			lastBB := signalEvents.BBSignals[len(signalEvents.BBSignals)-1]
-			trade.LastBBTrade = lastBB.Action
-			trade.IsBBToday = (lastBB.Time == lastCandleTime)
-		case "macdTime":
-			lastMacd := signalEvents.MacdSignals[len(signalEvents.MacdSignals)-1]
-			trade.LastMacdTrade = lastMacd.Action
-			trade.IsMacdToday = (lastMacd.Time == lastCandleTime)
-		case "rsiTime":
-			lastRsi := signalEvents.RsiSignals[len(signalEvents.RsiSignals)-1]
-			trade.LastRsiTrade = lastRsi.Action
-			trade.IsRsiToday = (lastRsi.Time == lastCandleTime)
-		case "willrTime":
-			lastWillr := signalEvents.WillrSignals[len(signalEvents.WillrSignals)-1]
-			trade.LastWillrTrade = lastWillr.Action
-			trade.IsWillrToday = (lastWillr.Time == lastCandleTime)
+	signalEvents := GetSignalFrame(symbol, names...).Signals
+
+	trades := make([]Trade, 0, len(strategies))
+	for _, strategy := range strategies {
+		trade := Trade{Strategy: strategy.Name, Name: strategy.DisplayName, LastTrade: indicator.NOTRADE}
+		if signals := signalEvents[strategy.Name]; len(signals) != 0 {
+			last := signals[len(signals)-1]
+			trade.LastTrade = last.Action
+			trade.IsToday = (last.Time == lastCandleTime)
 		}
+		trades = append(trades, trade)
 	}
 
-	return &TradeFrame{Trade: &trade}
+	return &TradeFrame{Trade: trades}
 }
 
-// SignalEvents stores a part of signal
-type SignalEvents struct {
-	EmaSignals   []indicator.EmaSignal   `json:"ema_signals,omitempty"`
-	BBSignals    []indicator.BBSignal    `json:"bb_signals,omitempty"`
-	MacdSignals  []indicator.MacdSignal  `json:"macd_signals,omitempty"`
-	RsiSignals   []indicator.RsiSignal   `json:"rsi_signals,omitempty"`
-	WillrSignals []indicator.WillrSignal `json:"willr_signals,omitempty"`
-}
+// SignalEvents stores the signals of each strategy by name, oldest first
+type SignalEvents map[string][]indicator.Signal
 
-// GetSignalFrame returns SignalFrame including a part of signal events
-func GetSignalFrame(symbol string, ema, bb, macd, rsi, willr bool) *SignalFrame {
-	if !(ema || bb || macd || rsi || willr) {
+// GetSignalFrame returns SignalFrame including the signal events of strategies
+func GetSignalFrame(symbol string, strategies ...string) *SignalFrame {
+	if len(strategies) == 0 {
 		return &SignalFrame{Signals: nil}
 	}
 
-	signalEvents := &SignalEvents{}
+	var signals []indicator.Signal
+	DB.Where("symbol = ? AND strategy IN ?", symbol, strategies).Order("time, id").Find(&signals)
 
-	if ema {
-		emaSignals := []indicator.EmaSignal{}
-		DB.Where("Symbol = ?", symbol).Find(&emaSignals)
-		signalEvents.EmaSignals = emaSignals
+	signalEvents := make(SignalEvents, len(strategies))
+	for _, strategy := range strategies {
+		signalEvents[strategy] = []indicator.Signal{}
 	}
-
-	if bb {
-		bbSignals := []indicator.BBSignal{}
-		DB.Where("Symbol = ?", symbol).Find(&bbSignals)
-		signalEvents.BBSignals = bbSignals
-	}
-
-	if macd {
-		macdSignals := []indicator.MacdSignal{}
-		DB.Where("Symbol = ?", symbol).Find(&macdSignals)
-		signalEvents.MacdSignals = macdSignals
-	}
-
-	if rsi {
-		rsiSignals := []indicator.RsiSignal{}
-		DB.Where("Symbol = ?", symbol).Find(&rsiSignals)
-		signalEvents.RsiSignals = rsiSignals
-	}
-
-	if willr {
-		willrSignals := []indicator.WillrSignal{}
-		DB.Where("Symbol = ?", symbol).Find(&willrSignals)
-		signalEvents.WillrSignals = willrSignals
+	for _, signal := range signals {
+		signalEvents[signal.Strategy] = append(signalEvents[signal.Strategy], signal)
 	}
 
 	return &SignalFrame{Signals: signalEvents}
@@ -135,64 +78,45 @@ func SignalTest(symbol string, period int) bool {
 	}
 
 	cframe := GetCandleFrame(symbol, period)
-	signalEvents := GetSignalFrame(symbol, true, true, true, true, true).Signals
+	candles := cframe.Bars()
+	names := make([]string, len(opParam.Results))
+	for i, result := range opParam.Results {
+		names[i] = result.Strategy
+	}
+	signalEvents := GetSignalFrame(symbol, names...).Signals
 
-	firstTime := cframe.Candles[0].ID
-	for k, v := range signalEvents.LastSignalTimes() {
-		machID, err := MatchTime(v)
-		if err != nil {
+	for _, result := range opParam.Results {
+		strategy, ok := indicator.Lookup(result.Strategy)
+		signals := signalEvents[result.Strategy]
+		if !ok || len(signals) == 0 {
 			continue
 		}
+		lastSignal := signals[len(signals)-1]
 
-		startDay := machID - firstTime + 1
-		switch k {
-		case "emaTime":
-			emaSignals := cframe.backtestEma(
-				startDay, opParam.EmaShort, opParam.EmaLong, &signalEvents.EmaSignals[len(signalEvents.EmaSignals)-1]).EmaSignals
-			DB.Model(opParam).Association("EmaSignals").Append(emaSignals)
-		case "bbTime":
-			bbSignals := cframe.backtestBB(
-				startDay, opParam.BBn, opParam.BBk, &signalEvents.BBSignals[len(signalEvents.BBSignals)-1]).BBSignals
-			DB.Model(opParam).Association("BBSignals").Append(bbSignals)
-		case "macdTime":
-			macdSignals := cframe.backtestMacd(
-				startDay, opParam.MacdFast, opParam.MacdSlow, opParam.MacdSignal, &signalEvents.MacdSignals[len(signalEvents.MacdSignals)-1]).MacdSignals
-			DB.Model(opParam).Association("MacdSignals").Append(macdSignals)
-		case "rsiTime":
-			rsiSignals := cframe.backtestRsi(
-				startDay, opParam.RsiPeriod, opParam.RsiBuyThread, opParam.RsiSellThread, &signalEvents.RsiSignals[len(signalEvents.RsiSignals)-1]).RsiSignals
-			DB.Model(opParam).Association("RsiSignals").Append(rsiSignals)
-		case "willrTime":
-			willrSignals := cframe.backtestWillr(
-				startDay, opParam.WillrPeriod, opParam.WillrBuyThread, opParam.WillrSellThread, &signalEvents.WillrSignals[len(signalEvents.WillrSignals)-1]).WillrSignals
-			DB.Model(opParam).Association("WillrSignals").Append(willrSignals)
+		// candle of the last signal, the backtest restarts from it
+		i := sort.Search(len(cframe.Candles), func(i int) bool { return cframe.Candles[i].Time >= lastSignal.Time })
+		if i == len(cframe.Candles) || cframe.Candles[i].Time != lastSignal.Time {
+			continue
+		}
 
+		updated := strategy.Backtest(symbol, candles, result.Params, i+1, &lastSignal)
+		// the first signal is the last one, already stored
+		if updated != nil && len(updated.Signals) > 1 {
+			DB.Create(updated.Signals[1:])
 		}
 	}
 
 	return true
 }
 
-// LastSignalTimes returns a slice including Time for a last element of Signals
-func (sg *SignalEvents) LastSignalTimes() map[string]int64 {
-	lastTimes := []int64{}
-
-	rv := reflect.ValueOf(*sg)
-	for i := 0; i < rv.NumField(); i++ {
-		signals := rv.Field(i)
-		if signals.Len() != 0 {
-			lastTimes = append(lastTimes, signals.Index(signals.Len()-1).FieldByName("Time").Int())
-		} else {
-			lastTimes = append(lastTimes, 0)
+// LastSignalTimes returns the Time of the last signal of each strategy, 0 when it has none
+func (sg SignalEvents) LastSignalTimes() map[string]int64 {
+	lastTimes := make(map[string]int64, len(sg))
+	for strategy, signals := range sg {
+		lastTimes[strategy] = 0
+		if len(signals) != 0 {
+			lastTimes[strategy] = signals[len(signals)-1].Time
 		}
 	}
-
-	return map[string]int64{
-		"emaTime":   lastTimes[0],
-		"bbTime":    lastTimes[1],
-		"macdTime":  lastTimes[2],
-		"rsiTime":   lastTimes[3],
-		"willrTime": lastTimes[4],
-	}
-
+	return lastTimes
 }
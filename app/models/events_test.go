@@ -8,27 +8,33 @@ import (
 func (suite *ModelsTestSuite) TestGetTradeState() {
 	// initializing
 	suite.Op.CreateBacktestResult()
+	models.DB.Delete(indicator.Signal{}, "symbol = ? AND strategy = ?", "VOO", "ema")
 
 	// As test, create Ema signal due to doing same time to last candle time
-	lastTime, _ := models.LastCandleTime()
-	emaSignal := indicator.EmaSignal{
-		Symbol: "VOO",
-		Time:   lastTime,
-		Price:  100,
-		Action: indicator.BUY,
+	lastTime, _ := models.LastCandleTime("VOO")
+	emaSignal := indicator.Signal{
+		Symbol:   "VOO",
+		Strategy: "ema",
+		Time:     lastTime,
+		Price:    100,
+		Action:   indicator.BUY,
 	}
 	models.DB.Create(&emaSignal)
 
 	// following, start test
 	tradeFrame := models.GetTradeState("VOO")
-	suite.Equal("BUY", tradeFrame.Trade.LastEmaTrade)
-	suite.True(tradeFrame.Trade.IsEmaToday)
+	suite.Len(tradeFrame.Trade, len(indicator.Strategies()))
+	trade, ok := tradeFrame.Strategy("ema")
+	suite.True(ok)
+	suite.Equal("EMA", trade.Name)
+	suite.Equal("BUY", trade.LastTrade)
+	suite.True(trade.IsToday)
 
 	// Delete Ema Signals
-	models.DB.Delete(indicator.EmaSignal{}, "Symbol LIKE ?", "%VOO%")
-	tradeFrame = models.GetTradeState("VOO")
-	suite.Equal(indicator.NOTRADE, tradeFrame.Trade.LastEmaTrade)
-	suite.False(tradeFrame.Trade.IsEmaToday)
+	models.DB.Delete(indicator.Signal{}, "symbol = ? AND strategy = ?", "VOO", "ema")
+	trade, _ = models.GetTradeState("VOO").Strategy("ema")
+	suite.Equal(indicator.NOTRADE, trade.LastTrade)
+	suite.False(trade.IsToday)
 
 	models.DeleteBacktestResult("VOO")
 }
@@ -37,20 +43,25 @@ func (suite *ModelsTestSuite) TestGetSignalFrame() {
 	// initializing
 	suite.Op.CreateBacktestResult()
 
-	signalFrame := models.GetSignalFrame("VOO", false, false, false, false, false)
+	signalFrame := models.GetSignalFrame("VOO")
 	suite.Nil(signalFrame.Signals)
 
-	signalFrame = models.GetSignalFrame("VOO", true, false, false, false, false)
+	signalFrame = models.GetSignalFrame("VOO", "ema")
 	suite.NotNil(signalFrame.Signals)
-	suite.NotEmpty(signalFrame.Signals.EmaSignals)
+	suite.NotEmpty(signalFrame.Signals["ema"])
+	suite.Len(signalFrame.Signals, 1)
 
-	signalFrame = models.GetSignalFrame("VOO", true, true, true, true, true)
+	signalFrame = models.GetSignalFrame("VOO", "ema", "bb", "macd", "rsi", "willr")
 	suite.NotNil(signalFrame.Signals)
-	suite.NotEmpty(signalFrame.Signals.EmaSignals)
-	suite.NotEmpty(signalFrame.Signals.BBSignals)
-	suite.NotEmpty(signalFrame.Signals.MacdSignals)
-	suite.NotEmpty(signalFrame.Signals.RsiSignals)
-	suite.NotEmpty(signalFrame.Signals.WillrSignals)
+	for _, strategy := range []string{"ema", "bb", "macd", "rsi", "willr"} {
+		signals := signalFrame.Signals[strategy]
+		suite.NotEmpty(signals, strategy)
+		suite.Equal(strategy, signals[0].Strategy)
+		suite.Equal(suite.Op.Result(strategy).ParamsHash, signals[0].ParamsHash)
+		for i := 1; i < len(signals); i++ {
+			suite.LessOrEqual(signals[i-1].Time, signals[i].Time)
+		}
+	}
 
 	models.DeleteBacktestResult("VOO")
 }
@@ -64,48 +75,24 @@ func (suite *ModelsTestSuite) TestSignalTest() {
 
 	suite.True(models.SignalTest("VOO", 500))
 
-	trades := models.GetTradeState("VOO").Trade
-	signals := models.GetSignalFrame("VOO", true, true, true, true, true).Signals
+	tradeFrame := models.GetTradeState("VOO")
+	signals := models.GetSignalFrame("VOO", "ema", "bb", "macd", "rsi", "willr").Signals
 	signalsLastTime := signals.LastSignalTimes()
-	candleLastTime, _ := models.LastCandleTime()
-	if len(signals.EmaSignals) != 0 {
-		suite.Equal(signals.EmaSignals[len(signals.EmaSignals)-1].Action, trades.LastEmaTrade)
-		suite.Equal(signalsLastTime["emaTime"] == candleLastTime, trades.IsEmaToday)
-	} else {
-		suite.Equal(indicator.NOTRADE, trades.LastEmaTrade)
-		suite.False(trades.IsEmaToday)
-	}
-
-	if len(signals.BBSignals) != 0 {
-		suite.Equal(signals.BBSignals[len(signals.BBSignals)-1].Action, trades.LastBBTrade)
-		suite.Equal(signalsLastTime["bbTime"] == candleLastTime, trades.IsBBToday)
-	} else {
-		suite.Equal(indicator.NOTRADE, trades.LastBBTrade)
-		suite.False(trades.IsBBToday)
-	}
-
-	if len(signals.MacdSignals) != 0 {
-		suite.Equal(signals.MacdSignals[len(signals.MacdSignals)-1].Action, trades.LastMacdTrade)
-		suite.Equal(signalsLastTime["macdTime"] == candleLastTime, trades.IsMacdToday)
-	} else {
-		suite.Equal(indicator.NOTRADE, trades.LastMacdTrade)
-		suite.False(trades.IsMacdToday)
-	}
-
-	if len(signals.RsiSignals) != 0 {
-		suite.Equal(signals.RsiSignals[len(signals.RsiSignals)-1].Action, trades.LastRsiTrade)
-		suite.Equal(signalsLastTime["rsiTime"] == candleLastTime, trades.IsRsiToday)
-	} else {
-		suite.Equal(indicator.NOTRADE, trades.LastRsiTrade)
-		suite.False(trades.IsRsiToday)
-	}
-
-	if len(signals.WillrSignals) != 0 {
-		suite.Equal(signals.WillrSignals[len(signals.WillrSignals)-1].Action, trades.LastWillrTrade)
-		suite.Equal(signalsLastTime["willrTime"] == candleLastTime, trades.IsWillrToday)
-	} else {
-		suite.Equal(indicator.NOTRADE, trades.LastWillrTrade)
-		suite.False(trades.IsWillrToday)
+	candleLastTime, _ := models.LastCandleTime("VOO")
+	for _, strategy := range []string{"ema", "bb", "macd", "rsi", "willr"} {
+		trade, ok := tradeFrame.Strategy(strategy)
+		suite.True(ok)
+		if len(signals[strategy]) != 0 {
+			suite.Equal(signals[strategy][len(signals[strategy])-1].Action, trade.LastTrade)
+			suite.Equal(signalsLastTime[strategy] == candleLastTime, trade.IsToday)
+		} else {
+			suite.Equal(indicator.NOTRADE, trade.LastTrade)
+			suite.False(trade.IsToday)
+		}
+		// signals are not stored twice
+		for i := 1; i < len(signals[strategy]); i++ {
+			suite.Less(signals[strategy][i-1].Time, signals[strategy][i].Time)
+		}
 	}
 
 	models.DeleteBacktestResult("VOO")
@@ -115,14 +102,14 @@ func (suite *ModelsTestSuite) TestLastSignalTimes() {
 	// initializing
 	suite.Op.CreateBacktestResult()
 
-	signalEvents := models.GetSignalFrame("VOO", true, true, true, true, true).Signals
+	signalEvents := models.GetSignalFrame("VOO", "ema", "bb", "macd", "rsi", "willr").Signals
 	lastTimeMap := signalEvents.LastSignalTimes()
 
-	suite.Equal(signalEvents.EmaSignals[len(signalEvents.EmaSignals)-1].Time, lastTimeMap["emaTime"])
-	suite.Equal(signalEvents.BBSignals[len(signalEvents.BBSignals)-1].Time, lastTimeMap["bbTime"])
-	suite.Equal(signalEvents.MacdSignals[len(signalEvents.MacdSignals)-1].Time, lastTimeMap["macdTime"])
-	suite.Equal(signalEvents.RsiSignals[len(signalEvents.RsiSignals)-1].Time, lastTimeMap["rsiTime"])
-	suite.Equal(signalEvents.WillrSignals[len(signalEvents.WillrSignals)-1].Time, lastTimeMap["willrTime"])
+	suite.Len(lastTimeMap, 5)
+	for strategy, signals := range signalEvents {
+		suite.Equal(signals[len(signals)-1].Time, lastTimeMap[strategy])
+	}
+	suite.Equal(int64(0), models.SignalEvents{"ema": nil}.LastSignalTimes()["ema"])
 
 	models.DeleteBacktestResult("VOO")
 }
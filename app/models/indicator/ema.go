@@ -1,95 +1,42 @@
 package indicator
 
-// EmaBacktestParam represents some parameters used for backtest
-type EmaBacktestParam struct {
-	EmaShortLow  int `json:"short_low"`
-	EmaShortHigh int `json:"short_high"`
-	EmaLongLow   int `json:"long_low"`
-	EmaLongHigh  int `json:"long_high"`
-}
-
-// EmaSignals stores EmaSignal
-type EmaSignals struct {
-	EmaSignals []EmaSignal
-}
+import "github.com/markcheno/go-talib"
 
-// EmaSignal is signal results of backtest
-type EmaSignal struct {
-	ID     int     `gorm:"primary_key" json:"-"`
-	Symbol string  `json:"-"`
-	Time   int64   `json:"time"`
-	Price  float64 `json:"-"`
-	Action string  `json:"action"`
+// EMA buys when the short EMA crosses above the long EMA and sells when it crosses below
+var EMA = &Strategy{
+	Name:        "ema",
+	DisplayName: "EMA",
+	Params: []Param{
+		{Name: "short", Default: 7, Step: 1},
+		{Name: "long", Default: 14, Step: 1},
+	},
+	Generate: generateEma,
 }
 
-// Buy appends buy-signal to Signals, if can not buy, return false
-func (ema *EmaSignals) Buy(symbol string, time int64, price float64) bool {
-	if !(ema.CanBuy()) {
-		return false
+func generateEma(c *Candles, p Params) []string {
+	short, long := p.Int("short"), p.Int("long")
+	lenCandles := c.Len()
+	if short >= lenCandles || long >= lenCandles {
+		return nil
 	}
-	ema.EmaSignals = append(ema.EmaSignals, EmaSignal{Symbol: symbol, Time: time, Price: price, Action: BUY})
-	return true
-}
 
-// CanBuy judges whether buy or not
-func (ema *EmaSignals) CanBuy() bool {
-	lenSignals := len(ema.EmaSignals)
-	// not buy or sell
-	if lenSignals == 0 {
-		return true
-	}
+	actions := make([]string, lenCandles)
+	shortEma := talib.Ema(c.Close, short)
+	longEma := talib.Ema(c.Close, long)
 
-	if ema.EmaSignals[lenSignals-1].Action == SELL {
-		return true
-	}
-
-	return false
-}
-
-// Sell appends sell-signal to Signals, if can not sell, return false
-func (ema *EmaSignals) Sell(symbol string, time int64, price float64) bool {
-	if !(ema.CanSell()) {
-		return false
-	}
-	ema.EmaSignals = append(ema.EmaSignals, EmaSignal{Symbol: symbol, Time: time, Price: price, Action: SELL})
-	return true
-}
-
-// CanSell judges whether sell or not
-func (ema *EmaSignals) CanSell() bool {
-	lenSignals := len(ema.EmaSignals)
-	// not buy or sell
-	if lenSignals == 0 {
-		return false
-	}
-
-	if ema.EmaSignals[lenSignals-1].Action == BUY {
-		return true
-	}
-
-	return false
-}
-
-// Profit calculates profit for backtest
-func (ema *EmaSignals) Profit() float64 {
-	profit := 0.0
-	afterSell := 0.0
-	isHolding := false
+	for day := 1; day < lenCandles; day++ {
+		if day < short || day < long {
+			continue
+		}
 
-	for _, signal := range ema.EmaSignals {
-		if signal.Action == BUY {
-			profit -= signal.Price
-			isHolding = true
-		} else if signal.Action == SELL {
-			profit += signal.Price
-			afterSell = profit
-			isHolding = false
+		if shortEma[day-1] < longEma[day-1] && shortEma[day] >= longEma[day] {
+			actions[day] = BUY
 		}
-	}
 
-	if isHolding {
-		return afterSell
+		if shortEma[day-1] > longEma[day-1] && shortEma[day] <= longEma[day] {
+			actions[day] = SELL
+		}
 	}
 
-	return profit
+	return actions
 }
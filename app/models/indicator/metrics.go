@@ -0,0 +1,198 @@
+package indicator
+
+import (
+	"math"
+	"time"
+
+	"github.com/oarkflow/nepse/fees"
+)
+
+// Metrics are the performance of the trades of a backtest, measured on its equity curve
+type Metrics struct {
+	// Capital is the equity before the first candle
+	Capital float64 `json:"capital"`
+	// TotalReturn and CAGR are the return of the last equity over Capital and its compound annual rate, in percent
+	TotalReturn float64 `json:"total_return"`
+	CAGR        float64 `json:"cagr"`
+	// MaxDrawdown is the largest fall of the equity from a peak, in percent,
+	// MaxDrawdownDays the calendar days from that peak until the equity got back to it or the last candle
+	MaxDrawdown     float64 `json:"max_drawdown"`
+	MaxDrawdownDays int     `json:"max_drawdown_days"`
+	// Sharpe and Sortino are the annualized ratios of the returns of every candle, without a risk-free rate,
+	// Calmar is CAGR over MaxDrawdown
+	Sharpe  float64 `json:"sharpe"`
+	Sortino float64 `json:"sortino"`
+	Calmar  float64 `json:"calmar"`
+	// WinRate is the percent of trades with a net profit
+	WinRate float64 `json:"win_rate"`
+	// ProfitFactor is the net profit of the winning trades over the net loss of the losing ones, 0 without losing trades
+	ProfitFactor float64 `json:"profit_factor"`
+	// AverageWin and AverageLoss are the mean net profit of the winning and losing trades, AverageLoss negative
+	AverageWin  float64 `json:"average_win"`
+	AverageLoss float64 `json:"average_loss"`
+	// Expectancy is the mean net profit of a trade
+	Expectancy float64 `json:"expectancy"`
+	// Exposure is the percent of candles holding shares
+	Exposure           float64 `json:"exposure"`
+	TradeCount         int     `json:"trade_count"`
+	AverageHoldingDays float64 `json:"average_holding_days"`
+}
+
+// Equity returns the equity curve of trades on c starting at capital, the realized net profit of the trades sold
+// and the shares held marked to the close of every candle
+func Equity(c *Candles, trades []fees.Trade, capital float64) []EquityPoint {
+	equity := make([]EquityPoint, c.Len())
+	realized, next := capital, 0
+	for i, t := range c.Time {
+		for ; next < len(trades) && trades[next].SellTime.UnixMilli() <= t; next++ {
+			realized += trades[next].Net
+		}
+		value := realized
+		if next < len(trades) && trades[next].BuyTime.UnixMilli() <= t {
+			value += trades[next].Shares * (c.Close[i] - trades[next].BuyPrice)
+		}
+		equity[i] = EquityPoint{Time: t, Equity: value}
+	}
+	return equity
+}
+
+// Measure returns the metrics of trades on c, the equity starting at capital.
+// A capital of 0 is the price of the shares of the trades at the highest close, enough to buy them on any candle.
+func Measure(c *Candles, trades []fees.Trade, capital float64) Metrics {
+	m := Metrics{Capital: capital, TradeCount: len(trades)}
+	if capital == 0 && len(trades) > 0 {
+		for _, price := range c.Close {
+			m.Capital = math.Max(m.Capital, trades[0].Shares*price)
+		}
+	}
+
+	var wins, losses, holding float64
+	winners, losers := 0, 0
+	for _, t := range trades {
+		switch {
+		case t.Net > 0:
+			wins += t.Net
+			winners++
+		case t.Net < 0:
+			losses += t.Net
+			losers++
+		}
+		m.Expectancy += t.Net
+		holding += float64(t.HoldingDays)
+	}
+	if len(trades) > 0 {
+		m.WinRate = float64(winners) / float64(len(trades)) * 100
+		m.Expectancy /= float64(len(trades))
+		m.AverageHoldingDays = holding / float64(len(trades))
+	}
+	if winners > 0 {
+		m.AverageWin = wins / float64(winners)
+	}
+	if losers > 0 {
+		m.AverageLoss = losses / float64(losers)
+		m.ProfitFactor = wins / -losses
+	}
+
+	if c.Len() == 0 || m.Capital <= 0 {
+		return m
+	}
+	equity := Equity(c, trades, m.Capital)
+
+	// exposure, the candles from a buy until before the sell
+	held, next := 0, 0
+	for _, t := range c.Time {
+		for ; next < len(trades) && trades[next].SellTime.UnixMilli() <= t; next++ {
+		}
+		if next < len(trades) && trades[next].BuyTime.UnixMilli() <= t {
+			held++
+		}
+	}
+	m.Exposure = float64(held) / float64(c.Len()) * 100
+
+	last := equity[len(equity)-1].Equity
+	m.TotalReturn = (last/m.Capital - 1) * 100
+	span := time.Duration(c.Time[c.Len()-1]-c.Time[0]) * time.Millisecond
+	if y := span.Hours() / 24 / 365.25; y > 0 && last > 0 {
+		m.CAGR = finite((math.Pow(last/m.Capital, 1/y) - 1) * 100)
+	}
+
+	// the drawdowns from the capital or the highest equity before
+	peak, peakTime := m.Capital, c.Time[0]
+	for i, p := range equity {
+		if p.Equity >= peak {
+			if p.Equity > peak {
+				peak, peakTime = p.Equity, p.Time
+			}
+			continue
+		}
+		if drawdown := (peak - p.Equity) / peak * 100; drawdown > m.MaxDrawdown {
+			m.MaxDrawdown = drawdown
+			// until the equity gets back to the peak
+			end := equity[len(equity)-1].Time
+			for _, q := range equity[i:] {
+				if q.Equity >= peak {
+					end = q.Time
+					break
+				}
+			}
+			m.MaxDrawdownDays = int(time.Duration(end-peakTime) * time.Millisecond / (24 * time.Hour))
+		}
+	}
+	if m.MaxDrawdown > 0 {
+		m.Calmar = finite(m.CAGR / m.MaxDrawdown)
+	}
+
+	m.Sharpe, m.Sortino = ratios(c, equity, m.Capital)
+	return m
+}
+
+// ratios returns the Sharpe and Sortino ratios of the returns of equity on every candle,
+// annualized by the candles per year of c
+func ratios(c *Candles, equity []EquityPoint, capital float64) (float64, float64) {
+	returns := make([]float64, 0, len(equity))
+	previous := capital
+	for _, p := range equity {
+		if previous <= 0 {
+			return 0, 0
+		}
+		returns = append(returns, p.Equity/previous-1)
+		previous = p.Equity
+	}
+	if len(returns) < 2 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance, downside := 0.0, 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		downside += math.Min(r, 0) * math.Min(r, 0)
+	}
+	variance /= float64(len(returns) - 1)
+	downside /= float64(len(returns))
+
+	// the candles per year, one when they span no time
+	annual := 1.0
+	if span := time.Duration(c.Time[c.Len()-1]-c.Time[0]) * time.Millisecond; span > 0 {
+		annual = math.Sqrt(float64(c.Len()-1) / (span.Hours() / 24 / 365.25))
+	}
+	var sharpe, sortino float64
+	if variance > 0 {
+		sharpe = mean / math.Sqrt(variance) * annual
+	}
+	if downside > 0 {
+		sortino = mean / math.Sqrt(downside) * annual
+	}
+	return finite(sharpe), finite(sortino)
+}
+
+// finite returns v, 0 for the infinities of compounding the returns of candles spanning too little time
+func finite(v float64) float64 {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,96 @@
+package indicator_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/app/models/indicator"
+	"github.com/oarkflow/nepse/fees"
+)
+
+// daily returns a candle a day closing at closes, from 2024-01-01
+func daily(closes ...float64) *indicator.Candles {
+	c := &indicator.Candles{}
+	for i, price := range closes {
+		c.Time = append(c.Time, time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC).UnixMilli())
+		c.Close = append(c.Close, price)
+	}
+	return c
+}
+
+// trade returns the frictionless trade of shares bought on candle buy and sold on candle sell of c
+func trade(c *indicator.Candles, shares float64, buy, sell int) fees.Trade {
+	var costs *fees.Schedule
+	return costs.Trade(shares, time.UnixMilli(c.Time[buy]).UTC(), c.Close[buy], time.UnixMilli(c.Time[sell]).UTC(), c.Close[sell])
+}
+
+func TestEquity(t *testing.T) {
+	assert := assert.New(t)
+	c := daily(100, 110, 90, 120, 120)
+	equity := indicator.Equity(c, []fees.Trade{trade(c, 1, 0, 3)}, 120)
+	values := make([]float64, len(equity))
+	for i, p := range equity {
+		assert.Equal(c.Time[i], p.Time)
+		values[i] = p.Equity
+	}
+	// marked to the close while held, then the net profit realized
+	assert.Equal([]float64{120, 130, 110, 140, 140}, values)
+}
+
+func TestMeasure(t *testing.T) {
+	assert := assert.New(t)
+	c := daily(100, 110, 90, 120, 120)
+	m := indicator.Measure(c, []fees.Trade{trade(c, 1, 0, 3)}, 0)
+
+	// the shares at the highest close
+	assert.Equal(120.0, m.Capital)
+	assert.InDelta(100*(140.0/120-1), m.TotalReturn, 1e-9)
+	assert.InDelta(100*(math.Pow(140.0/120, 365.25/4)-1), m.CAGR, 1e-6)
+	// from 130 on the second day to 110, back above on the fourth
+	assert.InDelta(100*20/130.0, m.MaxDrawdown, 1e-9)
+	assert.Equal(2, m.MaxDrawdownDays)
+	assert.InDelta(m.CAGR/m.MaxDrawdown, m.Calmar, 1e-9)
+	assert.Greater(m.Sharpe, 0.0)
+	assert.Greater(m.Sortino, m.Sharpe)
+	assert.Equal(100.0, m.WinRate)
+	assert.Equal(0.0, m.ProfitFactor)
+	assert.Equal(20.0, m.AverageWin)
+	assert.Equal(0.0, m.AverageLoss)
+	assert.Equal(20.0, m.Expectancy)
+	assert.Equal(60.0, m.Exposure)
+	assert.Equal(1, m.TradeCount)
+	assert.Equal(3.0, m.AverageHoldingDays)
+
+	// a win of 30 and a loss of 10
+	c = daily(100, 130, 130, 120, 120, 110)
+	m = indicator.Measure(c, []fees.Trade{trade(c, 1, 0, 1), trade(c, 1, 3, 5)}, 200)
+	assert.Equal(200.0, m.Capital)
+	assert.InDelta(10.0, m.TotalReturn, 1e-9)
+	assert.Equal(50.0, m.WinRate)
+	assert.Equal(3.0, m.ProfitFactor)
+	assert.Equal(30.0, m.AverageWin)
+	assert.Equal(-10.0, m.AverageLoss)
+	assert.Equal(10.0, m.Expectancy)
+	assert.InDelta(100*10/230.0, m.MaxDrawdown, 1e-9)
+	// not recovered by the last candle
+	assert.Equal(4, m.MaxDrawdownDays)
+	assert.Equal(1.5, m.AverageHoldingDays)
+
+	// nothing traded
+	m = indicator.Measure(c, nil, 0)
+	assert.Equal(indicator.Metrics{}, m)
+	m = indicator.Measure(c, nil, 100)
+	assert.Equal(0.0, m.TotalReturn)
+	assert.Equal(0.0, m.MaxDrawdown)
+	assert.Equal(0.0, m.Sharpe)
+	assert.Equal(0.0, m.Exposure)
+
+	// the fees and tax make the equity
+	trades := indicator.EMA.Backtest("VOO", sine(300), indicator.EMA.Defaults(), 1, nil).Trades(fees.NEPSE(), 10)
+	m = indicator.Measure(sine(300), trades, 100000)
+	assert.InDelta(fees.Sum(trades).Net/1000, m.TotalReturn, 1e-9)
+	assert.Equal(len(trades), m.TradeCount)
+}
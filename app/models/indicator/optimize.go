@@ -0,0 +1,292 @@
+package indicator
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/nepse/fees"
+)
+
+// SearchMethod is how an Optimizer picks the params it evaluates
+type SearchMethod string
+
+const (
+	// GridSearch evaluates every combination of the values of the ranges
+	GridSearch SearchMethod = "grid"
+	// RandomSearch evaluates combinations drawn at random from the grid
+	RandomSearch SearchMethod = "random"
+	// RefineSearch evaluates a coarse grid of the ranges, then finer grids around the best params
+	// until the steps of the params are reached
+	RefineSearch SearchMethod = "refine"
+)
+
+const (
+	// DefaultRandomEvaluations are the evaluations of a random search without a cap
+	DefaultRandomEvaluations = 100
+	// DefaultCoarse are the values of every param of a refine round when Coarse is not given
+	DefaultCoarse = 5
+)
+
+// Search are the options of a parameter search
+type Search struct {
+	// Method is GridSearch when empty
+	Method SearchMethod `json:"method,omitempty"`
+	// Evaluations caps the params evaluated, 0 for no cap
+	Evaluations int `json:"evaluations,omitempty"`
+	// Seconds caps the wall time of the search, 0 for no cap
+	Seconds float64 `json:"seconds,omitempty"`
+	// Workers evaluate params at the same time, runtime.NumCPU() when 0
+	Workers int `json:"workers,omitempty"`
+	// Seed seeds the draws of a random search
+	Seed int64 `json:"seed,omitempty"`
+	// Coarse are the values of every param of a refine round, at least 4, DefaultCoarse when 0
+	Coarse int `json:"coarse,omitempty"`
+}
+
+// Evaluation is the net profit of a strategy with Params and the totals of its trades
+type Evaluation struct {
+	Params      Params  `json:"params"`
+	Performance float64 `json:"performance"`
+	Gross       float64 `json:"gross"`
+	Fees        float64 `json:"fees"`
+	Tax         float64 `json:"tax"`
+	Trades      int     `json:"trades"`
+}
+
+// Optimization is the result of a parameter search
+type Optimization struct {
+	// Best are the params with the best performance, the defaults when none is profitable
+	Best        Params  `json:"best"`
+	Performance float64 `json:"performance"`
+	// Evaluations are the params evaluated, in the order they were searched
+	Evaluations []Evaluation `json:"evaluations"`
+	// Stopped tells a cap ended the search before it was done
+	Stopped bool `json:"stopped"`
+}
+
+// Optimizer searches the params of a strategy with the best net profit, trading Shares each time,
+// one when zero, with the fees and tax of Costs, see Signals.Net
+type Optimizer struct {
+	Search
+	Costs  *fees.Schedule
+	Shares float64
+}
+
+// Optimize searches the params of s in ranges on the candles of symbol.
+// Params without a range take their default.
+func (o Optimizer) Optimize(s *Strategy, symbol string, c *Candles, ranges Ranges) Optimization {
+	ctx := context.Background()
+	if o.Seconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(o.Seconds*float64(time.Second)))
+		defer cancel()
+	}
+	if o.Shares == 0 {
+		o.Shares = 1
+	}
+	r := &search{Optimizer: o, strategy: s, symbol: symbol, candles: c, seen: make(map[string]bool)}
+	switch o.Method {
+	case RandomSearch:
+		r.random(ctx, ranges)
+	case RefineSearch:
+		r.refine(ctx, ranges)
+	default:
+		r.evaluate(ctx, s.Grid(ranges))
+	}
+
+	opt := Optimization{Best: s.Defaults(), Evaluations: r.evaluations, Stopped: r.stopped}
+	if i := best(r.evaluations); i >= 0 && opt.Performance < r.evaluations[i].Performance {
+		opt.Best, opt.Performance = r.evaluations[i].Params, r.evaluations[i].Performance
+	}
+	return opt
+}
+
+// best returns the index of the first evaluation with the best performance, -1 when there is none
+func best(evaluations []Evaluation) int {
+	i := -1
+	for j, e := range evaluations {
+		if i < 0 || evaluations[i].Performance < e.Performance {
+			i = j
+		}
+	}
+	return i
+}
+
+// search is the state of an Optimize call
+type search struct {
+	Optimizer
+	strategy    *Strategy
+	symbol      string
+	candles     *Candles
+	seen        map[string]bool
+	evaluations []Evaluation
+	// evaluated counts the params sent to the workers, with too few candles or not
+	evaluated int
+	stopped   bool
+}
+
+// evaluate evaluates the params of batch not evaluated yet across the workers, until a cap is reached,
+// and returns how many it evaluated
+func (r *search) evaluate(ctx context.Context, batch []Params) int {
+	var todo []Params
+	for _, params := range batch {
+		hash := params.Hash()
+		if r.seen[hash] {
+			continue
+		}
+		if r.Evaluations > 0 && r.evaluated+len(todo) >= r.Evaluations {
+			r.stopped = true
+			break
+		}
+		r.seen[hash] = true
+		todo = append(todo, params)
+	}
+
+	workers := r.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]*Evaluation, len(todo))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if signals := r.strategy.Backtest(r.symbol, r.candles, todo[i], 1, nil); signals != nil {
+					total := fees.Sum(signals.Trades(r.Costs, r.Shares))
+					results[i] = &Evaluation{Params: todo[i], Performance: total.Net,
+						Gross: total.Gross, Fees: total.Fees, Tax: total.Tax, Trades: total.Trades}
+				}
+			}
+		}()
+	}
+	sent := 0
+send:
+	for i := range todo {
+		select {
+		case <-ctx.Done():
+			r.stopped = true
+			break send
+		case jobs <- i:
+			sent++
+		}
+	}
+	r.evaluated += sent
+	close(jobs)
+	wg.Wait()
+
+	for _, e := range results {
+		if e != nil {
+			r.evaluations = append(r.evaluations, *e)
+		}
+	}
+	return sent
+}
+
+// random evaluates Evaluations params drawn from the grid of ranges, DefaultRandomEvaluations without a cap
+func (r *search) random(ctx context.Context, ranges Ranges) {
+	n := r.Evaluations
+	if n <= 0 {
+		n = DefaultRandomEvaluations
+	}
+	values := make(map[string][]float64, len(r.strategy.Params))
+	size := 1.0
+	for _, param := range r.strategy.Params {
+		values[param.Name] = []float64{param.Default}
+		if rng, ok := ranges[param.Name]; ok {
+			values[param.Name] = param.Values(rng)
+		}
+		size *= float64(len(values[param.Name]))
+	}
+	if size == 0 {
+		return
+	}
+	// a draw from a space smaller than n is the whole grid
+	n = int(math.Min(float64(n), size))
+
+	rnd := rand.New(rand.NewSource(r.Seed))
+	drawn := make(map[string]bool, n)
+	var batch []Params
+	for len(batch) < n {
+		params := make(Params, len(r.strategy.Params))
+		for _, param := range r.strategy.Params {
+			v := values[param.Name]
+			params[param.Name] = v[rnd.Intn(len(v))]
+		}
+		if hash := params.Hash(); !drawn[hash] {
+			drawn[hash] = true
+			batch = append(batch, params)
+		}
+	}
+	r.evaluate(ctx, batch)
+}
+
+// refine evaluates a grid of Coarse values of every param of ranges, then the grid of the ranges around the best
+// params one spacing of the last grid away, until every value of the ranges left is searched
+func (r *search) refine(ctx context.Context, ranges Ranges) {
+	coarse := r.Coarse
+	if coarse <= 0 {
+		coarse = DefaultCoarse
+	}
+	// fewer values would not narrow the ranges
+	coarse = max(coarse, 4)
+
+	current := make(Ranges, len(ranges))
+	for name, rng := range ranges {
+		current[name] = rng
+	}
+	for {
+		spacing := make(map[string]float64, len(current))
+		done := true
+		grid := r.strategy.combine(func(param Param) []float64 {
+			rng, ok := current[param.Name]
+			if !ok {
+				return []float64{param.Default}
+			}
+			values := spread(param, rng, coarse)
+			if len(values) < len(param.Values(rng)) {
+				done = false
+			}
+			spacing[param.Name] = param.Step
+			for i := 1; i < len(values); i++ {
+				spacing[param.Name] = math.Max(spacing[param.Name], values[i]-values[i-1])
+			}
+			return values
+		})
+		evaluated := r.evaluate(ctx, grid)
+		i := best(r.evaluations)
+		if done || r.stopped || i < 0 || evaluated == 0 {
+			return
+		}
+		// around the best params, within the ranges searched
+		for name, rng := range ranges {
+			center := r.evaluations[i].Params[name]
+			current[name] = Range{
+				Low:  math.Max(rng.Low, center-spacing[name]),
+				High: math.Min(rng.High, center+spacing[name]),
+			}
+		}
+	}
+}
+
+// spread returns n values of p from r.Low to r.High evenly apart on the steps of p, all of them when there are fewer
+func spread(p Param, r Range, n int) []float64 {
+	all := p.Values(r)
+	if len(all) <= n {
+		return all
+	}
+	values := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		v := all[int(math.Round(float64(i)*float64(len(all)-1)/float64(n-1)))]
+		if len(values) == 0 || values[len(values)-1] != v {
+			values = append(values, v)
+		}
+	}
+	return values
+}
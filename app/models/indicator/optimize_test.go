@@ -0,0 +1,124 @@
+package indicator_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/app/models/indicator"
+)
+
+// swing buys on candle buy and sells on candle sell, its profit peaking at buy 20 and sell 60 of peak
+var swing = &indicator.Strategy{
+	Name: "swing",
+	Params: []indicator.Param{
+		{Name: "buy", Default: 1, Step: 1},
+		{Name: "sell", Default: 2, Step: 1},
+	},
+	Generate: func(c *indicator.Candles, p indicator.Params) []string {
+		actions := make([]string, c.Len())
+		actions[p.Int("buy")] = indicator.BUY
+		actions[p.Int("sell")] = indicator.SELL
+		return actions
+	},
+}
+
+// peak returns 100 candles, lowest on 20 and highest on 60
+func peak() *indicator.Candles {
+	c := &indicator.Candles{}
+	for i := 0; i < 100; i++ {
+		c.Time = append(c.Time, int64(i))
+		c.Close = append(c.Close, 100+math.Abs(float64(i)-20)-2*math.Max(0, float64(i)-60))
+	}
+	return c
+}
+
+var swingRanges = indicator.Ranges{"buy": {Low: 1, High: 40}, "sell": {Low: 41, High: 99}}
+
+func TestOptimizerGrid(t *testing.T) {
+	assert := assert.New(t)
+	opt := indicator.Optimizer{Search: indicator.Search{Workers: 4}}.Optimize(swing, "VOO", peak(), swingRanges)
+	assert.Equal(indicator.Params{"buy": 20, "sell": 60}, opt.Best)
+	assert.Equal(40.0, opt.Performance)
+	assert.Len(opt.Evaluations, 40*59)
+	assert.False(opt.Stopped)
+	// in the order of the grid
+	for i, params := range swing.Grid(swingRanges) {
+		assert.Equal(params, opt.Evaluations[i].Params)
+	}
+
+	// the same as one worker
+	one := indicator.Optimizer{Search: indicator.Search{Workers: 1}}.Optimize(swing, "VOO", sine(300), indicator.Ranges{
+		"buy": {Low: 1, High: 100}, "sell": {Low: 101, High: 299}})
+	assert.Equal(one, indicator.Optimizer{}.Optimize(swing, "VOO", sine(300), indicator.Ranges{
+		"buy": {Low: 1, High: 100}, "sell": {Low: 101, High: 299}}))
+
+	// nothing profitable, the defaults
+	opt = indicator.Optimizer{}.Optimize(swing, "VOO", peak(), indicator.Ranges{"buy": {Low: 60, High: 60}, "sell": {Low: 70, High: 99}})
+	assert.Equal(swing.Defaults(), opt.Best)
+	assert.Equal(0.0, opt.Performance)
+}
+
+func TestOptimizerRandom(t *testing.T) {
+	assert := assert.New(t)
+	search := indicator.Search{Method: indicator.RandomSearch, Evaluations: 200, Seed: 7}
+	opt := indicator.Optimizer{Search: search}.Optimize(swing, "VOO", peak(), swingRanges)
+	assert.Len(opt.Evaluations, 200)
+	assert.Greater(opt.Performance, 0.0)
+	seen := make(map[string]bool)
+	for _, e := range opt.Evaluations {
+		assert.False(seen[e.Params.Hash()])
+		seen[e.Params.Hash()] = true
+		assert.GreaterOrEqual(e.Params["buy"], 1.0)
+		assert.LessOrEqual(e.Params["buy"], 40.0)
+	}
+	// the same seed, the same draws
+	assert.Equal(opt, indicator.Optimizer{Search: search}.Optimize(swing, "VOO", peak(), swingRanges))
+
+	// a space smaller than the evaluations is searched whole
+	small := indicator.Ranges{"buy": {Low: 18, High: 22}, "sell": {Low: 58, High: 62}}
+	opt = indicator.Optimizer{Search: search}.Optimize(swing, "VOO", peak(), small)
+	assert.Len(opt.Evaluations, 25)
+	assert.Equal(indicator.Params{"buy": 20, "sell": 60}, opt.Best)
+}
+
+func TestOptimizerRefine(t *testing.T) {
+	assert := assert.New(t)
+	opt := indicator.Optimizer{Search: indicator.Search{Method: indicator.RefineSearch}}.Optimize(swing, "VOO", peak(), swingRanges)
+	assert.Equal(indicator.Params{"buy": 20, "sell": 60}, opt.Best)
+	assert.Equal(40.0, opt.Performance)
+	assert.False(opt.Stopped)
+	// far fewer than the grid
+	assert.Less(len(opt.Evaluations), 40*59/4)
+
+	// params without a range keep their default
+	opt = indicator.Optimizer{Search: indicator.Search{Method: indicator.RefineSearch, Coarse: 3}}.Optimize(swing, "VOO", peak(),
+		indicator.Ranges{"sell": {Low: 41, High: 99}})
+	assert.Equal(indicator.Params{"buy": 1, "sell": 60}, opt.Best)
+}
+
+func TestOptimizerCaps(t *testing.T) {
+	assert := assert.New(t)
+	opt := indicator.Optimizer{Search: indicator.Search{Evaluations: 50}}.Optimize(swing, "VOO", peak(), swingRanges)
+	assert.Len(opt.Evaluations, 50)
+	assert.True(opt.Stopped)
+	assert.Equal(swing.Grid(swingRanges)[49], opt.Evaluations[49].Params)
+
+	// the coarse grid of 25 and the first 5 of the next round
+	opt = indicator.Optimizer{Search: indicator.Search{Method: indicator.RefineSearch, Evaluations: 30}}.Optimize(swing, "VOO", peak(), swingRanges)
+	assert.Len(opt.Evaluations, 30)
+	assert.True(opt.Stopped)
+
+	slow := &indicator.Strategy{Name: "slow", Params: swing.Params, Generate: func(c *indicator.Candles, p indicator.Params) []string {
+		time.Sleep(10 * time.Millisecond)
+		return swing.Generate(c, p)
+	}}
+	start := time.Now()
+	opt = indicator.Optimizer{Search: indicator.Search{Seconds: 0.1, Workers: 2}}.Optimize(slow, "VOO", peak(), swingRanges)
+	assert.Less(time.Since(start), time.Second)
+	assert.True(opt.Stopped)
+	assert.NotEmpty(opt.Evaluations)
+	assert.Less(len(opt.Evaluations), 100)
+}
@@ -1,97 +1,42 @@
 package indicator
 
-// RsiBacktestParam represents some parameters used for backtest
-type RsiBacktestParam struct {
-	RsiPeriodLow      int     `json:"period_low"`
-	RsiPeriodHigh     int     `json:"period_high"`
-	RsiBuyThreadLow   float64 `json:"buy_low"`
-	RsiBuyThreadHigh  float64 `json:"buy_high"`
-	RsiSellThreadLow  float64 `json:"sell_low"`
-	RsiSellThreadHigh float64 `json:"sell_high"`
-}
-
-// RsiSignals stores EmaSignal
-type RsiSignals struct {
-	RsiSignals []RsiSignal
-}
+import "github.com/markcheno/go-talib"
 
-// RsiSignal is signal results of backtest
-type RsiSignal struct {
-	ID     int     `gorm:"primary_key" json:"-"`
-	Symbol string  `json:"-"`
-	Time   int64   `json:"time"`
-	Price  float64 `json:"-"`
-	Action string  `json:"action"`
+// RSI buys when the RSI crosses above the buy threshold and sells when it crosses below the sell threshold
+var RSI = &Strategy{
+	Name:        "rsi",
+	DisplayName: "RSI",
+	Params: []Param{
+		{Name: "period", Default: 14, Step: 1},
+		{Name: "buy", Default: 30, Step: 1},
+		{Name: "sell", Default: 70, Step: 1},
+	},
+	Generate: generateRsi,
 }
 
-// Buy appends buy-signal to Signals, if can not buy, return false
-func (rsi *RsiSignals) Buy(symbol string, time int64, price float64) bool {
-	if !(rsi.CanBuy()) {
-		return false
+func generateRsi(c *Candles, p Params) []string {
+	period, buyThread, sellThread := p.Int("period"), p["buy"], p["sell"]
+	lenCandles := c.Len()
+	if period >= lenCandles {
+		return nil
 	}
-	rsi.RsiSignals = append(rsi.RsiSignals, RsiSignal{Symbol: symbol, Time: time, Price: price, Action: BUY})
-	return true
-}
 
-// CanBuy judges whether buy or not
-func (rsi *RsiSignals) CanBuy() bool {
-	lenSignals := len(rsi.RsiSignals)
-	// not buy or sell
-	if lenSignals == 0 {
-		return true
-	}
+	actions := make([]string, lenCandles)
+	rsi := talib.Rsi(c.Close, period)
 
-	if rsi.RsiSignals[lenSignals-1].Action == SELL {
-		return true
-	}
-
-	return false
-}
-
-// Sell appends sell-signal to Signals, if can not sell, return false
-func (rsi *RsiSignals) Sell(symbol string, time int64, price float64) bool {
-	if !(rsi.CanSell()) {
-		return false
-	}
-	rsi.RsiSignals = append(rsi.RsiSignals, RsiSignal{Symbol: symbol, Time: time, Price: price, Action: SELL})
-	return true
-}
-
-// CanSell judges whether sell or not
-func (rsi *RsiSignals) CanSell() bool {
-	lenSignals := len(rsi.RsiSignals)
-	// not buy or sell
-	if lenSignals == 0 {
-		return false
-	}
-
-	if rsi.RsiSignals[lenSignals-1].Action == BUY {
-		return true
-	}
-
-	return false
-}
-
-// Profit calculates profit for backtest
-func (rsi *RsiSignals) Profit() float64 {
-	profit := 0.0
-	afterSell := 0.0
-	isHolding := false
+	for day := 1; day < lenCandles; day++ {
+		if rsi[day-1] == 0 || rsi[day-1] == 100 {
+			continue
+		}
 
-	for _, signal := range rsi.RsiSignals {
-		if signal.Action == BUY {
-			profit -= signal.Price
-			isHolding = true
-		} else if signal.Action == SELL {
-			profit += signal.Price
-			afterSell = profit
-			isHolding = false
+		if rsi[day-1] < buyThread && rsi[day] >= buyThread {
+			actions[day] = BUY
 		}
-	}
 
-	if isHolding {
-		return afterSell
+		if rsi[day-1] > sellThread && rsi[day] <= sellThread {
+			actions[day] = SELL
+		}
 	}
 
-	return profit
+	return actions
 }
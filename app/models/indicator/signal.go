@@ -0,0 +1,127 @@
+package indicator
+
+import (
+	"time"
+
+	"github.com/oarkflow/nepse/fees"
+)
+
+// Signal is a buy or sell signal of a strategy, the signals of every strategy are stored in the "signals" table
+type Signal struct {
+	ID     int    `gorm:"primary_key" json:"-"`
+	Symbol string `gorm:"index:idx_signals_symbol_strategy" json:"-"`
+	// Strategy is the name of the strategy in the registry
+	Strategy string `gorm:"index:idx_signals_symbol_strategy" json:"-"`
+	// ParamsHash is the hash of the params of the strategy the signal comes from, see Params.Hash
+	ParamsHash string  `json:"-"`
+	Time       int64   `json:"time"`
+	BSDate     string  `gorm:"-" json:"bs_date,omitempty"`
+	Price      float64 `json:"-"`
+	Action     string  `json:"action"`
+}
+
+// Signals stores the signals of Strategy with the params of ParamsHash
+type Signals struct {
+	Strategy   string
+	ParamsHash string
+	Signals    []Signal
+}
+
+// Buy appends buy-signal to Signals, if can not buy, return false
+func (s *Signals) Buy(symbol string, time int64, price float64) bool {
+	if !(s.CanBuy()) {
+		return false
+	}
+	s.Signals = append(s.Signals, s.signal(symbol, time, price, BUY))
+	return true
+}
+
+// CanBuy judges whether buy or not
+func (s *Signals) CanBuy() bool {
+	lenSignals := len(s.Signals)
+	// not buy or sell
+	if lenSignals == 0 {
+		return true
+	}
+
+	if s.Signals[lenSignals-1].Action == SELL {
+		return true
+	}
+
+	return false
+}
+
+// Sell appends sell-signal to Signals, if can not sell, return false
+func (s *Signals) Sell(symbol string, time int64, price float64) bool {
+	if !(s.CanSell()) {
+		return false
+	}
+	s.Signals = append(s.Signals, s.signal(symbol, time, price, SELL))
+	return true
+}
+
+// CanSell judges whether sell or not
+func (s *Signals) CanSell() bool {
+	lenSignals := len(s.Signals)
+	// not buy or sell
+	if lenSignals == 0 {
+		return false
+	}
+
+	if s.Signals[lenSignals-1].Action == BUY {
+		return true
+	}
+
+	return false
+}
+
+func (s *Signals) signal(symbol string, time int64, price float64, action string) Signal {
+	return Signal{Symbol: symbol, Strategy: s.Strategy, ParamsHash: s.ParamsHash, Time: time, Price: price, Action: action}
+}
+
+// Trades returns the trades of shares each of every buy and the sell following it with the fees and tax of costs,
+// a buy not sold yet is left out. A nil costs is frictionless.
+func (s *Signals) Trades(costs *fees.Schedule, shares float64) []fees.Trade {
+	var trades []fees.Trade
+	var buy *Signal
+	for i, signal := range s.Signals {
+		switch {
+		case signal.Action == BUY:
+			buy = &s.Signals[i]
+		case signal.Action == SELL && buy != nil:
+			trades = append(trades, costs.Trade(shares, time.UnixMilli(buy.Time).UTC(), buy.Price,
+				time.UnixMilli(signal.Time).UTC(), signal.Price))
+			buy = nil
+		}
+	}
+	return trades
+}
+
+// Net returns the profit of the trades of shares each after the fees and tax of costs, see Trades
+func (s *Signals) Net(costs *fees.Schedule, shares float64) float64 {
+	return fees.Sum(s.Trades(costs, shares)).Net
+}
+
+// Profit calculates profit for backtest, the price differences of one share without fees and tax
+func (s *Signals) Profit() float64 {
+	profit := 0.0
+	afterSell := 0.0
+	isHolding := false
+
+	for _, signal := range s.Signals {
+		if signal.Action == BUY {
+			profit -= signal.Price
+			isHolding = true
+		} else if signal.Action == SELL {
+			profit += signal.Price
+			afterSell = profit
+			isHolding = false
+		}
+	}
+
+	if isHolding {
+		return afterSell
+	}
+
+	return profit
+}
@@ -0,0 +1,86 @@
+package indicator_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/app/models/indicator"
+	"github.com/oarkflow/nepse/fees"
+)
+
+func TestSignalsBuyAndSell(t *testing.T) {
+	assert := assert.New(t)
+
+	signals := indicator.Signals{Strategy: "ema", ParamsHash: "hash"}
+	// when empty
+	assert.False(signals.Sell("VOO", 0, 100))
+	assert.True(signals.Buy("VOO", 0, 100))
+
+	// when last is BUY
+	assert.False(signals.Buy("VOO", 1, 100))
+	assert.True(signals.Sell("VOO", 1, 100))
+
+	// when last is SELL
+	assert.False(signals.Sell("VOO", 2, 100))
+	assert.True(signals.Buy("VOO", 2, 100))
+
+	assert.Equal(indicator.Signal{Symbol: "VOO", Strategy: "ema", ParamsHash: "hash", Time: 1, Price: 100, Action: indicator.SELL},
+		signals.Signals[1])
+}
+
+func TestSignalsProfit(t *testing.T) {
+	assert := assert.New(t)
+
+	signals := indicator.Signals{
+		Signals: []indicator.Signal{
+			{Symbol: "VOO", Time: 0, Price: 100, Action: indicator.BUY},
+			{Symbol: "VOO", Time: 1, Price: 150, Action: indicator.SELL},
+		},
+	}
+
+	// when buy at 100, sell at 150,
+	// expected profit is 50
+	assert.Equal(50.0, signals.Profit())
+
+	signals.Signals = append(signals.Signals, indicator.Signal{
+		Symbol: "VOO", Time: 2, Price: 100, Action: indicator.BUY,
+	})
+
+	// when buy at 100, sell at 150, buy at 100,
+	// expected profit is 50
+	assert.Equal(50.0, signals.Profit())
+}
+
+func TestSignalsTrades(t *testing.T) {
+	assert := assert.New(t)
+	day := int64(24 * time.Hour / time.Millisecond)
+	signals := indicator.Signals{
+		Signals: []indicator.Signal{
+			{Symbol: "VOO", Time: 0, Price: 500, Action: indicator.BUY},
+			{Symbol: "VOO", Time: 10 * day, Price: 600, Action: indicator.SELL},
+			{Symbol: "VOO", Time: 20 * day, Price: 600, Action: indicator.BUY},
+			{Symbol: "VOO", Time: 420 * day, Price: 500, Action: indicator.SELL},
+			{Symbol: "VOO", Time: 421 * day, Price: 500, Action: indicator.BUY},
+		},
+	}
+
+	// frictionless, the price differences
+	trades := signals.Trades(nil, 100)
+	assert.Len(trades, 2)
+	assert.Equal(10000.0, trades[0].Gross)
+	assert.Equal(10000.0, trades[0].Net)
+	assert.Equal(0.0, signals.Net(nil, 100))
+
+	trades = signals.Trades(fees.NEPSE(), 100)
+	// 50000 bought at 0.36% and 60000 sold at 0.33%, SEBON fee on both, a DP charge on the sell
+	assert.InDelta(180+7.5+198+9+25, trades[0].Fees, 1e-9)
+	assert.Equal(10, trades[0].HoldingDays)
+	assert.InDelta((10000-trades[0].Fees)*0.075, trades[0].Tax, 1e-9)
+	assert.InDelta(trades[0].Gross-trades[0].Fees-trades[0].Tax, trades[0].Net, 1e-9)
+	// a loss pays no tax
+	assert.Equal(400, trades[1].HoldingDays)
+	assert.Equal(0.0, trades[1].Tax)
+	assert.InDelta(trades[0].Net+trades[1].Net, signals.Net(fees.NEPSE(), 100), 1e-9)
+}
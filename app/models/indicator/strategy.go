@@ -0,0 +1,260 @@
+package indicator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oarkflow/nepse/fees"
+)
+
+// Candles are the prices a strategy generates its signals from, oldest first
+type Candles struct {
+	Time   []int64
+	Open   []float64
+	High   []float64
+	Low    []float64
+	Close  []float64
+	Volume []float64
+}
+
+// Len returns the number of candles
+func (c *Candles) Len() int {
+	return len(c.Time)
+}
+
+// Param is a parameter of a strategy
+type Param struct {
+	Name string `json:"name"`
+	// Default is the value used when no range is given, and the best value when nothing is profitable
+	Default float64 `json:"default"`
+	// Step is the distance between the values searched, 1 for integer params
+	Step float64 `json:"step"`
+}
+
+// Values returns the values of p from r.Low to r.High, both included, Step apart
+func (p Param) Values(r Range) []float64 {
+	if p.Step <= 0 {
+		return []float64{r.Low}
+	}
+	var values []float64
+	for i := 0; ; i++ {
+		v := p.round(r.Low + float64(i)*p.Step)
+		if v > r.High+p.Step*1e-6 {
+			break
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// round rounds v to the decimals of Step, so 1.5 + 0.1 is 1.6
+func (p Param) round(v float64) float64 {
+	decimals := 0
+	if step := strconv.FormatFloat(p.Step, 'f', -1, 64); strings.Contains(step, ".") {
+		decimals = len(step) - strings.Index(step, ".") - 1
+	}
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
+
+// Range is the lowest and highest value a param is searched from
+type Range struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// Ranges are the ranges of the params of a strategy by name,
+// as json {"<param>_low": low, "<param>_high": high...} like {"short_low": 5, "short_high": 15}
+type Ranges map[string]Range
+
+func (r Ranges) MarshalJSON() ([]byte, error) {
+	flat := make(map[string]float64, 2*len(r))
+	for name, rng := range r {
+		flat[name+"_low"] = rng.Low
+		flat[name+"_high"] = rng.High
+	}
+	return json.Marshal(flat)
+}
+
+func (r *Ranges) UnmarshalJSON(data []byte) error {
+	var flat map[string]float64
+	if err := json.Unmarshal(data, &flat); err != nil {
+		return err
+	}
+	ranges := make(Ranges, len(flat)/2)
+	for key, v := range flat {
+		switch {
+		case strings.HasSuffix(key, "_low"):
+			name := strings.TrimSuffix(key, "_low")
+			rng := ranges[name]
+			rng.Low = v
+			ranges[name] = rng
+		case strings.HasSuffix(key, "_high"):
+			name := strings.TrimSuffix(key, "_high")
+			rng := ranges[name]
+			rng.High = v
+			ranges[name] = rng
+		default:
+			return fmt.Errorf("range %s: want <param>_low or <param>_high", key)
+		}
+	}
+	*r = ranges
+	return nil
+}
+
+// Params are the values of the params of a strategy by name
+type Params map[string]float64
+
+// Int returns the value of name rounded to an int
+func (p Params) Int(name string) int {
+	return int(math.Round(p[name]))
+}
+
+// Hash identifies the values of p, the same for equal params whatever their order
+func (p Params) Hash() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha1.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s;", name, strconv.FormatFloat(p[name], 'g', -1, 64))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Strategy generates buy and sell signals from candles
+type Strategy struct {
+	// Name is the key of the strategy in the registry, the API and the signals table, like "ema"
+	Name string `json:"name"`
+	// DisplayName is the name shown to users, like "EMA"
+	DisplayName string `json:"display_name"`
+	// Params is the parameter space of the strategy, in the order it is searched
+	Params []Param `json:"params"`
+	// Generate returns the action on every candle, BUY, SELL or "" for none,
+	// nil when there are too few candles for params
+	Generate func(c *Candles, p Params) []string `json:"-"`
+}
+
+// Defaults returns the default value of every param of s
+func (s *Strategy) Defaults() Params {
+	params := make(Params, len(s.Params))
+	for _, param := range s.Params {
+		params[param.Name] = param.Default
+	}
+	return params
+}
+
+// Backtest returns the signals of s with params on the candles of symbol from candle start,
+// following last when it is not nil. nil when there are too few candles.
+func (s *Strategy) Backtest(symbol string, c *Candles, params Params, start int, last *Signal) *Signals {
+	actions := s.Generate(c, params)
+	if actions == nil {
+		return nil
+	}
+	signals := &Signals{Strategy: s.Name, ParamsHash: params.Hash()}
+	// using at SignalTest
+	if last != nil {
+		signals.Signals = append(signals.Signals, *last)
+	}
+	for day := start; day < len(actions); day++ {
+		switch actions[day] {
+		case BUY:
+			signals.Buy(symbol, c.Time[day], c.Close[day])
+		case SELL:
+			signals.Sell(symbol, c.Time[day], c.Close[day])
+		}
+	}
+	return signals
+}
+
+// Grid returns every combination of the values of the params of s in ranges, the last param changing first.
+// Params without a range take their default.
+func (s *Strategy) Grid(ranges Ranges) []Params {
+	return s.combine(func(param Param) []float64 {
+		if r, ok := ranges[param.Name]; ok {
+			return param.Values(r)
+		}
+		return []float64{param.Default}
+	})
+}
+
+// combine returns every combination of the values of the params of s, the last param changing first
+func (s *Strategy) combine(values func(Param) []float64) []Params {
+	grid := []Params{{}}
+	for _, param := range s.Params {
+		values := values(param)
+		next := make([]Params, 0, len(grid)*len(values))
+		for _, params := range grid {
+			for _, v := range values {
+				combined := make(Params, len(params)+1)
+				for name, value := range params {
+					combined[name] = value
+				}
+				combined[param.Name] = v
+				next = append(next, combined)
+			}
+		}
+		grid = next
+	}
+	return grid
+}
+
+// Optimize returns the params of the grid of ranges with the best net profit on the candles of symbol,
+// trading shares each time with the fees and tax of costs, see Signals.Net.
+// The defaults and zero when none is profitable. The grid is searched by the workers of Optimizer.
+func (s *Strategy) Optimize(symbol string, c *Candles, ranges Ranges, costs *fees.Schedule, shares float64) (best Params, bestPerformance float64) {
+	o := Optimizer{Costs: costs, Shares: shares}.Optimize(s, symbol, c, ranges)
+	return o.Best, o.Performance
+}
+
+var (
+	registry      = make(map[string]*Strategy)
+	registered    []*Strategy
+	registryMutex sync.RWMutex
+)
+
+func init() {
+	Register(EMA, BB, MACD, RSI, WILLR)
+}
+
+// Register adds strategies to the registry, replacing the strategy of the same name
+func Register(strategies ...*Strategy) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	for _, s := range strategies {
+		if _, ok := registry[s.Name]; ok {
+			for i := range registered {
+				if registered[i].Name == s.Name {
+					registered[i] = s
+				}
+			}
+		} else {
+			registered = append(registered, s)
+		}
+		registry[s.Name] = s
+	}
+}
+
+// Lookup returns the strategy registered as name
+func Lookup(name string) (*Strategy, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	s, ok := registry[name]
+	return s, ok
+}
+
+// Strategies returns the registered strategies, in the order they were registered
+func Strategies() []*Strategy {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	return append([]*Strategy(nil), registered...)
+}
@@ -0,0 +1,126 @@
+package indicator_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/app/models/indicator"
+	"github.com/oarkflow/nepse/fees"
+)
+
+// sine returns n candles swinging around a rising trend
+func sine(n int) *indicator.Candles {
+	c := &indicator.Candles{}
+	for i := 0; i < n; i++ {
+		price := 100 + 10*math.Sin(float64(i)/8) + float64(i)/20
+		c.Time = append(c.Time, int64(i))
+		c.Open = append(c.Open, price-1)
+		c.High = append(c.High, price+2)
+		c.Low = append(c.Low, price-2)
+		c.Close = append(c.Close, price)
+		c.Volume = append(c.Volume, 1000)
+	}
+	return c
+}
+
+func TestStrategies(t *testing.T) {
+	assert := assert.New(t)
+	var names []string
+	for _, s := range indicator.Strategies()[:5] {
+		names = append(names, s.Name)
+
+		signals := s.Backtest("VOO", sine(300), s.Defaults(), 1, nil)
+		assert.NotEmpty(signals.Signals, s.Name)
+		assert.Equal(s.Name, signals.Signals[0].Strategy)
+		assert.Equal(s.Defaults().Hash(), signals.Signals[0].ParamsHash)
+		// too few candles
+		assert.Nil(s.Backtest("VOO", sine(5), s.Defaults(), 1, nil), s.Name)
+	}
+	assert.Equal([]string{"ema", "bb", "macd", "rsi", "willr"}, names)
+
+	ema, ok := indicator.Lookup("ema")
+	assert.True(ok)
+	assert.Equal("EMA", ema.DisplayName)
+	_, ok = indicator.Lookup("sma")
+	assert.False(ok)
+}
+
+func TestRegister(t *testing.T) {
+	assert := assert.New(t)
+	hold := &indicator.Strategy{
+		Name:   "hold",
+		Params: []indicator.Param{{Name: "day", Default: 1, Step: 1}},
+		Generate: func(c *indicator.Candles, p indicator.Params) []string {
+			actions := make([]string, c.Len())
+			actions[p.Int("day")] = indicator.BUY
+			return actions
+		},
+	}
+	indicator.Register(hold)
+	n := len(indicator.Strategies())
+
+	s, ok := indicator.Lookup("hold")
+	assert.True(ok)
+	assert.Equal("hold", indicator.Strategies()[n-1].Name)
+	signals := s.Backtest("VOO", sine(10), indicator.Params{"day": 3}, 1, nil)
+	assert.Equal([]indicator.Signal{{Symbol: "VOO", Strategy: "hold", ParamsHash: indicator.Params{"day": 3}.Hash(),
+		Time: 3, Price: sine(10).Close[3], Action: indicator.BUY}}, signals.Signals)
+
+	// registering again replaces the strategy in place
+	indicator.Register(&indicator.Strategy{Name: "hold", DisplayName: "Hold", Generate: hold.Generate})
+	assert.Len(indicator.Strategies(), n)
+	assert.Equal("Hold", indicator.Strategies()[n-1].DisplayName)
+}
+
+func TestParamValues(t *testing.T) {
+	assert := assert.New(t)
+	k := indicator.Param{Name: "k", Step: 0.1}
+	assert.Equal([]float64{1.5, 1.6, 1.7, 1.8, 1.9, 2, 2.1, 2.2, 2.3, 2.4, 2.5}, k.Values(indicator.Range{Low: 1.5, High: 2.5}))
+	n := indicator.Param{Name: "n", Step: 1}
+	assert.Equal([]float64{5, 6, 7}, n.Values(indicator.Range{Low: 5, High: 7}))
+	assert.Empty(n.Values(indicator.Range{Low: 7, High: 5}))
+	quarter := indicator.Param{Name: "q", Step: 0.25}
+	assert.Equal([]float64{0, 0.25, 0.5}, quarter.Values(indicator.Range{Low: 0, High: 0.5}))
+}
+
+func TestGrid(t *testing.T) {
+	assert := assert.New(t)
+	grid := indicator.EMA.Grid(indicator.Ranges{"short": {Low: 5, High: 6}, "long": {Low: 15, High: 17}})
+	assert.Len(grid, 6)
+	assert.Equal(indicator.Params{"short": 5, "long": 15}, grid[0])
+	assert.Equal(indicator.Params{"short": 5, "long": 16}, grid[1])
+	// no range, the default
+	assert.Equal([]indicator.Params{{"short": 5, "long": 14}}, indicator.EMA.Grid(indicator.Ranges{"short": {Low: 5, High: 5}}))
+
+	ranges := indicator.Ranges{"short": {Low: 3, High: 8}, "long": {Low: 10, High: 20}}
+	best, performance := indicator.EMA.Optimize("VOO", sine(300), ranges, nil, 1)
+	assert.Greater(performance, 0.0)
+	signals := indicator.EMA.Backtest("VOO", sine(300), best, 1, nil)
+	assert.InDelta(signals.Profit(), performance, 1e-9)
+
+	// the fees and tax of 10 shares a trade take most of the profit
+	_, net := indicator.EMA.Optimize("VOO", sine(300), ranges, fees.NEPSE(), 10)
+	assert.Less(net, 10*performance)
+
+	// nothing to trade, the defaults
+	best, performance = indicator.EMA.Optimize("VOO", sine(5), indicator.Ranges{"short": {Low: 3, High: 8}}, nil, 1)
+	assert.Equal(indicator.EMA.Defaults(), best)
+	assert.Equal(0.0, performance)
+}
+
+func TestParams(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(indicator.Params{"a": 1, "b": 2.5}.Hash(), indicator.Params{"b": 2.5, "a": 1}.Hash())
+	assert.NotEqual(indicator.Params{"a": 1}.Hash(), indicator.Params{"a": 2}.Hash())
+	assert.Equal(3, indicator.Params{"n": 2.9999}.Int("n"))
+
+	var ranges indicator.Ranges
+	assert.Nil(json.Unmarshal([]byte(`{"short_low": 5, "short_high": 15, "long_low": 15, "long_high": 30}`), &ranges))
+	assert.Equal(indicator.Ranges{"short": {Low: 5, High: 15}, "long": {Low: 15, High: 30}}, ranges)
+	data, _ := json.Marshal(ranges)
+	assert.JSONEq(`{"short_low": 5, "short_high": 15, "long_low": 15, "long_high": 30}`, string(data))
+	assert.NotNil(json.Unmarshal([]byte(`{"short": 5}`), &ranges))
+}
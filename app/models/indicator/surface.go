@@ -0,0 +1,180 @@
+package indicator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Axis is a param of a surface and its values, in order
+type Axis struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// Surface is the performance of the params evaluated by a search on the grid of two params, X and Y,
+// the other params fixed to Fixed
+type Surface struct {
+	Strategy string `json:"strategy"`
+	X        Axis   `json:"x"`
+	// Y has no values for the strategies of one param, the surface having one row
+	Y     Axis   `json:"y"`
+	Fixed Params `json:"fixed"`
+	// Performance is the performance of every y value, then every x value, nil where not evaluated
+	Performance [][]*float64 `json:"performance"`
+	// Smoothed is the mean performance of the cells evaluated within Radius cells of every cell, nil where none is
+	Smoothed [][]*float64 `json:"smoothed"`
+	Radius   int          `json:"radius"`
+}
+
+// near tells whether a and b are within radius steps of p
+func near(p Param, a, b float64, radius int) bool {
+	step := p.Step
+	if step <= 0 {
+		return a == b
+	}
+	return math.Abs(a-b) <= float64(radius)*step+step*1e-6
+}
+
+// Neighbourhood returns the mean performance of the evaluations of s with every param within radius steps
+// of center, center included, and how many there are
+func (s *Strategy) Neighbourhood(evaluations []Evaluation, center Params, radius int) (float64, int) {
+	sum, n := 0.0, 0
+	for _, e := range evaluations {
+		inside := true
+		for _, param := range s.Params {
+			if !near(param, e.Params[param.Name], center[param.Name], radius) {
+				inside = false
+				break
+			}
+		}
+		if inside {
+			sum += e.Performance
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return sum / float64(n), n
+}
+
+// Robustness returns the mean performance of the neighbourhood of radius steps of best over the performance of best,
+// near 1 for an optimum on a plateau and low or negative for an isolated spike. 0 when best is not profitable.
+func (s *Strategy) Robustness(evaluations []Evaluation, best Params, performance float64, radius int) float64 {
+	if performance <= 0 {
+		return 0
+	}
+	mean, _ := s.Neighbourhood(evaluations, best, radius)
+	return mean / performance
+}
+
+// Surface returns the surface of evaluations on the params x and y of s, the others fixed to the values of fixed,
+// smoothed within radius cells. An empty x or y is the first or second param of s.
+func (s *Strategy) Surface(evaluations []Evaluation, x, y string, fixed Params, radius int) (*Surface, error) {
+	if x == "" && len(s.Params) > 0 {
+		x = s.Params[0].Name
+	}
+	if y == "" && len(s.Params) > 1 {
+		y = s.Params[1].Name
+		if y == x {
+			y = s.Params[0].Name
+		}
+	}
+	params := make(map[string]Param, len(s.Params))
+	for _, param := range s.Params {
+		params[param.Name] = param
+	}
+	if _, ok := params[x]; !ok {
+		return nil, fmt.Errorf("strategy %s has no param %q", s.Name, x)
+	}
+	if _, ok := params[y]; !ok && y != "" {
+		return nil, fmt.Errorf("strategy %s has no param %q", s.Name, y)
+	}
+	if x == y {
+		return nil, fmt.Errorf("surface of %q on both axes", x)
+	}
+
+	surface := &Surface{Strategy: s.Name, X: Axis{Name: x}, Y: Axis{Name: y}, Fixed: make(Params), Radius: radius}
+	for _, param := range s.Params {
+		if param.Name != x && param.Name != y {
+			surface.Fixed[param.Name] = fixed[param.Name]
+		}
+	}
+	// the evaluations on the slice of the fixed params
+	var slice []Evaluation
+	for _, e := range evaluations {
+		on := true
+		for name, v := range surface.Fixed {
+			if !near(params[name], e.Params[name], v, 0) {
+				on = false
+				break
+			}
+		}
+		if on {
+			slice = append(slice, e)
+		}
+	}
+	surface.X.Values = axis(slice, x)
+	rows := 1
+	if y != "" {
+		surface.Y.Values = axis(slice, y)
+		rows = len(surface.Y.Values)
+	}
+
+	index := func(values []float64, v float64) int {
+		for i, value := range values {
+			if value == v {
+				return i
+			}
+		}
+		return -1
+	}
+	surface.Performance = make([][]*float64, rows)
+	for j := range surface.Performance {
+		surface.Performance[j] = make([]*float64, len(surface.X.Values))
+	}
+	for _, e := range slice {
+		i, j := index(surface.X.Values, e.Params[x]), 0
+		if y != "" {
+			j = index(surface.Y.Values, e.Params[y])
+		}
+		performance := e.Performance
+		surface.Performance[j][i] = &performance
+	}
+
+	surface.Smoothed = make([][]*float64, rows)
+	for j := range surface.Smoothed {
+		surface.Smoothed[j] = make([]*float64, len(surface.X.Values))
+		for i := range surface.Smoothed[j] {
+			sum, n := 0.0, 0
+			for jj := max(0, j-radius); jj <= min(rows-1, j+radius); jj++ {
+				for ii := max(0, i-radius); ii <= min(len(surface.X.Values)-1, i+radius); ii++ {
+					if v := surface.Performance[jj][ii]; v != nil {
+						sum += *v
+						n++
+					}
+				}
+			}
+			if n > 0 {
+				mean := sum / float64(n)
+				surface.Smoothed[j][i] = &mean
+			}
+		}
+	}
+	return surface, nil
+}
+
+// axis returns the distinct values of name in evaluations, in increasing order
+func axis(evaluations []Evaluation, name string) []float64 {
+	seen := make(map[float64]bool)
+	var values []float64
+	for _, e := range evaluations {
+		if v := e.Params[name]; !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Float64s(values)
+	return values
+}
@@ -0,0 +1,90 @@
+package indicator_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/app/models/indicator"
+)
+
+func TestSurface(t *testing.T) {
+	assert := assert.New(t)
+	ranges := indicator.Ranges{"buy": {Low: 18, High: 22}, "sell": {Low: 58, High: 61}}
+	opt := indicator.Optimizer{}.Optimize(swing, "VOO", peak(), ranges)
+
+	surface, err := swing.Surface(opt.Evaluations, "", "", opt.Best, 1)
+	assert.Nil(err)
+	assert.Equal(indicator.Axis{Name: "buy", Values: []float64{18, 19, 20, 21, 22}}, surface.X)
+	assert.Equal(indicator.Axis{Name: "sell", Values: []float64{58, 59, 60, 61}}, surface.Y)
+	assert.Empty(surface.Fixed)
+	assert.Len(surface.Performance, 4)
+	assert.Len(surface.Performance[0], 5)
+	// sell 60 and buy 20
+	assert.Equal(40.0, *surface.Performance[2][2])
+	assert.Equal(38.0, *surface.Performance[0][2])
+	// the mean of the 3 x 3 cells around
+	assert.InDelta((38+39+38+39+40+39+38+39+38)/9.0, *surface.Smoothed[2][2], 1e-9)
+	// the corner, 2 x 2 cells
+	assert.InDelta((36+37+37+38)/4.0, *surface.Smoothed[0][0], 1e-9)
+
+	// the axes swapped
+	surface, err = swing.Surface(opt.Evaluations, "sell", "buy", opt.Best, 0)
+	assert.Nil(err)
+	assert.Equal("sell", surface.X.Name)
+	assert.Equal(*surface.Performance[2][2], *surface.Smoothed[2][2])
+
+	// one param, the other fixed to its best value
+	surface, err = swing.Surface(opt.Evaluations, "sell", "", indicator.Params{"buy": 19}, 1)
+	assert.Nil(err)
+	assert.Equal("buy", surface.Y.Name)
+	surface, err = swing.Surface(append(opt.Evaluations, indicator.Evaluation{Params: indicator.Params{"buy": 25, "sell": 70}}),
+		"sell", "buy", nil, 1)
+	assert.Nil(err)
+	assert.Len(surface.X.Values, 5)
+	// cells not evaluated are nil
+	assert.Nil(surface.Performance[0][4])
+
+	_, err = swing.Surface(opt.Evaluations, "hold", "", nil, 1)
+	assert.NotNil(err)
+	_, err = swing.Surface(opt.Evaluations, "buy", "buy", nil, 1)
+	assert.NotNil(err)
+}
+
+func TestSurfaceFixed(t *testing.T) {
+	assert := assert.New(t)
+	ranges := indicator.Ranges{"fast": {Low: 5, High: 7}, "slow": {Low: 20, High: 22}, "signal": {Low: 8, High: 9}}
+	opt := indicator.Optimizer{}.Optimize(indicator.MACD, "VOO", sine(300), ranges)
+
+	surface, err := indicator.MACD.Surface(opt.Evaluations, "", "", indicator.Params{"signal": 9}, 1)
+	assert.Nil(err)
+	assert.Equal("fast", surface.X.Name)
+	assert.Equal("slow", surface.Y.Name)
+	assert.Equal(indicator.Params{"signal": 9}, surface.Fixed)
+	for j := range surface.Y.Values {
+		for i := range surface.X.Values {
+			assert.NotNil(surface.Performance[j][i])
+		}
+	}
+}
+
+func TestRobustness(t *testing.T) {
+	assert := assert.New(t)
+	opt := indicator.Optimizer{}.Optimize(swing, "VOO", peak(), swingRanges)
+
+	mean, n := swing.Neighbourhood(opt.Evaluations, opt.Best, 1)
+	assert.Equal(9, n)
+	assert.InDelta((38+39+38+39+40+39+38+39+38)/9.0, mean, 1e-9)
+	assert.InDelta(mean/40, swing.Robustness(opt.Evaluations, opt.Best, opt.Performance, 1), 1e-9)
+	_, n = swing.Neighbourhood(opt.Evaluations, opt.Best, 0)
+	assert.Equal(1, n)
+
+	// an isolated spike
+	spike := []indicator.Evaluation{
+		{Params: indicator.Params{"buy": 1, "sell": 50}, Performance: 100},
+		{Params: indicator.Params{"buy": 2, "sell": 50}, Performance: -10},
+		{Params: indicator.Params{"buy": 1, "sell": 51}, Performance: -10},
+	}
+	assert.InDelta(80/3.0/100, swing.Robustness(spike, indicator.Params{"buy": 1, "sell": 50}, 100, 1), 1e-9)
+	assert.Equal(0.0, swing.Robustness(spike, indicator.Params{"buy": 1, "sell": 50}, 0, 1))
+}
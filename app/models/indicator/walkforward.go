@@ -0,0 +1,171 @@
+package indicator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/oarkflow/nepse/fees"
+)
+
+// ErrTooFewCandles is returned by a walk-forward analysis without candles for one train and test slice
+var ErrTooFewCandles = errors.New("too few candles")
+
+// Slice returns the candles of c from from to to, to excluded, sharing the arrays of c
+func (c *Candles) Slice(from, to int) *Candles {
+	part := func(v []float64) []float64 {
+		if v == nil {
+			return nil
+		}
+		return v[from:to]
+	}
+	return &Candles{Time: c.Time[from:to], Open: part(c.Open), High: part(c.High), Low: part(c.Low),
+		Close: part(c.Close), Volume: part(c.Volume)}
+}
+
+// WalkForward splits candles into folds, each optimizing the params of a strategy on Train candles
+// and trading them on the Test candles following, out of sample.
+// The train slices roll Test candles forward fold after fold, with Anchored they all start at the first candle.
+type WalkForward struct {
+	Train    int  `json:"train"`
+	Test     int  `json:"test"`
+	Anchored bool `json:"anchored"`
+	// Optimizer searches the params of every train slice
+	Optimizer `json:"-"`
+}
+
+// Fold is a train slice, the params optimized on it and how they did on the test slice following
+type Fold struct {
+	// TrainStart and TestStart are the times of the first candles of the slices, TestEnd of the last
+	TrainStart int64  `json:"train_start"`
+	TestStart  int64  `json:"test_start"`
+	TestEnd    int64  `json:"test_end"`
+	Params     Params `json:"params"`
+	// InSample is the net profit of Params on the train slice, OutOfSample on the test slice
+	InSample    float64 `json:"in_sample"`
+	OutOfSample float64 `json:"out_of_sample"`
+	// Efficiency is the out-of-sample profit per candle over the in-sample one, 0 without in-sample profit
+	Efficiency  float64      `json:"efficiency"`
+	Trades      []fees.Trade `json:"trades"`
+	Evaluations int          `json:"evaluations"`
+}
+
+// Drift is how the value of a param changed across folds
+type Drift struct {
+	Values []float64 `json:"values"`
+	Mean   float64   `json:"mean"`
+	StdDev float64   `json:"std_dev"`
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+}
+
+// EquityPoint is the out-of-sample net profit realized by Time
+type EquityPoint struct {
+	Time   int64   `json:"time"`
+	Equity float64 `json:"equity"`
+}
+
+// WalkForwardReport is the result of a walk-forward analysis of a strategy
+type WalkForwardReport struct {
+	Strategy string `json:"strategy"`
+	Folds    []Fold `json:"folds"`
+	// InSample and OutOfSample are the totals of the folds
+	InSample    float64 `json:"in_sample"`
+	OutOfSample float64 `json:"out_of_sample"`
+	// Efficiency is the out-of-sample profit per candle of all folds over the in-sample one
+	Efficiency float64          `json:"efficiency"`
+	Drift      map[string]Drift `json:"drift"`
+	// Equity is the out-of-sample net profit realized on every test candle, the folds stitched one after the other
+	Equity []EquityPoint `json:"equity"`
+}
+
+// Run runs the walk-forward analysis of s with the params searched in ranges on the candles of symbol.
+// The last test slice may be shorter than Test.
+func (w WalkForward) Run(s *Strategy, symbol string, c *Candles, ranges Ranges) (*WalkForwardReport, error) {
+	if w.Train <= 0 || w.Test <= 0 {
+		return nil, fmt.Errorf("walk forward train %d and test %d: want both above 0", w.Train, w.Test)
+	}
+	if c.Len() <= w.Train {
+		return nil, fmt.Errorf("%w: %d for a train slice of %d and a test slice", ErrTooFewCandles, c.Len(), w.Train)
+	}
+	shares := w.Shares
+	if shares == 0 {
+		shares = 1
+	}
+
+	report := &WalkForwardReport{Strategy: s.Name, Drift: make(map[string]Drift)}
+	var trainCandles, testCandles int
+	realized := 0.0
+	for testStart := w.Train; testStart < c.Len(); testStart += w.Test {
+		trainStart := testStart - w.Train
+		if w.Anchored {
+			trainStart = 0
+		}
+		testEnd := min(testStart+w.Test, c.Len())
+
+		opt := w.Optimizer.Optimize(s, symbol, c.Slice(trainStart, testStart), ranges)
+		fold := Fold{
+			TrainStart:  c.Time[trainStart],
+			TestStart:   c.Time[testStart],
+			TestEnd:     c.Time[testEnd-1],
+			Params:      opt.Best,
+			InSample:    opt.Performance,
+			Evaluations: len(opt.Evaluations),
+		}
+		// the train slice warms up the indicators, only the signals of the test slice are traded
+		if signals := s.Backtest(symbol, c.Slice(trainStart, testEnd), opt.Best, testStart-trainStart, nil); signals != nil {
+			fold.Trades = signals.Trades(w.Costs, shares)
+			fold.OutOfSample = fees.Sum(fold.Trades).Net
+		}
+		fold.Efficiency = efficiency(fold.InSample, testStart-trainStart, fold.OutOfSample, testEnd-testStart)
+
+		// the equity of every test candle, the trades counting once sold
+		next := 0
+		for i := testStart; i < testEnd; i++ {
+			for ; next < len(fold.Trades) && fold.Trades[next].SellTime.UnixMilli() <= c.Time[i]; next++ {
+				realized += fold.Trades[next].Net
+			}
+			report.Equity = append(report.Equity, EquityPoint{Time: c.Time[i], Equity: realized})
+		}
+
+		report.Folds = append(report.Folds, fold)
+		report.InSample += fold.InSample
+		report.OutOfSample += fold.OutOfSample
+		trainCandles += testStart - trainStart
+		testCandles += testEnd - testStart
+	}
+	report.Efficiency = efficiency(report.InSample, trainCandles, report.OutOfSample, testCandles)
+
+	for _, param := range s.Params {
+		values := make([]float64, len(report.Folds))
+		for i, fold := range report.Folds {
+			values[i] = fold.Params[param.Name]
+		}
+		report.Drift[param.Name] = drift(values)
+	}
+	return report, nil
+}
+
+// efficiency returns the profit per candle out of sample over the one in sample, 0 without in-sample profit
+func efficiency(inSample float64, inCandles int, outOfSample float64, outCandles int) float64 {
+	if inSample <= 0 || inCandles == 0 || outCandles == 0 {
+		return 0
+	}
+	return (outOfSample / float64(outCandles)) / (inSample / float64(inCandles))
+}
+
+// drift returns the mean, standard deviation and bounds of values
+func drift(values []float64) Drift {
+	d := Drift{Values: values, Min: math.Inf(1), Max: math.Inf(-1)}
+	for _, v := range values {
+		d.Mean += v
+		d.Min = math.Min(d.Min, v)
+		d.Max = math.Max(d.Max, v)
+	}
+	d.Mean /= float64(len(values))
+	for _, v := range values {
+		d.StdDev += (v - d.Mean) * (v - d.Mean)
+	}
+	d.StdDev = math.Sqrt(d.StdDev / float64(len(values)))
+	return d
+}
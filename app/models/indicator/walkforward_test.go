@@ -0,0 +1,81 @@
+package indicator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/app/models/indicator"
+	"github.com/oarkflow/nepse/fees"
+)
+
+func TestWalkForward(t *testing.T) {
+	assert := assert.New(t)
+	c := sine(300)
+	ranges := indicator.Ranges{"short": {Low: 3, High: 8}, "long": {Low: 10, High: 20}}
+
+	report, err := indicator.WalkForward{Train: 100, Test: 60}.Run(indicator.EMA, "VOO", c, ranges)
+	assert.Nil(err)
+	assert.Equal("ema", report.Strategy)
+	// tests from 100 to 160, 220 and 280, the last one 20 candles
+	assert.Len(report.Folds, 4)
+	assert.Equal([]int64{0, 60, 120, 180}, []int64{report.Folds[0].TrainStart, report.Folds[1].TrainStart,
+		report.Folds[2].TrainStart, report.Folds[3].TrainStart})
+	assert.Equal(int64(280), report.Folds[3].TestStart)
+	assert.Equal(int64(299), report.Folds[3].TestEnd)
+
+	inSample, outOfSample := 0.0, 0.0
+	for _, fold := range report.Folds {
+		// the best params of the train slice
+		opt := indicator.Optimizer{}.Optimize(indicator.EMA, "VOO", c.Slice(int(fold.TrainStart), int(fold.TestStart)), ranges)
+		assert.Equal(opt.Best, fold.Params)
+		assert.Equal(opt.Performance, fold.InSample)
+		assert.Equal(len(opt.Evaluations), fold.Evaluations)
+		for _, trade := range fold.Trades {
+			assert.GreaterOrEqual(trade.BuyTime.UnixMilli(), fold.TestStart)
+			assert.LessOrEqual(trade.SellTime.UnixMilli(), fold.TestEnd)
+		}
+		assert.InDelta(fees.Sum(fold.Trades).Net, fold.OutOfSample, 1e-9)
+		inSample += fold.InSample
+		outOfSample += fold.OutOfSample
+	}
+	assert.InDelta(inSample, report.InSample, 1e-9)
+	assert.InDelta(outOfSample, report.OutOfSample, 1e-9)
+	assert.InDelta((outOfSample/200)/(inSample/400), report.Efficiency, 1e-9)
+
+	// the out-of-sample equity of every test candle, ending at the out-of-sample profit
+	assert.Len(report.Equity, 200)
+	assert.Equal(int64(100), report.Equity[0].Time)
+	assert.InDelta(outOfSample, report.Equity[199].Equity, 1e-9)
+
+	assert.Len(report.Drift, 2)
+	short := report.Drift["short"]
+	assert.Len(short.Values, 4)
+	assert.Equal(report.Folds[2].Params["short"], short.Values[2])
+	assert.LessOrEqual(short.Min, short.Mean)
+	assert.GreaterOrEqual(short.Max, short.Mean)
+
+	// anchored, every train slice starts at the first candle
+	report, err = indicator.WalkForward{Train: 100, Test: 100, Anchored: true}.Run(indicator.EMA, "VOO", c, ranges)
+	assert.Nil(err)
+	assert.Len(report.Folds, 2)
+	assert.Equal(int64(0), report.Folds[1].TrainStart)
+	assert.Equal(int64(200), report.Folds[1].TestStart)
+
+	// the fees and tax of the optimizer
+	costed, err := indicator.WalkForward{Train: 100, Test: 100, Anchored: true,
+		Optimizer: indicator.Optimizer{Costs: fees.NEPSE(), Shares: 10}}.Run(indicator.EMA, "VOO", c, ranges)
+	assert.Nil(err)
+	for _, fold := range costed.Folds {
+		for _, trade := range fold.Trades {
+			assert.Equal(10.0, trade.Shares)
+			assert.Greater(trade.Fees, 0.0)
+		}
+	}
+
+	_, err = indicator.WalkForward{Train: 300, Test: 10}.Run(indicator.EMA, "VOO", c, ranges)
+	assert.True(errors.Is(err, indicator.ErrTooFewCandles))
+	_, err = indicator.WalkForward{Train: 100}.Run(indicator.EMA, "VOO", c, ranges)
+	assert.NotNil(err)
+}
@@ -1,97 +1,42 @@
 package indicator
 
-// WillrBacktestParam represents some parameters used for backtest
-type WillrBacktestParam struct {
-	WillrPeriodLow      int     `json:"period_low"`
-	WillrPeriodHigh     int     `json:"period_high"`
-	WillrBuyThreadLow   float64 `json:"buy_low"`
-	WillrBuyThreadHigh  float64 `json:"buy_high"`
-	WillrSellThreadLow  float64 `json:"sell_low"`
-	WillrSellThreadHigh float64 `json:"sell_high"`
-}
-
-// WillrSignals stores WillrSignal
-type WillrSignals struct {
-	WillrSignals []WillrSignal
-}
+import "github.com/markcheno/go-talib"
 
-// WillrSignal is signal results of backtest
-type WillrSignal struct {
-	ID     int     `gorm:"primary_key" json:"-"`
-	Symbol string  `json:"-"`
-	Time   int64   `json:"time"`
-	Price  float64 `json:"-"`
-	Action string  `json:"action"`
+// WILLR buys when the Williams %R crosses above the buy threshold and sells when it crosses below the sell threshold
+var WILLR = &Strategy{
+	Name:        "willr",
+	DisplayName: "WILLr",
+	Params: []Param{
+		{Name: "period", Default: 10, Step: 1},
+		{Name: "buy", Default: -20, Step: 1},
+		{Name: "sell", Default: -80, Step: 1},
+	},
+	Generate: generateWillr,
 }
 
-// Buy appends buy-signal to Signals, if can not buy, return false
-func (wi *WillrSignals) Buy(symbol string, time int64, price float64) bool {
-	if !(wi.CanBuy()) {
-		return false
+func generateWillr(c *Candles, p Params) []string {
+	period, buyThread, sellThread := p.Int("period"), p["buy"], p["sell"]
+	lenCandles := c.Len()
+	if period >= lenCandles {
+		return nil
 	}
-	wi.WillrSignals = append(wi.WillrSignals, WillrSignal{Symbol: symbol, Time: time, Price: price, Action: BUY})
-	return true
-}
 
-// CanBuy judges whether buy or not
-func (wi *WillrSignals) CanBuy() bool {
-	lenSignals := len(wi.WillrSignals)
-	// not buy or sell
-	if lenSignals == 0 {
-		return true
-	}
+	actions := make([]string, lenCandles)
+	willr := talib.WillR(c.High, c.Low, c.Close, period)
 
-	if wi.WillrSignals[lenSignals-1].Action == SELL {
-		return true
-	}
-
-	return false
-}
-
-// Sell appends sell-signal to Signals, if can not sell, return false
-func (wi *WillrSignals) Sell(symbol string, time int64, price float64) bool {
-	if !(wi.CanSell()) {
-		return false
-	}
-	wi.WillrSignals = append(wi.WillrSignals, WillrSignal{Symbol: symbol, Time: time, Price: price, Action: SELL})
-	return true
-}
-
-// CanSell judges whether sell or not
-func (wi *WillrSignals) CanSell() bool {
-	lenSignals := len(wi.WillrSignals)
-	// not buy or sell
-	if lenSignals == 0 {
-		return false
-	}
-
-	if wi.WillrSignals[lenSignals-1].Action == BUY {
-		return true
-	}
-
-	return false
-}
-
-// Profit calculates profit for backtest
-func (wi *WillrSignals) Profit() float64 {
-	profit := 0.0
-	afterSell := 0.0
-	isHolding := false
+	for day := 1; day < lenCandles; day++ {
+		if willr[day-1] == 0 || willr[day-1] == -100 {
+			continue
+		}
 
-	for _, signal := range wi.WillrSignals {
-		if signal.Action == BUY {
-			profit -= signal.Price
-			isHolding = true
-		} else if signal.Action == SELL {
-			profit += signal.Price
-			afterSell = profit
-			isHolding = false
+		if willr[day-1] < buyThread && willr[day] >= buyThread {
+			actions[day] = BUY
 		}
-	}
 
-	if isHolding {
-		return afterSell
+		if willr[day-1] > sellThread && willr[day] <= sellThread {
+			actions[day] = SELL
+		}
 	}
 
-	return profit
+	return actions
 }
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/stock"
+)
+
+// Bar is a daily session of a symbol stored in the DB, the market data read by DBSource
+type Bar struct {
+	Symbol        string    `gorm:"primaryKey"`
+	Date          time.Time `gorm:"primaryKey"`
+	Open          float64
+	High          float64
+	Low           float64
+	Close         float64
+	VWAP          float64
+	Volume        float64
+	PreviousClose float64
+	Turnover      float64
+	Transactions  int64
+	WeeksHigh52   float64
+	WeeksLow52    float64
+}
+
+func newBar(d nepse.StockData) Bar {
+	return Bar{
+		Symbol:        d.Symbol,
+		Date:          d.Date,
+		Open:          d.OpenPrice,
+		High:          d.HighPrice,
+		Low:           d.LowPrice,
+		Close:         d.ClosePrice,
+		VWAP:          d.VWAP,
+		Volume:        d.Volume,
+		PreviousClose: d.PreviousClose,
+		Turnover:      d.Turnover,
+		Transactions:  d.Transactions,
+		WeeksHigh52:   d.WeeksHigh52,
+		WeeksLow52:    d.WeeksLow52,
+	}
+}
+
+// StockData returns b as a session
+func (b Bar) StockData() nepse.StockData {
+	return nepse.StockData{
+		Symbol:        b.Symbol,
+		Date:          b.Date.UTC(),
+		OpenPrice:     b.Open,
+		HighPrice:     b.High,
+		LowPrice:      b.Low,
+		ClosePrice:    b.Close,
+		VWAP:          b.VWAP,
+		Volume:        b.Volume,
+		PreviousClose: b.PreviousClose,
+		Turnover:      b.Turnover,
+		Transactions:  b.Transactions,
+		WeeksHigh52:   b.WeeksHigh52,
+		WeeksLow52:    b.WeeksLow52,
+	}
+}
+
+// DBSource is a stock.DataSource reading the bars of a DB, new sessions are those given to Save
+type DBSource struct {
+	db   *gorm.DB
+	feed nepse.Feed
+}
+
+var _ stock.DataSource = (*DBSource)(nil)
+
+// NewDBSource returns the source of the bars of db, creating their table when it does not exist
+func NewDBSource(db *gorm.DB) (*DBSource, error) {
+	if err := db.AutoMigrate(&Bar{}); err != nil {
+		return nil, err
+	}
+	return &DBSource{db: db}, nil
+}
+
+// Save stores rows, a row replacing the bar of the same symbol and date,
+// and sends the rows later than the last bar of their symbol to the subscribers
+func (s *DBSource) Save(rows ...nepse.StockData) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	last := make(map[string]time.Time)
+	if s.feed.Len() > 0 {
+		for _, row := range rows {
+			if _, ok := last[row.Symbol]; !ok {
+				latest, _ := s.Latest(row.Symbol)
+				last[row.Symbol] = latest.Date
+			}
+		}
+	}
+	bars := make([]Bar, len(rows))
+	for i, row := range rows {
+		bars[i] = newBar(row)
+	}
+	if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(bars, 500).Error; err != nil {
+		return err
+	}
+	var latest []nepse.StockData
+	for _, row := range rows {
+		if date, ok := last[row.Symbol]; ok && row.Date.After(date) {
+			latest = append(latest, row)
+		}
+	}
+	s.feed.Publish(latest...)
+	return nil
+}
+
+func (s *DBSource) Symbols() ([]string, error) {
+	var symbols []string
+	err := s.db.Model(&Bar{}).Distinct("symbol").Order("symbol").Pluck("symbol", &symbols).Error
+	return symbols, err
+}
+
+func (s *DBSource) History(symbol string, from, to time.Time) (*nepse.Series, error) {
+	query := s.db.Where("symbol = ?", symbol)
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("date <= ?", to)
+	}
+	var bars []Bar
+	if err := query.Order("date").Find(&bars).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]nepse.StockData, len(bars))
+	for i, bar := range bars {
+		rows[i] = bar.StockData()
+	}
+	series, ok := nepse.NewStore(rows...).Series(symbol)
+	if !ok {
+		return &nepse.Series{Symbol: symbol}, nil
+	}
+	return series, nil
+}
+
+func (s *DBSource) Latest(symbol string) (nepse.StockData, error) {
+	var bar Bar
+	err := s.db.Where("symbol = ?", symbol).Order("date desc").Limit(1).Find(&bar).Error
+	if err != nil {
+		return nepse.StockData{}, err
+	}
+	if bar.Symbol == "" {
+		return nepse.StockData{}, fmt.Errorf("%w: %s", stock.ErrNoData, symbol)
+	}
+	return bar.StockData(), nil
+}
+
+func (s *DBSource) Subscribe(ctx context.Context) (<-chan nepse.StockData, error) {
+	return s.feed.Subscribe(ctx), nil
+}
@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/oarkflow/nepse/app/models"
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/stock"
+)
+
+func TestDBSource(t *testing.T) {
+	assert := assert.New(t)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "source.sqlite3")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	assert.Nil(err)
+	source, err := models.NewDBSource(db)
+	assert.Nil(err)
+
+	day := func(s string) time.Time {
+		t, _ := time.Parse(time.DateOnly, s)
+		return t
+	}
+	row := func(symbol, date string, close float64) nepse.StockData {
+		return nepse.StockData{Symbol: symbol, Date: day(date), OpenPrice: close, HighPrice: close + 1,
+			LowPrice: close - 1, ClosePrice: close, VWAP: close, Volume: 10, Turnover: 10 * close, Transactions: 2}
+	}
+	assert.Nil(source.Save(row("ADBL", "2024-08-05", 550), row("ADBL", "2024-08-04", 545), row("NABIL", "2024-08-04", 500)))
+
+	symbols, err := source.Symbols()
+	assert.Nil(err)
+	assert.Equal([]string{"ADBL", "NABIL"}, symbols)
+	series, err := source.History("ADBL", day("2024-08-05"), time.Time{})
+	assert.Nil(err)
+	assert.Equal([]time.Time{day("2024-08-05")}, series.Date)
+	assert.Equal([]int64{2}, series.Transactions)
+	_, err = source.Latest("TEST")
+	assert.True(errors.Is(err, stock.ErrNoData))
+
+	// saving a session again replaces it, only later sessions are sent
+	ch, _ := source.Subscribe(context.Background())
+	assert.Nil(source.Save(row("ADBL", "2024-08-05", 555), row("ADBL", "2024-08-06", 560)))
+	latest := <-ch
+	assert.Equal(day("2024-08-06"), latest.Date)
+	series, _ = source.History("ADBL", time.Time{}, time.Time{})
+	assert.Equal([]float64{545, 555, 560}, series.Close)
+
+	// the client reads the DB like any source
+	client := stock.NewClient(source)
+	ts, _, err := client.GetTimeSeries("ADBL", time.Time{}, time.Time{}, false)
+	assert.Nil(err)
+	assert.Len(ts.Candles, 3)
+}
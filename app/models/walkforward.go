@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/oarkflow/nepse/app/models/indicator"
+	"github.com/oarkflow/nepse/stock"
+	"github.com/oarkflow/nepse/techan"
+)
+
+// WalkForwardParam recieves the parameters of a walk-forward analysis at json, those of BackTestParam
+// and the candles of the train and test slices of every fold, like
+// {"symbol": "NABIL", "period": 1000, "train": 250, "test": 60, "anchored": false, "ema": {...}}
+type WalkForwardParam struct {
+	BackTestParam
+	Train    int
+	Test     int
+	Anchored bool
+}
+
+func (wf WalkForwardParam) MarshalJSON() ([]byte, error) {
+	data, err := wf.BackTestParam.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["train"], fields["test"], fields["anchored"] = wf.Train, wf.Test, wf.Anchored
+	return json.Marshal(fields)
+}
+
+func (wf *WalkForwardParam) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var parsed WalkForwardParam
+	for key, v := range map[string]any{"train": &parsed.Train, "test": &parsed.Test, "anchored": &parsed.Anchored} {
+		if value, ok := fields[key]; ok {
+			if err := json.Unmarshal(value, v); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			delete(fields, key)
+		}
+	}
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(rest, &parsed.BackTestParam); err != nil {
+		return err
+	}
+	*wf = parsed
+	return nil
+}
+
+// WalkForwardResult is the walk-forward analysis of every strategy given ranges
+type WalkForwardResult struct {
+	Symbol     string                `json:"symbol"`
+	Train      int                   `json:"train"`
+	Test       int                   `json:"test"`
+	Anchored   bool                  `json:"anchored"`
+	Strategies []StrategyWalkForward `json:"strategies"`
+}
+
+// StrategyWalkForward is the walk-forward analysis of a strategy
+type StrategyWalkForward struct {
+	// DisplayName is the display name of the strategy in the registry
+	DisplayName string `json:"name"`
+	*indicator.WalkForwardReport
+}
+
+// WalkForwardClient runs the walk-forward analysis of the last Period sessions of Symbol read by client,
+// nothing is stored in the DB
+func (wf *WalkForwardParam) WalkForwardClient(client *stock.Client) (*WalkForwardResult, error) {
+	candles, _, err := LoadCandles(client, wf.Symbol, wf.Period, techan.Daily)
+	if err != nil {
+		return nil, err
+	}
+	return wf.WalkForwardFrame(&CandleFrame{Symbol: wf.Symbol, Candles: *candles})
+}
+
+// WalkForwardFrame runs the walk-forward analysis of the candles of cframe,
+// indicator.ErrTooFewCandles when they are too few for a fold
+func (wf *WalkForwardParam) WalkForwardFrame(cframe *CandleFrame) (*WalkForwardResult, error) {
+	logrus.Infof("walk forward start: %v, %v, train %v, test %v", wf.Symbol, wf.Period, wf.Train, wf.Test)
+
+	costs, shares := wf.costs()
+	walk := indicator.WalkForward{
+		Train:     wf.Train,
+		Test:      wf.Test,
+		Anchored:  wf.Anchored,
+		Optimizer: indicator.Optimizer{Search: wf.Search, Costs: costs, Shares: shares},
+	}
+	result := &WalkForwardResult{Symbol: wf.Symbol, Train: wf.Train, Test: wf.Test, Anchored: wf.Anchored}
+	candles := cframe.Bars()
+	for _, strategy := range indicator.Strategies() {
+		ranges, ok := wf.Ranges[strategy.Name]
+		if !ok {
+			continue
+		}
+		report, err := walk.Run(strategy, wf.Symbol, candles, ranges)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("%s walk forward end: in sample %v, out of sample %v, efficiency %v",
+			strategy.DisplayName, report.InSample, report.OutOfSample, report.Efficiency)
+		result.Strategies = append(result.Strategies, StrategyWalkForward{DisplayName: strategy.DisplayName, WalkForwardReport: report})
+	}
+	return result, nil
+}
@@ -0,0 +1,60 @@
+package models_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/app/models"
+	"github.com/oarkflow/nepse/app/models/indicator"
+)
+
+func (suite *ModelsTestSuite) TestWalkForwardClient() {
+	wf := models.WalkForwardParam{BackTestParam: backTestParam, Train: 200, Test: 100}
+	wf.Ranges = map[string]indicator.Ranges{"ema": backTestParam.Ranges["ema"], "rsi": backTestParam.Ranges["rsi"]}
+	result, err := wf.WalkForwardClient(suite.Client)
+	suite.Nil(err)
+	suite.Equal("VOO", result.Symbol)
+	suite.Len(result.Strategies, 2)
+	suite.Equal("EMA", result.Strategies[0].DisplayName)
+	suite.Equal("rsi", result.Strategies[1].Strategy)
+	// 500 sessions, tests of 100 from 200
+	suite.Len(result.Strategies[0].Folds, 3)
+	suite.Len(result.Strategies[0].Equity, 300)
+	suite.Len(result.Strategies[0].Drift, 2)
+	for _, fold := range result.Strategies[0].Folds {
+		for _, trade := range fold.Trades {
+			suite.Equal(float64(models.DefaultShares), trade.Shares)
+		}
+	}
+	// nothing stored
+	suite.Nil(models.GetOptimizedParamFrame("VOO").Param)
+
+	wf.Train = 500
+	_, err = wf.WalkForwardClient(suite.Client)
+	suite.True(errors.Is(err, indicator.ErrTooFewCandles))
+}
+
+func TestWalkForwardParamJSON(t *testing.T) {
+	assert := assert.New(t)
+	var wf models.WalkForwardParam
+	assert.Nil(json.Unmarshal([]byte(`{"symbol": "NABIL", "period": 1000, "train": 250, "test": 60, "anchored": true,
+		"ema": {"short_low": 5, "short_high": 15}}`), &wf))
+	assert.Equal("NABIL", wf.Symbol)
+	assert.Equal(1000, wf.Period)
+	assert.Equal(250, wf.Train)
+	assert.Equal(60, wf.Test)
+	assert.True(wf.Anchored)
+	assert.Equal(map[string]indicator.Ranges{"ema": {"short": {Low: 5, High: 15}}}, wf.Ranges)
+
+	data, err := json.Marshal(wf)
+	assert.Nil(err)
+	var again models.WalkForwardParam
+	assert.Nil(json.Unmarshal(data, &again))
+	assert.Equal(wf, again)
+
+	assert.EqualError(json.Unmarshal([]byte(`{"train": 250, "sma": {}}`), &wf), "unknown strategy: sma")
+	assert.NotNil(json.Unmarshal([]byte(`{"train": "250"}`), &wf))
+}
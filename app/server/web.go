@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -10,9 +11,12 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/oarkflow/nepse/app/models"
+	"github.com/oarkflow/nepse/app/models/indicator"
 	"github.com/oarkflow/nepse/config"
+	"github.com/oarkflow/nepse/nepse"
 	"github.com/oarkflow/nepse/scrape"
 	"github.com/oarkflow/nepse/stock"
+	"github.com/oarkflow/nepse/techan"
 )
 
 // JSONError is json error massage
@@ -20,6 +24,43 @@ type JSONError struct {
 	Error string `json:"error"`
 }
 
+// JSONNotReady is json massage returned while stock data is not ready to be queried
+type JSONNotReady struct {
+	Error  string            `json:"error"`
+	Ingest nepse.IngestState `json:"ingest"`
+}
+
+// Server serves the API from the sessions read by its stock client
+type Server struct {
+	client *stock.Client
+}
+
+// New returns a Server reading the sessions of symbols with client
+func New(client *stock.Client) *Server {
+	return &Server{client: client}
+}
+
+// notReady writes 503 with the ingest progress and returns true, when the source of s is still loading
+func (s *Server) notReady(w http.ResponseWriter) bool {
+	state, ok := s.client.State()
+	if !ok || state.Ready() {
+		return false
+	}
+	jsonMessage, err := json.Marshal(JSONNotReady{Error: "stock data not ready", Ingest: state})
+	if err != nil {
+		logrus.Warnf("not ready message create error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if state.Status == nepse.IngestLoading {
+		w.Header().Set("Retry-After", "5")
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write(jsonMessage)
+	return true
+}
+
 func errorAPI(w http.ResponseWriter, message string, code int) {
 	jsonMessage, err := json.Marshal(JSONError{Error: message})
 	if err != nil {
@@ -38,13 +79,87 @@ func IndexAPIHandler(w http.ResponseWriter, req *http.Request) {
 	temp.ExecuteTemplate(w, "index.html", nil)
 }
 
-// CandleGetAPIHandler gets stock data, optimized paramerters, signal data, and trade data,
-// when path is "/candles"
+// CandleGetAPIHandler is the CandleGetAPIHandler of the server of stock.Default()
 func CandleGetAPIHandler(w http.ResponseWriter, req *http.Request) {
+	New(stock.Default()).CandleGetAPIHandler(w, req)
+}
+
+// BacktestAPIHandler is the BacktestAPIHandler of the server of stock.Default()
+func BacktestAPIHandler(w http.ResponseWriter, req *http.Request) {
+	New(stock.Default()).BacktestAPIHandler(w, req)
+}
+
+// WalkForwardAPIHandler is the WalkForwardAPIHandler of the server of stock.Default()
+func WalkForwardAPIHandler(w http.ResponseWriter, req *http.Request) {
+	New(stock.Default()).WalkForwardAPIHandler(w, req)
+}
+
+// StrategiesAPIHandler returns the registered strategies with their display name and params,
+// when path is "/strategies"
+func StrategiesAPIHandler(w http.ResponseWriter, req *http.Request) {
+	js, err := json.Marshal(indicator.Strategies())
+	if err != nil {
+		logrus.Warnf("strategies json error: %v", err)
+		errorAPI(w, "strategies json error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// SurfaceAPIHandler returns the params of a strategy searched by the last backtest of symbol and their performance
+// as a grid of the params x and y, the others fixed to their best value, when path is "/surface", like
+// /surface?symbol=NABIL&strategy=macd&x=fast&y=slow&radius=1. x and y default to the first two params of the strategy,
+// radius, 1 by default, is the cells around every cell averaged into the smoothed grid.
+func SurfaceAPIHandler(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	radius := 1
+	if r := query.Get("radius"); r != "" {
+		var err error
+		if radius, err = strconv.Atoi(r); err != nil || radius < 0 {
+			errorAPI(w, fmt.Sprintf("bad radius: %s", r), http.StatusBadRequest)
+			return
+		}
+	}
+	surface, err := models.GetSurface(query.Get("symbol"), query.Get("strategy"), query.Get("x"), query.Get("y"), radius)
+	if errors.Is(err, models.ErrNoSurface) {
+		errorAPI(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logrus.Warnf("surface error: %v", err)
+		errorAPI(w, fmt.Sprintf("surface error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	js, err := json.Marshal(surface)
+	if err != nil {
+		logrus.Warnf("surface json error: %v", err)
+		errorAPI(w, "surface json error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// StatusAPIHandler is the StatusAPIHandler of the server of stock.Default()
+func StatusAPIHandler(w http.ResponseWriter, req *http.Request) {
+	New(stock.Default()).StatusAPIHandler(w, req)
+}
+
+// CandleGetAPIHandler gets stock data, optimized paramerters, signal data, and trade data,
+// with bs=true dates are also given in Bikram Sambat, when path is "/candles".
+// Symbols the symbol master does not list are rejected, the others come with their security.
+// timeframe (like w, m or 5d, see techan.ParseTimeframe) merges the daily sessions into weekly, monthly
+// or N session candles, period still counting daily sessions; signals are only tested on daily candles
+func (s *Server) CandleGetAPIHandler(w http.ResponseWriter, req *http.Request) {
 	logrus.Infof("candle get request: url -> %s", req.URL)
 
 	get, _ := strconv.ParseBool(req.URL.Query().Get("get"))
 	symbol := req.URL.Query().Get("symbol")
+	// period counts trading sessions
 	period, err := strconv.Atoi(req.URL.Query().Get("period"))
 
 	if symbol == "" {
@@ -57,34 +172,65 @@ func CandleGetAPIHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := s.client.Check(symbol); err != nil {
+		errorAPI(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timeframe, err := techan.ParseTimeframe(req.URL.Query().Get("timeframe"))
+	if err != nil {
+		errorAPI(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	dframe := models.NewDataFrame()
+	dframe.AddSecurity(s.client.Master, symbol)
 
 	// Downloads stock data
 	if get {
-		adjStock, _ := stock.GetStockData(symbol, period, true)
-		Stock, _ := stock.GetStockData(symbol, period, false)
-		if len(adjStock.Date) == 0 || len(Stock.Date) == 0 {
-			logrus.Warnf("stock get error, symbol: %v", symbol)
+		if s.notReady(w) {
+			return
+		}
+		candles, segments, err := models.LoadCandles(s.client, symbol, period, timeframe)
+		if err != nil {
+			logrus.Warnf("stock get error, symbol: %v: %v", symbol, err)
 			errorAPI(w, fmt.Sprintf("stock get error, symbol: %v", symbol), http.StatusBadRequest)
 			return
 		}
-		// After delete existing data, store stock data in DB
-		models.AllDeleteCandles()
-		models.NewCandlesFromQuote(adjStock, Stock).CreateCandles()
-		dframe.AddCandleFrame(symbol, period)
+		if timeframe.IsDaily() {
+			// the candles of symbol in DB are updated, those of other symbols are left as they are
+			if err := candles.CreateCandles(); err != nil {
+				logrus.Warnf("candle store error, symbol: %v: %v", symbol, err)
+				errorAPI(w, "candle store error", http.StatusInternalServerError)
+				return
+			}
+			dframe.AddCandleFrame(symbol, period)
+		} else {
+			// merged candles are not stored, the DB keeps daily candles
+			dframe.CandleFrame = &models.CandleFrame{Symbol: symbol, Candles: *candles}
+			dframe.Timeframe = timeframe.String()
+		}
+		if len(segments) > 1 {
+			dframe.Segments = segments
+		}
 		dframe.AddOptimizedParamFrame(symbol)
-		if models.SignalTest(symbol, period) {
+		if timeframe.IsDaily() && models.SignalTest(symbol, period) {
 			dframe.AddTradeFrame(symbol)
 		}
 	}
 
-	ema, _ := strconv.ParseBool(req.URL.Query().Get("ema"))
-	bb, _ := strconv.ParseBool(req.URL.Query().Get("bb"))
-	macd, _ := strconv.ParseBool(req.URL.Query().Get("macd"))
-	rsi, _ := strconv.ParseBool(req.URL.Query().Get("rsi"))
-	willr, _ := strconv.ParseBool(req.URL.Query().Get("willr"))
+	// signals of the strategies given as true, like ema=true
+	var strategies []string
+	for _, strategy := range indicator.Strategies() {
+		if ok, _ := strconv.ParseBool(req.URL.Query().Get(strategy.Name)); ok {
+			strategies = append(strategies, strategy.Name)
+		}
+	}
+	dframe.AddSignalFrame(symbol, strategies...)
 
-	dframe.AddSignalFrame(symbol, ema, bb, macd, rsi, willr)
+	if bs, _ := strconv.ParseBool(req.URL.Query().Get("bs")); bs {
+		dframe.AddBSDates()
+	}
 
 	js, err := json.Marshal(dframe)
 	if err != nil {
@@ -98,19 +244,29 @@ func CandleGetAPIHandler(w http.ResponseWriter, req *http.Request) {
 }
 
 // BacktestAPIHandler executes backtest, returns optimized parameters, trade data,
-// when path is "/backtest"
-func BacktestAPIHandler(w http.ResponseWriter, req *http.Request) {
+// with bs=true dates are also given in Bikram Sambat, when path is "/backtest".
+// The last period sessions of symbol are read from the source of s.
+func (s *Server) BacktestAPIHandler(w http.ResponseWriter, req *http.Request) {
 	logrus.Info("backtest request")
 	dec := json.NewDecoder(req.Body)
 
 	var bt models.BackTestParam
 	if err := dec.Decode(&bt); err != nil {
 		logrus.Warnf("backtest params error: %v", err)
-		errorAPI(w, fmt.Sprintf("backtest params error: %v", err), http.StatusInternalServerError)
+		errorAPI(w, fmt.Sprintf("backtest params error: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	if err := bt.BackTest().CreateBacktestResult(); err != nil {
+	if s.notReady(w) {
+		return
+	}
+	op, err := bt.BackTestClient(s.client)
+	if err != nil {
+		logrus.Warnf("backtest error: %v", err)
+		errorAPI(w, fmt.Sprintf("backtest error: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := op.CreateBacktestResult(); err != nil {
 		logrus.Warnf("backtest error: %v", err)
 		errorAPI(w, fmt.Sprintf("backtest error: %v", err), http.StatusInternalServerError)
 		return
@@ -119,6 +275,9 @@ func BacktestAPIHandler(w http.ResponseWriter, req *http.Request) {
 	dframe := models.NewDataFrame()
 	dframe.AddOptimizedParamFrame(bt.Symbol)
 	dframe.AddTradeFrame(bt.Symbol)
+	if bs, _ := strconv.ParseBool(req.URL.Query().Get("bs")); bs {
+		dframe.AddBSDates()
+	}
 
 	js, err := json.Marshal(dframe)
 	if err != nil {
@@ -131,16 +290,84 @@ func BacktestAPIHandler(w http.ResponseWriter, req *http.Request) {
 	w.Write(js)
 }
 
-// Run starts webserver
-func Run() {
-	logrus.Info("server start")
+// WalkForwardAPIHandler runs the walk-forward analysis of the strategies given ranges, returns their folds,
+// in-sample and out-of-sample profits, efficiency, parameter drift and out-of-sample equity, when path is "/walkforward".
+// The last period sessions of symbol are read from the source of s, nothing is stored.
+func (s *Server) WalkForwardAPIHandler(w http.ResponseWriter, req *http.Request) {
+	logrus.Info("walk forward request")
+	dec := json.NewDecoder(req.Body)
+
+	var wf models.WalkForwardParam
+	if err := dec.Decode(&wf); err != nil {
+		logrus.Warnf("walk forward params error: %v", err)
+		errorAPI(w, fmt.Sprintf("walk forward params error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if s.notReady(w) {
+		return
+	}
+	result, err := wf.WalkForwardClient(s.client)
+	if err != nil {
+		logrus.Warnf("walk forward error: %v", err)
+		errorAPI(w, fmt.Sprintf("walk forward error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	js, err := json.Marshal(result)
+	if err != nil {
+		logrus.Warnf("walk forward json error: %v", err)
+		errorAPI(w, "walk forward json error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// StatusAPIHandler returns the ingest state of the source of s, ready for sources
+// not loading in the background, when path is "/status"
+func (s *Server) StatusAPIHandler(w http.ResponseWriter, req *http.Request) {
+	state, ok := s.client.State()
+	if !ok {
+		state = nepse.IngestState{Status: nepse.IngestReady}
+	}
+	js, err := json.Marshal(state)
+	if err != nil {
+		logrus.Warnf("status json error: %v", err)
+		errorAPI(w, "status json error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+// Handler returns the routes of s
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
 	fs := http.FileServer(http.Dir("./static"))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
-	http.HandleFunc("/", IndexAPIHandler)
-	http.HandleFunc("/candles", CandleGetAPIHandler)
-	http.HandleFunc("/backtest", BacktestAPIHandler)
-	http.HandleFunc("/scrape", func(w http.ResponseWriter, req *http.Request) {
-		scrape.Scrape()
+	mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	mux.HandleFunc("/", IndexAPIHandler)
+	mux.HandleFunc("/candles", s.CandleGetAPIHandler)
+	mux.HandleFunc("/backtest", s.BacktestAPIHandler)
+	mux.HandleFunc("/walkforward", s.WalkForwardAPIHandler)
+	mux.HandleFunc("/surface", SurfaceAPIHandler)
+	mux.HandleFunc("/status", s.StatusAPIHandler)
+	mux.HandleFunc("/strategies", StrategiesAPIHandler)
+	mux.HandleFunc("/scrape", func(w http.ResponseWriter, req *http.Request) {
+		if s.notReady(w) {
+			return
+		}
+		if err := scrape.Scrape(s.client.Calendar); err != nil {
+			errorAPI(w, fmt.Sprintf("scrape error: %v", err), http.StatusInternalServerError)
+		}
 	})
-	logrus.Fatalln(http.ListenAndServe(fmt.Sprintf(":%d", config.Config.Port), nil))
+	return mux
+}
+
+// Run starts webserver reading the sessions with client
+func Run(client *stock.Client) {
+	logrus.Info("server start")
+	logrus.Fatalln(http.ListenAndServe(fmt.Sprintf(":%d", config.Config.Port), New(client).Handler()))
 }
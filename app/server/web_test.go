@@ -4,16 +4,22 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"math"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/oarkflow/nepse/app/server"
 	"github.com/sirupsen/logrus"
 
 	"github.com/oarkflow/nepse/app/models"
 	"github.com/oarkflow/nepse/app/models/indicator"
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/nepse"
 	"github.com/oarkflow/nepse/stock"
+	"github.com/oarkflow/nepse/techan"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -23,46 +29,18 @@ import (
 var backTestParam = models.BackTestParam{
 	Symbol: "VOO",
 	Period: 500,
-	Ema: &indicator.EmaBacktestParam{
-		EmaShortLow:  5,
-		EmaShortHigh: 15,
-		EmaLongLow:   15,
-		EmaLongHigh:  30,
-	},
-	BB: &indicator.BBBacktestParam{
-		BBnLow:  10,
-		BBnHigh: 30,
-		BBkLow:  1.5,
-		BBkHigh: 2.5,
-	},
-	Macd: &indicator.MacdBacktestParam{
-		MacdFastLow:    5,
-		MacdFastHigh:   20,
-		MacdSlowLow:    20,
-		MacdSlowHigh:   35,
-		MacdSignalLow:  5,
-		MacdSignalHigh: 20,
-	},
-	Rsi: &indicator.RsiBacktestParam{
-		RsiPeriodLow:      5,
-		RsiPeriodHigh:     50,
-		RsiBuyThreadLow:   20,
-		RsiBuyThreadHigh:  35,
-		RsiSellThreadLow:  65,
-		RsiSellThreadHigh: 80,
-	},
-	Willr: &indicator.WillrBacktestParam{
-		WillrPeriodLow:      5,
-		WillrPeriodHigh:     50,
-		WillrBuyThreadLow:   -90,
-		WillrBuyThreadHigh:  -75,
-		WillrSellThreadLow:  -25,
-		WillrSellThreadHigh: -10,
+	Ranges: map[string]indicator.Ranges{
+		"ema":   {"short": {Low: 5, High: 15}, "long": {Low: 15, High: 30}},
+		"bb":    {"n": {Low: 10, High: 30}, "k": {Low: 1.5, High: 2.5}},
+		"macd":  {"fast": {Low: 5, High: 20}, "slow": {Low: 20, High: 35}, "signal": {Low: 5, High: 20}},
+		"rsi":   {"period": {Low: 5, High: 50}, "buy": {Low: 20, High: 35}, "sell": {Low: 65, High: 80}},
+		"willr": {"period": {Low: 5, High: 50}, "buy": {Low: -90, High: -75}, "sell": {Low: -25, High: -10}},
 	},
 }
 
 type ModelsTestSuite struct {
 	suite.Suite
+	Server  *server.Server
 	Candles *models.Candles
 	Op      *models.OptimizedParam
 }
@@ -73,19 +51,11 @@ func (suite *ModelsTestSuite) SetupSuite() {
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 
-	models.DB.AutoMigrate(
-		&models.Candle{},
-		&models.OptimizedParam{},
-		&indicator.EmaSignal{},
-		&indicator.BBSignal{},
-		&indicator.MacdSignal{},
-		&indicator.RsiSignal{},
-		&indicator.WillrSignal{},
-	)
-
-	adjStock, _ := stock.GetStockData("VOO", 500, true)
-	Stock, _ := stock.GetStockData("VOO", 500, false)
-	suite.Candles = models.NewCandlesFromQuote(adjStock, Stock)
+	models.MigrateDB(models.DB)
+
+	client := fixtureClient()
+	suite.Server = server.New(client)
+	suite.Candles, _, _ = models.LoadCandles(client, "VOO", 500, techan.Daily)
 }
 
 func (suite *ModelsTestSuite) SetupTest() {
@@ -106,7 +76,7 @@ func (suite *ModelsTestSuite) TestCandleGetAPIHadler() {
 	// normal access
 	recorder := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/candles?get=true&symbol=VOO&period=100", nil)
-	server.CandleGetAPIHandler(recorder, req)
+	suite.Server.CandleGetAPIHandler(recorder, req)
 	resp := recorder.Result()
 
 	dframe := models.DataFrame{}
@@ -124,7 +94,7 @@ func (suite *ModelsTestSuite) TestCandleGetAPIHadler() {
 	// signal access
 	recorder = httptest.NewRecorder()
 	req = httptest.NewRequest("GET", "/candles?symbol=VOO&ema=true&bb=true&macd=true&rsi=true&willr=true", nil)
-	server.CandleGetAPIHandler(recorder, req)
+	suite.Server.CandleGetAPIHandler(recorder, req)
 	resp = recorder.Result()
 
 	dframe = models.DataFrame{}
@@ -135,17 +105,17 @@ func (suite *ModelsTestSuite) TestCandleGetAPIHadler() {
 	suite.Equal("application/json", resp.Header.Get("Content-Type"))
 	suite.Nil(dframe.CandleFrame)
 	suite.Nil(dframe.OptimizedParamFrame)
-	suite.NotEmpty(dframe.SignalFrame.Signals.EmaSignals)
-	suite.NotEmpty(dframe.SignalFrame.Signals.BBSignals)
-	suite.NotEmpty(dframe.SignalFrame.Signals.MacdSignals)
-	suite.NotEmpty(dframe.SignalFrame.Signals.RsiSignals)
-	suite.NotEmpty(dframe.SignalFrame.Signals.WillrSignals)
+	suite.NotEmpty(dframe.SignalFrame.Signals["ema"])
+	suite.NotEmpty(dframe.SignalFrame.Signals["bb"])
+	suite.NotEmpty(dframe.SignalFrame.Signals["macd"])
+	suite.NotEmpty(dframe.SignalFrame.Signals["rsi"])
+	suite.NotEmpty(dframe.SignalFrame.Signals["willr"])
 	suite.Nil(dframe.TradeFrame)
 
 	// when no backtest data, example GOOGL
 	recorder = httptest.NewRecorder()
 	req = httptest.NewRequest("GET", "/candles?get=true&symbol=GOOGL&period=100", nil)
-	server.CandleGetAPIHandler(recorder, req)
+	suite.Server.CandleGetAPIHandler(recorder, req)
 	resp = recorder.Result()
 
 	dframe = models.DataFrame{}
@@ -159,11 +129,27 @@ func (suite *ModelsTestSuite) TestCandleGetAPIHadler() {
 	suite.Nil(dframe.OptimizedParamFrame)
 	suite.Nil(dframe.SignalFrame)
 	suite.Nil(dframe.TradeFrame)
+	// the candles of VOO are kept
+	suite.Len(models.GetCandleFrame("VOO", 100).Candles, 100)
+
+	// weekly candles are returned, not stored
+	recorder = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/candles?get=true&symbol=GOOGL&period=100&timeframe=w", nil)
+	suite.Server.CandleGetAPIHandler(recorder, req)
+	resp = recorder.Result()
+
+	dframe = models.DataFrame{}
+	json.NewDecoder(resp.Body).Decode(&dframe)
+
+	suite.Equal(200, resp.StatusCode)
+	suite.Equal("1w", dframe.Timeframe)
+	suite.Less(len(dframe.CandleFrame.Candles), 30)
+	suite.Len(models.GetCandleFrame("GOOGL", 1000).Candles, 100)
 
 	// wrong request, when no symbol
 	recorder = httptest.NewRecorder()
 	req = httptest.NewRequest("GET", "/candles?get=true&period=100", nil)
-	server.CandleGetAPIHandler(recorder, req)
+	suite.Server.CandleGetAPIHandler(recorder, req)
 	resp = recorder.Result()
 	body, _ := io.ReadAll(resp.Body)
 
@@ -173,7 +159,7 @@ func (suite *ModelsTestSuite) TestCandleGetAPIHadler() {
 	// wrong request, when no period
 	recorder = httptest.NewRecorder()
 	req = httptest.NewRequest("GET", "/candles?get=true&symbol=VOO", nil)
-	server.CandleGetAPIHandler(recorder, req)
+	suite.Server.CandleGetAPIHandler(recorder, req)
 	resp = recorder.Result()
 	body, _ = io.ReadAll(resp.Body)
 
@@ -183,7 +169,7 @@ func (suite *ModelsTestSuite) TestCandleGetAPIHadler() {
 	// wrong request, when wrong ticker symbol, example symbol=DAMYTEST
 	recorder = httptest.NewRecorder()
 	req = httptest.NewRequest("GET", "/candles?get=true&symbol=DAMYTEST&period=100", nil)
-	server.CandleGetAPIHandler(recorder, req)
+	suite.Server.CandleGetAPIHandler(recorder, req)
 	resp = recorder.Result()
 	body, _ = io.ReadAll(resp.Body)
 
@@ -196,7 +182,7 @@ func (suite *ModelsTestSuite) TestBacktestAPIHandler() {
 	recorder := httptest.NewRecorder()
 	jsonData, _ := json.Marshal(backTestParam)
 	req := httptest.NewRequest("POST", "/backtest", bytes.NewReader(jsonData))
-	server.BacktestAPIHandler(recorder, req)
+	suite.Server.BacktestAPIHandler(recorder, req)
 	resp := recorder.Result()
 
 	dframe := models.DataFrame{}
@@ -209,6 +195,144 @@ func (suite *ModelsTestSuite) TestBacktestAPIHandler() {
 	suite.NotEmpty(dframe.OptimizedParamFrame.Param)
 	suite.Equal("VOO", dframe.OptimizedParamFrame.Param.Symbol)
 	suite.NotEmpty(dframe.TradeFrame.Trade)
+	// the metrics next to the params of every strategy
+	for _, result := range dframe.OptimizedParamFrame.Param.Results {
+		suite.Equal(len(result.Trades), result.TradeCount, result.Strategy)
+		if len(result.Trades) != 0 {
+			suite.Greater(result.Capital, 0.0, result.Strategy)
+		}
+	}
+}
+
+func (suite *ModelsTestSuite) TestBacktestAPIHandlerUnknownStrategy() {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/backtest", bytes.NewReader([]byte(`{"symbol": "VOO", "period": 500, "sma": {}}`)))
+	suite.Server.BacktestAPIHandler(recorder, req)
+
+	suite.Equal(400, recorder.Result().StatusCode)
+}
+
+func TestStrategiesAPIHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	server.StrategiesAPIHandler(recorder, httptest.NewRequest("GET", "/strategies", nil))
+	resp := recorder.Result()
+
+	var strategies []indicator.Strategy
+	json.NewDecoder(resp.Body).Decode(&strategies)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Len(t, strategies, len(indicator.Strategies()))
+	assert.Equal(t, "ema", strategies[0].Name)
+	assert.Equal(t, "short", strategies[0].Params[0].Name)
+}
+
+func (suite *ModelsTestSuite) TestWalkForwardAPIHandler() {
+	recorder := httptest.NewRecorder()
+	wf := models.WalkForwardParam{BackTestParam: backTestParam, Train: 200, Test: 100, Anchored: true}
+	wf.Ranges = map[string]indicator.Ranges{"ema": backTestParam.Ranges["ema"]}
+	jsonData, _ := json.Marshal(wf)
+	req := httptest.NewRequest("POST", "/walkforward", bytes.NewReader(jsonData))
+	suite.Server.WalkForwardAPIHandler(recorder, req)
+	resp := recorder.Result()
+
+	result := models.WalkForwardResult{}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	suite.Equal(200, resp.StatusCode)
+	suite.Equal("application/json", resp.Header.Get("Content-Type"))
+	suite.Equal("VOO", result.Symbol)
+	suite.True(result.Anchored)
+	suite.Len(result.Strategies, 1)
+	suite.Equal("ema", result.Strategies[0].Strategy)
+	suite.Len(result.Strategies[0].Folds, 3)
+	suite.Equal(result.Strategies[0].Folds[0].TrainStart, result.Strategies[0].Folds[2].TrainStart)
+
+	// too few sessions
+	recorder = httptest.NewRecorder()
+	wf.Train = 1000
+	jsonData, _ = json.Marshal(wf)
+	suite.Server.WalkForwardAPIHandler(recorder, httptest.NewRequest("POST", "/walkforward", bytes.NewReader(jsonData)))
+	suite.Equal(400, recorder.Result().StatusCode)
+}
+
+func (suite *ModelsTestSuite) TestSurfaceAPIHandler() {
+	// the surface of the last backtest
+	recorder := httptest.NewRecorder()
+	jsonData, _ := json.Marshal(backTestParam)
+	suite.Server.BacktestAPIHandler(recorder, httptest.NewRequest("POST", "/backtest", bytes.NewReader(jsonData)))
+	suite.Equal(200, recorder.Result().StatusCode)
+
+	recorder = httptest.NewRecorder()
+	server.SurfaceAPIHandler(recorder, httptest.NewRequest("GET", "/surface?symbol=VOO&strategy=bb&radius=0", nil))
+	resp := recorder.Result()
+
+	surface := indicator.Surface{}
+	json.NewDecoder(resp.Body).Decode(&surface)
+
+	suite.Equal(200, resp.StatusCode)
+	suite.Equal("application/json", resp.Header.Get("Content-Type"))
+	suite.Equal("bb", surface.Strategy)
+	suite.Equal("n", surface.X.Name)
+	suite.Equal("k", surface.Y.Name)
+	suite.Len(surface.Performance, len(surface.Y.Values))
+	suite.Equal(surface.Performance, surface.Smoothed)
+
+	for query, code := range map[string]int{
+		"symbol=TEST&strategy=bb":         404,
+		"symbol=VOO&strategy=sma":         400,
+		"symbol=VOO&strategy=bb&x=fast":   400,
+		"symbol=VOO&strategy=bb&radius=x": 400,
+	} {
+		recorder = httptest.NewRecorder()
+		server.SurfaceAPIHandler(recorder, httptest.NewRequest("GET", "/surface?"+query, nil))
+		suite.Equal(code, recorder.Result().StatusCode, query)
+	}
+	models.DeleteBacktestResult("VOO")
+}
+
+func TestCandleGetAPIHandlerNotReady(t *testing.T) {
+	// stock data is never ingested in this test binary
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/candles?get=true&symbol=ADBL&period=100", nil)
+	server.CandleGetAPIHandler(recorder, req)
+	resp := recorder.Result()
+
+	notReady := server.JSONNotReady{}
+	json.NewDecoder(resp.Body).Decode(&notReady)
+
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Equal(t, "stock data not ready", notReady.Error)
+	assert.Equal(t, nepse.IngestIdle, notReady.Ingest.Status)
+
+	recorder = httptest.NewRecorder()
+	server.StatusAPIHandler(recorder, httptest.NewRequest("GET", "/status", nil))
+	state := nepse.IngestState{}
+	json.NewDecoder(recorder.Result().Body).Decode(&state)
+
+	assert.Equal(t, 200, recorder.Result().StatusCode)
+	assert.False(t, state.Ready())
+}
+
+// fixtureClient returns a client reading sessions of VOO and GOOGL over the last three years,
+// their close swinging around a rising trend so the strategies trade
+func fixtureClient() *stock.Client {
+	var rows []nepse.StockData
+	cal := calendar.New()
+	days := cal.TradingDays(time.Now().AddDate(-3, 0, 0), time.Now())
+	for _, symbol := range []string{"VOO", "GOOGL"} {
+		for i, day := range days {
+			price := 100 + 10*math.Sin(float64(i)/8) + float64(i)/20
+			rows = append(rows, nepse.StockData{
+				Symbol: symbol, Date: day,
+				OpenPrice: price - 1, HighPrice: price + 2, LowPrice: price - 2, ClosePrice: price,
+				VWAP: price, Volume: 1000, Turnover: 1000 * price, Transactions: 10,
+			})
+		}
+	}
+	client := stock.NewClient(stock.NewMemorySource(rows...))
+	client.Calendar = cal
+	return client
 }
 
 func TestModels(t *testing.T) {
@@ -0,0 +1,188 @@
+// Package breadth computes market breadth, statistics over every symbol traded on each session,
+// used as techan indicators to filter strategies by market regime.
+package breadth
+
+import (
+	"sort"
+	"time"
+
+	"github.com/oarkflow/nepse/big"
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/techan"
+)
+
+// Breadth holds the breadth statistics of every session, each field having one value per entry of Dates
+type Breadth struct {
+	Dates []time.Time
+	// Advances, Declines and Unchanged count the symbols closing above, below and at their previous close
+	Advances  []float64
+	Declines  []float64
+	Unchanged []float64
+	// UpVolume and DownVolume are the volumes of the advancing and declining symbols
+	UpVolume   []float64
+	DownVolume []float64
+	// ADLine is the running sum of advances minus declines
+	ADLine []float64
+	// McClellan is the 19 session minus the 39 session exponential average of advances minus declines,
+	// Summation its running sum
+	McClellan []float64
+	Summation []float64
+	// NewHighs and NewLows count the symbols reaching their 52 week high or low
+	NewHighs []float64
+	NewLows  []float64
+	// AboveSMA50 and AboveSMA200 are the percent of the symbols having that many sessions closing above
+	// their simple moving average of that many sessions
+	AboveSMA50  []float64
+	AboveSMA200 []float64
+}
+
+// Compute returns the breadth of the sessions of symbols in store, of every symbol but the indices
+// when none is given. A session of a symbol is compared with the previous session of the symbol.
+func Compute(store *nepse.Store, symbols ...string) *Breadth {
+	if len(symbols) == 0 {
+		for _, symbol := range store.Symbols() {
+			if !listing.IsIndex(symbol) {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	var all []*nepse.Series
+	dates := make(map[time.Time]bool)
+	for _, symbol := range symbols {
+		series, ok := store.Series(symbol)
+		if !ok {
+			continue
+		}
+		for _, date := range series.Date {
+			dates[date] = true
+		}
+		all = append(all, series)
+	}
+
+	b := &Breadth{Dates: make([]time.Time, 0, len(dates))}
+	for date := range dates {
+		b.Dates = append(b.Dates, date)
+	}
+	sort.Slice(b.Dates, func(i, j int) bool { return b.Dates[i].Before(b.Dates[j]) })
+	n := len(b.Dates)
+	for _, field := range []*[]float64{&b.Advances, &b.Declines, &b.Unchanged, &b.UpVolume, &b.DownVolume,
+		&b.NewHighs, &b.NewLows, &b.AboveSMA50, &b.AboveSMA200} {
+		*field = make([]float64, n)
+	}
+	index := make(map[time.Time]int, n)
+	for i, date := range b.Dates {
+		index[date] = i
+	}
+
+	counted50, counted200 := make([]float64, n), make([]float64, n)
+	for _, series := range all {
+		sma50, sma200 := movingSum{n: 50}, movingSum{n: 200}
+		for i, date := range series.Date {
+			d := index[date]
+			close := series.Close[i]
+			if i > 0 {
+				switch previous := series.Close[i-1]; {
+				case close > previous:
+					b.Advances[d]++
+					b.UpVolume[d] += series.Volume[i]
+				case close < previous:
+					b.Declines[d]++
+					b.DownVolume[d] += series.Volume[i]
+				default:
+					b.Unchanged[d]++
+				}
+			}
+			if high := series.WeeksHigh52[i]; high > 0 && series.High[i] >= high {
+				b.NewHighs[d]++
+			}
+			if low := series.WeeksLow52[i]; low > 0 && series.Low[i] <= low {
+				b.NewLows[d]++
+			}
+			if average, ok := sma50.add(close); ok {
+				counted50[d]++
+				if close > average {
+					b.AboveSMA50[d]++
+				}
+			}
+			if average, ok := sma200.add(close); ok {
+				counted200[d]++
+				if close > average {
+					b.AboveSMA200[d]++
+				}
+			}
+		}
+	}
+	for d := range b.Dates {
+		b.AboveSMA50[d] = percent(b.AboveSMA50[d], counted50[d])
+		b.AboveSMA200[d] = percent(b.AboveSMA200[d], counted200[d])
+	}
+
+	b.ADLine, b.McClellan, b.Summation = make([]float64, n), make([]float64, n), make([]float64, n)
+	var line, fast, slow, summation float64
+	for d := range b.Dates {
+		net := b.Advances[d] - b.Declines[d]
+		line += net
+		if d == 0 {
+			fast, slow = net, net
+		} else {
+			// the 19 and 39 session averages of McClellan, 10% and 5% trends
+			fast += 0.1 * (net - fast)
+			slow += 0.05 * (net - slow)
+		}
+		summation += fast - slow
+		b.ADLine[d], b.McClellan[d], b.Summation[d] = line, fast-slow, summation
+	}
+	return b
+}
+
+// movingSum keeps the sum of the last n values added
+type movingSum struct {
+	n      int
+	values []float64
+	sum    float64
+}
+
+// add adds value and returns the average of the last n values, false until n values were added
+func (m *movingSum) add(value float64) (float64, bool) {
+	m.values = append(m.values, value)
+	m.sum += value
+	if len(m.values) > m.n {
+		m.sum -= m.values[0]
+		m.values = m.values[1:]
+	}
+	return m.sum / float64(m.n), len(m.values) == m.n
+}
+
+func percent(count, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return count / total * 100
+}
+
+// Indicator returns values, a field of b, as an indicator indexed like b.Dates
+func (b *Breadth) Indicator(values []float64) techan.Indicator {
+	return techan.NewFixedIndicator(values...)
+}
+
+// Aligned returns values, a field of b, as an indicator indexed like the candles of series,
+// the value of a candle being the one of the last session on or before its start, or zero before the first
+func (b *Breadth) Aligned(values []float64, series *techan.TimeSeries) techan.Indicator {
+	return alignedIndicator{breadth: b, values: values, series: series}
+}
+
+type alignedIndicator struct {
+	breadth *Breadth
+	values  []float64
+	series  *techan.TimeSeries
+}
+
+func (ai alignedIndicator) Calculate(index int) big.Decimal {
+	start := ai.series.Candles[index].Period.Start
+	n := sort.Search(len(ai.breadth.Dates), func(i int) bool { return ai.breadth.Dates[i].After(start) })
+	if n == 0 {
+		return big.ZERO
+	}
+	return big.NewDecimal(ai.values[n-1])
+}
@@ -0,0 +1,79 @@
+package breadth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/breadth"
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/techan"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func TestCompute(t *testing.T) {
+	assert := assert.New(t)
+	row := func(symbol, d string, close, high52, low52 float64) nepse.StockData {
+		return nepse.StockData{Symbol: symbol, Date: date(d), HighPrice: close, LowPrice: close, ClosePrice: close,
+			Volume: 10, WeeksHigh52: high52, WeeksLow52: low52}
+	}
+	store := nepse.NewStore(
+		row("ADBL", "2024-08-01", 100, 120, 80),
+		row("ADBL", "2024-08-02", 110, 120, 80),
+		row("ADBL", "2024-08-04", 120, 120, 80),
+		row("NABIL", "2024-08-01", 500, 600, 400),
+		row("NABIL", "2024-08-02", 490, 600, 400),
+		row("NABIL", "2024-08-04", 400, 600, 400),
+		row("NICA", "2024-08-02", 300, 400, 200),
+		row("NICA", "2024-08-04", 300, 400, 200),
+		// indices are left out
+		row("^NEPSE", "2024-08-04", 2000, 0, 0),
+	)
+	b := breadth.Compute(store)
+	assert.Equal([]time.Time{date("2024-08-01"), date("2024-08-02"), date("2024-08-04")}, b.Dates)
+	assert.Equal([]float64{0, 1, 1}, b.Advances)
+	assert.Equal([]float64{0, 1, 1}, b.Declines)
+	assert.Equal([]float64{0, 0, 1}, b.Unchanged)
+	assert.Equal([]float64{0, 10, 10}, b.UpVolume)
+	assert.Equal([]float64{0, 0, 0}, b.ADLine)
+	assert.Equal([]float64{0, 0, 1}, b.NewHighs)
+	assert.Equal([]float64{0, 0, 1}, b.NewLows)
+
+	b = breadth.Compute(store, "ADBL")
+	assert.Equal([]float64{0, 1, 2}, b.ADLine)
+	// 0.1 * 1 - 0.05 * 1, then 0.19 - 0.0975
+	assert.InDeltaSlice([]float64{0, 0.05, 0.0925}, b.McClellan, 1e-9)
+	assert.InDeltaSlice([]float64{0, 0.05, 0.1425}, b.Summation, 1e-9)
+	assert.InDelta(2, b.Indicator(b.ADLine).Calculate(2).Float(), 1e-9)
+}
+
+func TestAboveSMA(t *testing.T) {
+	assert := assert.New(t)
+	store := nepse.NewStore()
+	start := date("2024-01-01")
+	for i := 0; i < 60; i++ {
+		store.Add(
+			nepse.StockData{Symbol: "UP", Date: start.AddDate(0, 0, i), ClosePrice: float64(100 + i)},
+			nepse.StockData{Symbol: "DOWN", Date: start.AddDate(0, 0, i), ClosePrice: float64(100 - i)},
+		)
+	}
+	// a symbol without 50 sessions is not counted
+	store.Add(nepse.StockData{Symbol: "NEW", Date: start.AddDate(0, 0, 59), ClosePrice: 1000})
+	b := breadth.Compute(store)
+	assert.Zero(b.AboveSMA50[48])
+	assert.Equal(50.0, b.AboveSMA50[49])
+	assert.Equal(50.0, b.AboveSMA50[59])
+	assert.Zero(b.AboveSMA200[59])
+
+	// a regime filter on the candles of a symbol
+	ts := store.TimeSeries("UP", start.AddDate(0, 0, 58), time.Time{})
+	above := b.Aligned(b.AboveSMA50, ts)
+	assert.InDelta(50, above.Calculate(1).Float(), 1e-9)
+	rule := techan.Under(techan.NewConstantIndicator(40), above)
+	assert.True(rule.IsSatisfied(1, nil))
+}
@@ -0,0 +1,169 @@
+// Package bsdate converts dates between the Gregorian calendar (AD) and Bikram Sambat (BS),
+// the official calendar of Nepal, and knows the Nepali fiscal year that runs from Shrawan to Asar.
+//
+// BS month lengths are not computed by a rule, they are published year by year,
+// so conversions are limited to the years listed in monthDays.
+package bsdate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Month is a month of the Bikram Sambat year, Baisakh is 1
+type Month int
+
+const (
+	Baisakh Month = 1 + iota
+	Jestha
+	Asar
+	Shrawan
+	Bhadra
+	Asoj
+	Kartik
+	Mangsir
+	Poush
+	Magh
+	Falgun
+	Chaitra
+)
+
+var monthNames = [...]string{
+	"Baisakh", "Jestha", "Asar", "Shrawan", "Bhadra", "Asoj",
+	"Kartik", "Mangsir", "Poush", "Magh", "Falgun", "Chaitra",
+}
+
+func (m Month) String() string {
+	if m < Baisakh || m > Chaitra {
+		return fmt.Sprintf("%%!Month(%d)", int(m))
+	}
+	return monthNames[m-1]
+}
+
+// ErrOutOfRange is returned for dates outside the years the month table covers
+var ErrOutOfRange = errors.New("bsdate: date out of supported range")
+
+// Date is a Bikram Sambat calendar date
+type Date struct {
+	Year  int
+	Month Month
+	Day   int
+}
+
+// New returns the BS date year-month-day, after checking it exists
+func New(year int, month Month, day int) (Date, error) {
+	days, err := DaysInMonth(year, month)
+	if err != nil {
+		return Date{}, err
+	}
+	if day < 1 || day > days {
+		return Date{}, fmt.Errorf("bsdate: %s %d has %d days, not %d", month, year, days, day)
+	}
+	return Date{Year: year, Month: month, Day: day}, nil
+}
+
+// DaysInMonth returns the number of days of month in the BS year
+func DaysInMonth(year int, month Month) (int, error) {
+	if year < MinYear || year > MaxYear {
+		return 0, ErrOutOfRange
+	}
+	if month < Baisakh || month > Chaitra {
+		return 0, fmt.Errorf("bsdate: bad month %d", int(month))
+	}
+	return int(monthDays[year-MinYear][month-1]), nil
+}
+
+// FromAD returns the BS date of the calendar day of t, in the location of t
+func FromAD(t time.Time) (Date, error) {
+	days := int(day(t).Sub(epoch).Hours() / 24)
+	if days < 0 || days >= yearStart[len(yearStart)-1] {
+		return Date{}, ErrOutOfRange
+	}
+	// index of the first year starting after days, the date is in the year before it
+	i := sort.SearchInts(yearStart, days+1) - 1
+	days -= yearStart[i]
+	month := Baisakh
+	for _, length := range monthDays[i] {
+		if days < int(length) {
+			break
+		}
+		days -= int(length)
+		month++
+	}
+	return Date{Year: MinYear + i, Month: month, Day: days + 1}, nil
+}
+
+// FromUnixMilli returns the BS date of a Unix time in milliseconds, as used by Candle.Time.
+// The day is taken in UTC, like the dates of the CSV files.
+func FromUnixMilli(msec int64) (Date, error) {
+	return FromAD(time.UnixMilli(msec).UTC())
+}
+
+// AD returns the Gregorian date of d, at midnight UTC
+func (d Date) AD() (time.Time, error) {
+	if _, err := New(d.Year, d.Month, d.Day); err != nil {
+		return time.Time{}, err
+	}
+	days := yearStart[d.Year-MinYear] + d.Day - 1
+	for _, length := range monthDays[d.Year-MinYear][:d.Month-1] {
+		days += int(length)
+	}
+	return epoch.AddDate(0, 0, days), nil
+}
+
+// FiscalYear returns the BS year the fiscal year of d starts in,
+// the Nepali fiscal year runs from 1 Shrawan to the end of Asar of the next year
+func (d Date) FiscalYear() int {
+	if d.Month < Shrawan {
+		return d.Year - 1
+	}
+	return d.Year
+}
+
+// FiscalYearLabel formats the fiscal year starting in BS year start the usual way, like "2081/82"
+func FiscalYearLabel(start int) string {
+	return fmt.Sprintf("%d/%02d", start, (start+1)%100)
+}
+
+// FiscalQuarter returns the quarter of the fiscal year of d, 1 for Shrawan to Asoj
+func (d Date) FiscalQuarter() int {
+	return (int(d.Month+12-Shrawan)%12)/3 + 1
+}
+
+// Before reports whether d is before e
+func (d Date) Before(e Date) bool {
+	if d.Year != e.Year {
+		return d.Year < e.Year
+	}
+	if d.Month != e.Month {
+		return d.Month < e.Month
+	}
+	return d.Day < e.Day
+}
+
+// String formats d as ISO, like 2081-04-01
+func (d Date) String() string {
+	return d.Format(ISO)
+}
+
+// MarshalText implements encoding.TextMarshaler, used as json
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Date) UnmarshalText(text []byte) error {
+	date, err := Parse(ISO, string(text))
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// day returns midnight UTC of the calendar day of t
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
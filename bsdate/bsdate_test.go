@@ -0,0 +1,103 @@
+package bsdate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/bsdate"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func TestConvert(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		ad string
+		bs bsdate.Date
+	}{
+		{"1943-04-14", bsdate.Date{Year: 2000, Month: bsdate.Baisakh, Day: 1}},
+		{"2020-04-13", bsdate.Date{Year: 2077, Month: bsdate.Baisakh, Day: 1}},
+		{"2021-07-16", bsdate.Date{Year: 2078, Month: bsdate.Shrawan, Day: 1}},
+		{"2023-04-14", bsdate.Date{Year: 2080, Month: bsdate.Baisakh, Day: 1}},
+		{"2024-04-12", bsdate.Date{Year: 2080, Month: bsdate.Chaitra, Day: 30}},
+		{"2024-07-16", bsdate.Date{Year: 2081, Month: bsdate.Shrawan, Day: 1}},
+		{"2024-08-06", bsdate.Date{Year: 2081, Month: bsdate.Shrawan, Day: 22}},
+		{"2025-04-13", bsdate.Date{Year: 2081, Month: bsdate.Chaitra, Day: 31}},
+		{"2025-04-14", bsdate.Date{Year: 2082, Month: bsdate.Baisakh, Day: 1}},
+		{"2025-07-17", bsdate.Date{Year: 2082, Month: bsdate.Shrawan, Day: 1}},
+		{"2026-02-15", bsdate.Date{Year: 2082, Month: bsdate.Falgun, Day: 3}},
+		{"2026-04-14", bsdate.Date{Year: 2083, Month: bsdate.Baisakh, Day: 1}},
+		{"2026-10-18", bsdate.Date{Year: 2083, Month: bsdate.Kartik, Day: 2}},
+	}
+	for _, c := range cases {
+		bs, err := bsdate.FromAD(date(c.ad))
+		assert.Nil(err)
+		assert.Equal(c.bs, bs, c.ad)
+		ad, err := c.bs.AD()
+		assert.Nil(err)
+		assert.Equal(date(c.ad), ad, c.bs.String())
+	}
+
+	// the calendar day counts, not the instant
+	kathmandu := time.FixedZone("NPT", 5*3600+45*60)
+	bs, _ := bsdate.FromAD(time.Date(2024, 8, 6, 0, 30, 0, 0, kathmandu))
+	assert.Equal(22, bs.Day)
+	bs, _ = bsdate.FromUnixMilli(date("2024-08-06").Unix() * 1000)
+	assert.Equal(22, bs.Day)
+
+	_, err := bsdate.FromAD(date("1943-04-13"))
+	assert.ErrorIs(err, bsdate.ErrOutOfRange)
+	// the end of 2090 BS
+	_, err = bsdate.FromAD(date("2034-04-14"))
+	assert.ErrorIs(err, bsdate.ErrOutOfRange)
+	_, err = bsdate.New(2081, bsdate.Poush, 30)
+	assert.NotNil(err)
+}
+
+func TestFiscalYear(t *testing.T) {
+	assert := assert.New(t)
+	asar := bsdate.Date{Year: 2081, Month: bsdate.Asar, Day: 31}
+	shrawan := bsdate.Date{Year: 2081, Month: bsdate.Shrawan, Day: 1}
+	assert.Equal(2080, asar.FiscalYear())
+	assert.Equal(4, asar.FiscalQuarter())
+	assert.Equal(2081, shrawan.FiscalYear())
+	assert.Equal(1, shrawan.FiscalQuarter())
+	assert.Equal(3, bsdate.Date{Year: 2081, Month: bsdate.Magh, Day: 1}.FiscalQuarter())
+	assert.Equal("2081/82", bsdate.FiscalYearLabel(shrawan.FiscalYear()))
+	assert.Equal("2099/00", bsdate.FiscalYearLabel(2099))
+}
+
+func TestFormatParse(t *testing.T) {
+	assert := assert.New(t)
+	d := bsdate.Date{Year: 2081, Month: bsdate.Shrawan, Day: 2}
+	assert.Equal("2081-04-02", d.String())
+	assert.Equal("2 Shrawan 2081", d.Format(bsdate.Long))
+	assert.Equal("2/4/2081", d.Format("2/1/2006"))
+
+	for layout, value := range map[string]string{
+		bsdate.ISO:   "2081-04-02",
+		bsdate.Long:  "2 shrawan 2081",
+		"2/1/2006":   "2/4/2081",
+		"2006.01.02": "2081.04.02",
+	} {
+		parsed, err := bsdate.Parse(layout, value)
+		assert.Nil(err, value)
+		assert.Equal(d, parsed, value)
+	}
+
+	_, err := bsdate.Parse(bsdate.ISO, "2081-04-32x")
+	assert.NotNil(err)
+	_, err = bsdate.Parse(bsdate.ISO, "2081-13-01")
+	assert.NotNil(err)
+	_, err = bsdate.Parse(bsdate.Long, "2 Sawan 2081")
+	assert.NotNil(err)
+
+	var text bsdate.Date
+	assert.Nil(text.UnmarshalText([]byte("2081-04-02")))
+	assert.Equal(d, text)
+}
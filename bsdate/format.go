@@ -0,0 +1,114 @@
+package bsdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Layouts use the elements of the time package reference date,
+// "2006" for the year, "01" or "1" for the month, "02" or "2" for the day
+// and "January" for the month name, anything else is copied as is.
+const (
+	ISO  = "2006-01-02"
+	Long = "2 January 2006"
+)
+
+// layout elements, longest first so "2006" is not read as "2"
+var elements = []string{"January", "2006", "01", "02", "1", "2"}
+
+// nextElement returns the element layout starts with, or "" for a literal byte
+func nextElement(layout string) string {
+	for _, element := range elements {
+		if strings.HasPrefix(layout, element) {
+			return element
+		}
+	}
+	return ""
+}
+
+// Format returns d formatted with layout
+func (d Date) Format(layout string) string {
+	var b strings.Builder
+	for layout != "" {
+		element := nextElement(layout)
+		switch element {
+		case "January":
+			b.WriteString(d.Month.String())
+		case "2006":
+			fmt.Fprintf(&b, "%04d", d.Year)
+		case "01":
+			fmt.Fprintf(&b, "%02d", int(d.Month))
+		case "1":
+			b.WriteString(strconv.Itoa(int(d.Month)))
+		case "02":
+			fmt.Fprintf(&b, "%02d", d.Day)
+		case "2":
+			b.WriteString(strconv.Itoa(d.Day))
+		default:
+			b.WriteByte(layout[0])
+			layout = layout[1:]
+			continue
+		}
+		layout = layout[len(element):]
+	}
+	return b.String()
+}
+
+// Parse reads a BS date formatted with layout, month names are matched ignoring case
+func Parse(layout, value string) (Date, error) {
+	var d Date
+	input := value
+	for layout != "" {
+		element := nextElement(layout)
+		var err error
+		switch element {
+		case "January":
+			d.Month, value, err = parseMonthName(value)
+		case "2006":
+			d.Year, value, err = parseNumber(value, 4, 4)
+		case "01", "1":
+			var month int
+			month, value, err = parseNumber(value, len(element), 2)
+			d.Month = Month(month)
+		case "02", "2":
+			d.Day, value, err = parseNumber(value, len(element), 2)
+		default:
+			if value == "" || value[0] != layout[0] {
+				return Date{}, fmt.Errorf("bsdate: cannot parse %q as %q", input, layout)
+			}
+			value, layout = value[1:], layout[1:]
+			continue
+		}
+		if err != nil {
+			return Date{}, fmt.Errorf("bsdate: cannot parse %q as %q: %w", input, layout, err)
+		}
+		layout = layout[len(element):]
+	}
+	if value != "" {
+		return Date{}, fmt.Errorf("bsdate: extra text %q after date", value)
+	}
+	return New(d.Year, d.Month, d.Day)
+}
+
+// parseNumber reads between min and max digits from the start of value
+func parseNumber(value string, min, max int) (int, string, error) {
+	n := 0
+	for n < max && n < len(value) && value[n] >= '0' && value[n] <= '9' {
+		n++
+	}
+	if n < min {
+		return 0, value, fmt.Errorf("expected %d digits", min)
+	}
+	number, err := strconv.Atoi(value[:n])
+	return number, value[n:], err
+}
+
+func parseMonthName(value string) (Month, string, error) {
+	for i, name := range monthNames {
+		if len(value) >= len(name) && strings.EqualFold(value[:len(name)], name) {
+			return Month(i + 1), value[len(name):], nil
+		}
+	}
+	return 0, value, fmt.Errorf("unknown month name")
+}
@@ -0,0 +1,157 @@
+// Package calendar answers which dates NEPSE holds trading sessions on.
+// NEPSE trades Sunday to Thursday, except on the holidays listed in a holiday file.
+package calendar
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Holiday is a weekday the market is closed on
+type Holiday struct {
+	Date time.Time `json:"date"`
+	Name string    `json:"name"`
+}
+
+// Calendar knows the trading days of the market
+type Calendar struct {
+	weekend  map[time.Weekday]bool
+	holidays map[string]Holiday
+}
+
+// New returns a NEPSE calendar, closed on Friday, Saturday and the given holidays
+func New(holidays ...Holiday) *Calendar {
+	c := &Calendar{
+		weekend:  map[time.Weekday]bool{time.Friday: true, time.Saturday: true},
+		holidays: make(map[string]Holiday, len(holidays)),
+	}
+	for _, holiday := range holidays {
+		c.AddHoliday(holiday)
+	}
+	return c
+}
+
+// Load reads a holiday file, one "YYYY-MM-DD,name" line per holiday, lines starting with # are skipped
+func Load(path string) (*Calendar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read reads holidays in the format of Load
+func Read(r io.Reader) (*Calendar, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	c := New()
+	for i, record := range records {
+		date, err := time.Parse(time.DateOnly, strings.TrimSpace(record[0]))
+		if err != nil {
+			// header line
+			if i == 0 {
+				continue
+			}
+			return nil, err
+		}
+		holiday := Holiday{Date: date}
+		if len(record) > 1 {
+			holiday.Name = strings.TrimSpace(record[1])
+		}
+		c.AddHoliday(holiday)
+	}
+	return c, nil
+}
+
+// AddHoliday closes the market on holiday.Date
+func (c *Calendar) AddHoliday(holiday Holiday) {
+	holiday.Date = day(holiday.Date)
+	c.holidays[key(holiday.Date)] = holiday
+}
+
+// Holidays returns the holidays in date order
+func (c *Calendar) Holidays() []Holiday {
+	holidays := make([]Holiday, 0, len(c.holidays))
+	for _, holiday := range c.holidays {
+		holidays = append(holidays, holiday)
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
+
+// IsHoliday reports whether t falls on a listed holiday
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	_, ok := c.holidays[key(t)]
+	return ok
+}
+
+// IsTradingDay reports whether the market holds a session on the date of t
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	return !c.weekend[t.Weekday()] && !c.IsHoliday(t)
+}
+
+// NextTradingDay returns the first trading day after the date of t
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	d := day(t).AddDate(0, 0, 1)
+	for !c.IsTradingDay(d) {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// PrevTradingDay returns the last trading day before the date of t
+func (c *Calendar) PrevTradingDay(t time.Time) time.Time {
+	d := day(t).AddDate(0, 0, -1)
+	for !c.IsTradingDay(d) {
+		d = d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+// TradingDays lists the trading days between from and to, both included
+func (c *Calendar) TradingDays(from, to time.Time) []time.Time {
+	var days []time.Time
+	for d := day(from); !d.After(day(to)); d = d.AddDate(0, 0, 1) {
+		if c.IsTradingDay(d) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// TradingDaysBetween counts the trading days between from and to, both included
+func (c *Calendar) TradingDaysBetween(from, to time.Time) int {
+	return len(c.TradingDays(from, to))
+}
+
+// SessionsBack returns the first day of the n trading sessions ending on the date of t,
+// t itself counts when it is a trading day
+func (c *Calendar) SessionsBack(t time.Time, n int) time.Time {
+	d := day(t)
+	if !c.IsTradingDay(d) {
+		d = c.PrevTradingDay(d)
+	}
+	for i := 1; i < n; i++ {
+		d = c.PrevTradingDay(d)
+	}
+	return d
+}
+
+// day truncates t to midnight UTC of its calendar date
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func key(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
@@ -0,0 +1,75 @@
+package calendar_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/calendar"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+const holidays = `# comment
+date,name
+2024-08-07,Test Holiday
+2024-08-11, Another Holiday
+`
+
+func TestCalendar(t *testing.T) {
+	assert := assert.New(t)
+	cal, err := calendar.Read(strings.NewReader(holidays))
+	assert.Nil(err)
+	assert.Len(cal.Holidays(), 2)
+	assert.Equal("Another Holiday", cal.Holidays()[1].Name)
+
+	assert.True(cal.IsTradingDay(date("2024-08-04")))  // Sunday
+	assert.True(cal.IsTradingDay(date("2024-08-08")))  // Thursday
+	assert.False(cal.IsTradingDay(date("2024-08-09"))) // Friday
+	assert.False(cal.IsTradingDay(date("2024-08-10"))) // Saturday
+	assert.False(cal.IsTradingDay(date("2024-08-07"))) // holiday
+	// time of day and location do not matter
+	kathmandu := time.FixedZone("NPT", 5*3600+45*60)
+	assert.False(cal.IsTradingDay(time.Date(2024, 8, 7, 23, 59, 0, 0, kathmandu)))
+
+	assert.Equal(date("2024-08-08"), cal.NextTradingDay(date("2024-08-06")))
+	assert.Equal(date("2024-08-12"), cal.NextTradingDay(date("2024-08-08")))
+	assert.Equal(date("2024-08-08"), cal.PrevTradingDay(date("2024-08-12")))
+	assert.Equal(date("2024-08-06"), cal.PrevTradingDay(date("2024-08-08")))
+
+	days := cal.TradingDays(date("2024-08-04"), date("2024-08-12"))
+	assert.Equal([]time.Time{
+		date("2024-08-04"), date("2024-08-05"), date("2024-08-06"), date("2024-08-08"), date("2024-08-12"),
+	}, days)
+	assert.Equal(5, cal.TradingDaysBetween(date("2024-08-04"), date("2024-08-12")))
+	assert.Equal(0, cal.TradingDaysBetween(date("2024-08-09"), date("2024-08-11")))
+
+	assert.Equal(date("2024-08-12"), cal.SessionsBack(date("2024-08-12"), 1))
+	assert.Equal(date("2024-08-05"), cal.SessionsBack(date("2024-08-12"), 4))
+	// a closed day counts back from the session before it
+	assert.Equal(date("2024-08-06"), cal.SessionsBack(date("2024-08-10"), 2))
+}
+
+func TestRead(t *testing.T) {
+	_, err := calendar.Read(strings.NewReader("date,name\n2024-13-01,bad\n"))
+	assert.NotNil(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	cal, err := calendar.Load("../data/holidays.csv")
+	assert.Nil(err)
+	// Constitution Day, Asoj 3 2080
+	assert.True(cal.IsHoliday(date("2023-09-20")))
+	assert.False(cal.IsTradingDay(date("2023-09-20")))
+	// NEPSE traded on Loktantra Day 2080
+	assert.True(cal.IsTradingDay(date("2023-04-24")))
+	for _, holiday := range cal.Holidays() {
+		assert.NotContains([]time.Weekday{time.Friday, time.Saturday}, holiday.Date.Weekday(), holiday.Name)
+	}
+}
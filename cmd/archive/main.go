@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/oarkflow/nepse/config"
+	"github.com/oarkflow/nepse/nepse"
+)
+
+// archive writes the daily CSV files as a single compressed archive, loaded when the CSV directory
+// does not exist, or built into the binary with -tags nepse_archive, e.g.
+//
+//	go run ./cmd/archive -out nepse/nepse.archive && go build -tags nepse_archive
+func main() {
+	config.InitConfig()
+
+	dir := flag.String("dir", nepse.DataDir(), "directory of the daily CSV files")
+	out := flag.String("out", config.Config.Archive, "archive file to write")
+	flag.Parse()
+
+	data, err := nepse.LoadAllCsvFilesToMap(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "archive error: %v\n", err)
+		os.Exit(1)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "archive error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := nepse.WriteArchive(file, data); err != nil {
+		file.Close()
+		os.Remove(*out)
+		fmt.Fprintf(os.Stderr, "archive error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "archive error: %v\n", err)
+		os.Exit(1)
+	}
+	info, _ := os.Stat(*out)
+	fmt.Printf("%d dates written to %s, %d bytes\n", len(data), *out, info.Size())
+}
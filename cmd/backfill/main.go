@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/config"
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/scrape"
+)
+
+// backfill fetches the daily CSV files missing between two dates, e.g.
+//
+//	go run ./cmd/backfill -from 2021-09-01 -to 2021-12-31
+func main() {
+	config.InitConfig()
+
+	from := flag.String("from", "", "first date to backfill, YYYY-MM-DD")
+	to := flag.String("to", time.Now().Format(time.DateOnly), "last date to backfill, YYYY-MM-DD")
+	dir := flag.String("dir", nepse.DataDir(), "directory of the daily CSV files")
+	workers := flag.Int("workers", config.Config.BackfillWorkers, "dates fetched at the same time")
+	delay := flag.Duration("delay", time.Duration(config.Config.BackfillDelay)*time.Millisecond, "minimum time between two requests")
+	asJSON := flag.Bool("json", false, "print the per-date report as json")
+	flag.Parse()
+
+	fromDate, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bad -from: %v\n", err)
+		os.Exit(2)
+	}
+	toDate, err := time.Parse(time.DateOnly, *to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bad -to: %v\n", err)
+		os.Exit(2)
+	}
+
+	cal, err := calendar.Load(config.Config.Holidays)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "holidays: %v, closed on the weekends only\n", err)
+		cal = calendar.New()
+	}
+
+	results, err := scrape.Backfill(scrape.NewSource(), scrape.BackfillParam{
+		From:     fromDate,
+		To:       toDate,
+		Dir:      *dir,
+		Workers:  *workers,
+		Delay:    *delay,
+		Calendar: cal,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backfill: %v, set date_param in the [scrape] section of config.ini\n", err)
+		os.Exit(1)
+	}
+
+	counts := map[scrape.Outcome]int{}
+	for _, result := range results {
+		counts[result.Outcome]++
+	}
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(results)
+	} else {
+		for _, result := range results {
+			fmt.Printf("%s\t%s\t%s\n", result.Date, result.Outcome, result.Error)
+		}
+		fmt.Printf("written: %d, exists: %d, empty: %d, failed: %d\n",
+			counts[scrape.OutcomeWritten], counts[scrape.OutcomeExists], counts[scrape.OutcomeEmpty], counts[scrape.OutcomeFailed])
+	}
+	if counts[scrape.OutcomeFailed] > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/oarkflow/nepse/config"
+	"github.com/oarkflow/nepse/nepse"
+)
+
+// validate checks the daily CSV files with the rules of the [validate] sections of config.ini, e.g.
+//
+//	go run ./cmd/validate -json > report.json
+func main() {
+	config.InitConfig()
+
+	dir := flag.String("dir", nepse.DataDir(), "directory of the daily CSV files")
+	quarantine := flag.Bool("quarantine", config.Config.ValidateQuarantine, "count the rows the indexer would quarantine")
+	asJSON := flag.Bool("json", false, "print the report as json")
+	flag.Parse()
+
+	validator := nepse.NewValidatorFromConfig()
+	validator.Quarantine = *quarantine
+	report, err := validator.ValidateDir(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validate error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(report)
+	} else {
+		for _, finding := range report.Findings {
+			fmt.Printf("%s\t%d\t%s\t%s\t%s\t%s\n",
+				finding.File, finding.Row, finding.Symbol, finding.Rule, finding.Severity, finding.Message)
+		}
+		fmt.Printf("files: %d, rows: %d, errors: %d, warnings: %d, quarantined: %d\n",
+			report.Files, report.Rows, report.Count(nepse.SeverityError), report.Count(nepse.SeverityWarning), report.Quarantined)
+	}
+	if report.Count(nepse.SeverityError) > 0 {
+		os.Exit(1)
+	}
+}
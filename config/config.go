@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"gopkg.in/ini.v1"
 )
@@ -10,10 +12,41 @@ var Config ConfList
 
 // ConfList has contents of config.ini
 type ConfList struct {
-	DBdriver string
-	DBname   string
-	Port     int
-	IP       string
+	DBdriver     string
+	DBname       string
+	Port         int
+	IP           string
+	DataDir      string
+	Manifest     string
+	Snapshot     string
+	Holidays     string
+	Actions      string
+	Archive      string
+	Symbols      string
+	Lineage      string
+	PollInterval int
+	Source       string
+
+	ScrapeSource     string
+	ScrapeURL        string
+	ScrapeSelector   string
+	ScrapeReplayDir  string
+	ScrapeRecordDir  string
+	ScrapeDateParam  string
+	ScrapeDateFormat string
+	ScrapeForm       map[string]string
+	ScrapeHeaders    map[string]string
+	BackfillWorkers  int
+	BackfillDelay    int
+
+	ValidateQuarantine bool
+	ValidateTolerance  float64
+	ValidateRules      map[string]string
+
+	IndexBaseDate  time.Time
+	IndexBaseValue float64
+
+	Fees map[string]string
 }
 
 // InitConfig initializes config settings
@@ -24,9 +57,40 @@ func InitConfig() {
 	}
 
 	Config = ConfList{
-		DBdriver: conf.Section("db").Key("driver").String(),
-		DBname:   conf.Section("db").Key("name").String(),
-		Port:     conf.Section("web").Key("port").MustInt(),
-		IP:       conf.Section("web").Key("ip").String(),
+		DBdriver:     conf.Section("db").Key("driver").String(),
+		DBname:       conf.Section("db").Key("name").String(),
+		Port:         conf.Section("web").Key("port").MustInt(),
+		IP:           conf.Section("web").Key("ip").String(),
+		DataDir:      conf.Section("data").Key("dir").MustString("./data/date"),
+		Manifest:     conf.Section("data").Key("manifest").MustString("./data/manifest.json"),
+		Snapshot:     conf.Section("data").Key("snapshot").MustString("./data/stock.snapshot"),
+		Holidays:     conf.Section("data").Key("holidays").MustString("./data/holidays.csv"),
+		Actions:      conf.Section("data").Key("corporate_actions").MustString("./data/corporate_actions.csv"),
+		Archive:      conf.Section("data").Key("archive").MustString("./data/nepse.archive"),
+		Symbols:      conf.Section("data").Key("symbols").MustString("./data/symbols.csv"),
+		Lineage:      conf.Section("data").Key("lineage").MustString("./data/lineage.csv"),
+		PollInterval: conf.Section("data").Key("poll_interval").MustInt(60),
+		Source:       conf.Section("data").Key("source").MustString("index"),
+
+		ScrapeSource:     conf.Section("scrape").Key("source").MustString("http"),
+		ScrapeURL:        conf.Section("scrape").Key("url").String(),
+		ScrapeSelector:   conf.Section("scrape").Key("selector").String(),
+		ScrapeReplayDir:  conf.Section("scrape").Key("replay_dir").String(),
+		ScrapeRecordDir:  conf.Section("scrape").Key("record_dir").String(),
+		ScrapeDateParam:  conf.Section("scrape").Key("date_param").String(),
+		ScrapeDateFormat: conf.Section("scrape").Key("date_format").String(),
+		ScrapeForm:       conf.Section("scrape.form").KeysHash(),
+		ScrapeHeaders:    conf.Section("scrape.headers").KeysHash(),
+		BackfillWorkers:  conf.Section("backfill").Key("workers").MustInt(2),
+		BackfillDelay:    conf.Section("backfill").Key("delay").MustInt(2000),
+
+		ValidateQuarantine: conf.Section("validate").Key("quarantine").MustBool(false),
+		ValidateTolerance:  conf.Section("validate").Key("previous_close_tolerance").MustFloat64(0.005),
+		ValidateRules:      conf.Section("validate.rules").KeysHash(),
+
+		IndexBaseDate:  conf.Section("index").Key("base_date").MustTimeFormat(time.DateOnly),
+		IndexBaseValue: conf.Section("index").Key("base_value").MustFloat64(100),
+
+		Fees: conf.Section("fees").KeysHash(),
 	}
 }
@@ -0,0 +1,131 @@
+package corpaction
+
+import (
+	"sort"
+	"time"
+
+	"github.com/markcheno/go-quote"
+
+	"github.com/oarkflow/nepse/big"
+	"github.com/oarkflow/nepse/techan"
+)
+
+// Factor is what the prices and the volume of a session are multiplied by to back-adjust them
+type Factor struct {
+	Price  float64
+	Volume float64
+}
+
+// ExPrice returns the theoretical price after the book close of a share that closed at cum before it,
+// (cum - cash dividend + right ratio * right price) / (1 + bonus + right ratio)
+func (a Action) ExPrice(cum float64) float64 {
+	return (cum - a.CashDividend + a.RightRatio*a.RightPrice) / a.shares()
+}
+
+// shares is the number of shares one share held before the book close turns into
+func (a Action) shares() float64 {
+	return 1 + a.BonusPercent/100 + a.RightRatio
+}
+
+// factor returns the factors of the sessions before the book close, cum being the last close before it
+func (a Action) factor(cum float64) Factor {
+	shares := a.shares()
+	f := Factor{Price: 1 / shares, Volume: shares}
+	// without a usable cum price only the share count is adjusted
+	if ex := a.ExPrice(cum); cum > 0 && ex > 0 {
+		f.Price = ex / cum
+	}
+	return f
+}
+
+// Factors returns the adjustment factors of the sessions of a series, dates in ascending order
+// and closes being the raw closes. Sessions before the book close of an action are adjusted for it,
+// actions the series has no session on or after are not applied yet.
+func Factors(actions []Action, dates []time.Time, closes []float64) []Factor {
+	factors := make([]Factor, len(dates))
+	for i := range factors {
+		factors[i] = Factor{Price: 1, Volume: 1}
+	}
+	if len(dates) == 0 {
+		return factors
+	}
+	last := day(dates[len(dates)-1])
+	for _, action := range actions {
+		bookClose := day(action.BookClose)
+		if bookClose.After(last) {
+			continue
+		}
+		// number of sessions before the book close
+		n := sort.Search(len(dates), func(i int) bool { return !day(dates[i]).Before(bookClose) })
+		if n == 0 {
+			continue
+		}
+		f := action.factor(closes[n-1])
+		for i := 0; i < n; i++ {
+			factors[i].Price *= f.Price
+			factors[i].Volume *= f.Volume
+		}
+	}
+	return factors
+}
+
+// AdjustQuote returns a back-adjusted copy of q, which must be in date order, q itself is not changed
+func AdjustQuote(q *quote.Quote, actions []Action) *quote.Quote {
+	return ApplyQuote(q, Factors(actions, q.Date, q.Close))
+}
+
+// ApplyQuote returns a copy of q with the factors of every session applied, see Factors
+func ApplyQuote(q *quote.Quote, factors []Factor) *quote.Quote {
+	adjusted := quote.NewQuote(q.Symbol, len(q.Date))
+	adjusted.Precision = q.Precision
+	for i, f := range factors {
+		adjusted.Date[i] = q.Date[i]
+		adjusted.Open[i] = q.Open[i] * f.Price
+		adjusted.High[i] = q.High[i] * f.Price
+		adjusted.Low[i] = q.Low[i] * f.Price
+		adjusted.Close[i] = q.Close[i] * f.Price
+		adjusted.Volume[i] = q.Volume[i] * f.Volume
+	}
+	return &adjusted
+}
+
+// AdjustTimeSeries returns a back-adjusted copy of ts, the candles of ts are not changed
+func AdjustTimeSeries(ts *techan.TimeSeries, actions []Action) *techan.TimeSeries {
+	dates := make([]time.Time, len(ts.Candles))
+	closes := make([]float64, len(ts.Candles))
+	for i, candle := range ts.Candles {
+		dates[i] = candle.Period.Start
+		closes[i] = candle.ClosePrice.Float()
+	}
+	return ApplyTimeSeries(ts, Factors(actions, dates, closes))
+}
+
+// ApplyTimeSeries returns a copy of ts with the factors of every candle applied, see Factors
+func ApplyTimeSeries(ts *techan.TimeSeries, factors []Factor) *techan.TimeSeries {
+	adjusted := techan.NewTimeSeries()
+	for i, f := range factors {
+		candle := *ts.Candles[i]
+		price, volume := big.NewDecimal(f.Price), big.NewDecimal(f.Volume)
+		candle.OpenPrice = candle.OpenPrice.Mul(price)
+		candle.MaxPrice = candle.MaxPrice.Mul(price)
+		candle.MinPrice = candle.MinPrice.Mul(price)
+		candle.ClosePrice = candle.ClosePrice.Mul(price)
+		if !candle.VWAP.NaN() {
+			candle.VWAP = candle.VWAP.Mul(price)
+		}
+		// the turnover, the amount traded, is the same before and after the adjustment
+		candle.Volume = candle.Volume.Mul(volume)
+		adjusted.AddCandle(&candle)
+	}
+	return adjusted
+}
+
+// AdjustQuote back-adjusts q for the actions of q.Symbol
+func (s *Store) AdjustQuote(q *quote.Quote) *quote.Quote {
+	return AdjustQuote(q, s.Actions(q.Symbol))
+}
+
+// AdjustTimeSeries back-adjusts ts for the actions of symbol
+func (s *Store) AdjustTimeSeries(symbol string, ts *techan.TimeSeries) *techan.TimeSeries {
+	return AdjustTimeSeries(ts, s.Actions(symbol))
+}
@@ -0,0 +1,136 @@
+// Package corpaction keeps the corporate actions of listed companies, bonus shares,
+// right shares and cash dividends, and back-adjusts price series for them so
+// that book closures do not show up as price drops.
+package corpaction
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Action is a corporate action of a company, entitled to the shareholders on the book close date
+type Action struct {
+	Symbol string `json:"symbol"`
+	// BookClose is the book close date, the market price is adjusted from that session
+	BookClose time.Time `json:"book_close"`
+	// BonusPercent is the bonus shares per 100 shares held, 10 for a 10% bonus
+	BonusPercent float64 `json:"bonus_percent,omitempty"`
+	// RightRatio is the right shares offered per share held, 0.5 for one right share for two held
+	RightRatio float64 `json:"right_ratio,omitempty"`
+	// RightPrice is the price paid per right share, usually the face value
+	RightPrice float64 `json:"right_price,omitempty"`
+	// CashDividend is the cash paid per share in rupees, 10 for a 10% dividend on a Rs 100 share
+	CashDividend float64 `json:"cash_dividend,omitempty"`
+}
+
+// Store keeps corporate actions by symbol
+type Store struct {
+	mu      sync.RWMutex
+	actions map[string][]Action
+}
+
+// NewStore returns a Store holding actions
+func NewStore(actions ...Action) *Store {
+	s := &Store{actions: make(map[string][]Action)}
+	for _, action := range actions {
+		s.Add(action)
+	}
+	return s
+}
+
+// Load reads a corporate action file with the columns
+// symbol,book_close,bonus_percent,right_ratio,right_price,cash_dividend,
+// empty columns are zero and lines starting with # are skipped
+func Load(path string) (*Store, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read reads corporate actions in the format of Load
+func Read(r io.Reader) (*Store, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	s := NewStore()
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("corporate action line %d: want at least symbol and book close date", i+1)
+		}
+		bookClose, err := time.Parse(time.DateOnly, strings.TrimSpace(record[1]))
+		if err != nil {
+			// header line
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("corporate action line %d: %w", i+1, err)
+		}
+		action := Action{Symbol: strings.TrimSpace(record[0]), BookClose: bookClose}
+		fields := []*float64{&action.BonusPercent, &action.RightRatio, &action.RightPrice, &action.CashDividend}
+		for j, field := range fields {
+			if len(record) <= j+2 || strings.TrimSpace(record[j+2]) == "" {
+				continue
+			}
+			if *field, err = strconv.ParseFloat(strings.TrimSpace(record[j+2]), 64); err != nil {
+				return nil, fmt.Errorf("corporate action line %d: %w", i+1, err)
+			}
+		}
+		s.Add(action)
+	}
+	return s, nil
+}
+
+// Add stores action, replacing an action of the same symbol and book close date
+func (s *Store) Add(action Action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	action.BookClose = day(action.BookClose)
+	actions := s.actions[action.Symbol]
+	for i := range actions {
+		if actions[i].BookClose.Equal(action.BookClose) {
+			actions[i] = action
+			return
+		}
+	}
+	actions = append(actions, action)
+	sort.Slice(actions, func(i, j int) bool { return actions[i].BookClose.Before(actions[j].BookClose) })
+	s.actions[action.Symbol] = actions
+}
+
+// Actions returns the actions of symbol in book close order
+func (s *Store) Actions(symbol string) []Action {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Action(nil), s.actions[symbol]...)
+}
+
+// Symbols returns the symbols having at least one action
+func (s *Store) Symbols() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	symbols := make([]string, 0, len(s.actions))
+	for symbol := range s.actions {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// day truncates t to midnight UTC of its calendar date
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,108 @@
+package corpaction_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markcheno/go-quote"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/big"
+	"github.com/oarkflow/nepse/corpaction"
+	"github.com/oarkflow/nepse/techan"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+const actions = `# comment
+symbol,book_close,bonus_percent,right_ratio,right_price,cash_dividend
+NABIL,2024-08-06,10,,,
+ADBL,2024-08-05,,0.5,100,10
+ADBL, 2024-07-01 ,5
+`
+
+func TestRead(t *testing.T) {
+	assert := assert.New(t)
+	store, err := corpaction.Read(strings.NewReader(actions))
+	assert.Nil(err)
+	assert.Equal([]string{"ADBL", "NABIL"}, store.Symbols())
+	adbl := store.Actions("ADBL")
+	assert.Len(adbl, 2)
+	// book close order
+	assert.Equal(date("2024-07-01"), adbl[0].BookClose)
+	assert.Equal(5.0, adbl[0].BonusPercent)
+	assert.Equal(corpaction.Action{Symbol: "ADBL", BookClose: date("2024-08-05"), RightRatio: 0.5, RightPrice: 100, CashDividend: 10}, adbl[1])
+	assert.Empty(store.Actions("UNKNOWN"))
+
+	_, err = corpaction.Read(strings.NewReader("NABIL,2024-08-06,ten\n"))
+	assert.NotNil(err)
+}
+
+func TestAdjustQuote(t *testing.T) {
+	assert := assert.New(t)
+	store := corpaction.NewStore(
+		corpaction.Action{Symbol: "NABIL", BookClose: date("2024-08-06"), BonusPercent: 10},
+		// not reached by the series yet
+		corpaction.Action{Symbol: "NABIL", BookClose: date("2024-09-01"), BonusPercent: 100},
+	)
+	raw := quote.NewQuote("NABIL", 3)
+	for i, d := range []string{"2024-08-04", "2024-08-05", "2024-08-06"} {
+		raw.Date[i] = date(d)
+		raw.Close[i] = 110
+		raw.High[i] = 121
+		raw.Volume[i] = 1000
+	}
+	raw.Close[2] = 100
+
+	adjusted := store.AdjustQuote(&raw)
+	assert.InDeltaSlice([]float64{100, 100, 100}, adjusted.Close, 1e-9)
+	assert.InDeltaSlice([]float64{110, 110, 121}, adjusted.High, 1e-9)
+	assert.InDeltaSlice([]float64{1100, 1100, 1000}, adjusted.Volume, 1e-9)
+	// the raw series is kept
+	assert.Equal([]float64{110, 110, 100}, raw.Close)
+}
+
+func TestFactors(t *testing.T) {
+	assert := assert.New(t)
+	rights := corpaction.Action{BookClose: date("2024-08-05"), RightRatio: 0.5, RightPrice: 100, CashDividend: 10}
+	// (200 - 10 + 0.5 * 100) / 1.5
+	assert.InDelta(160, rights.ExPrice(200), 1e-9)
+
+	dates := []time.Time{date("2024-08-01"), date("2024-08-04"), date("2024-08-05")}
+	factors := corpaction.Factors([]corpaction.Action{
+		rights,
+		{BookClose: date("2024-08-04"), BonusPercent: 25},
+	}, dates, []float64{250, 200, 160})
+	assert.InDelta(0.8*0.8, factors[0].Price, 1e-9)
+	assert.InDelta(1.5*1.25, factors[0].Volume, 1e-9)
+	assert.InDelta(0.8, factors[1].Price, 1e-9)
+	assert.Equal(corpaction.Factor{Price: 1, Volume: 1}, factors[2])
+}
+
+func TestAdjustTimeSeries(t *testing.T) {
+	assert := assert.New(t)
+	ts := techan.NewTimeSeries()
+	for i, d := range []string{"2024-08-04", "2024-08-05"} {
+		candle := techan.NewCandle(techan.NewTimePeriod(date(d), 24*time.Hour))
+		candle.OpenPrice = big.NewDecimal(110)
+		candle.MaxPrice = big.NewDecimal(110)
+		candle.MinPrice = big.NewDecimal(110)
+		candle.ClosePrice = big.NewDecimal(110 - 10*float64(i))
+		candle.Volume = big.NewDecimal(1000)
+		candle.VWAP = big.NewDecimal(110)
+		candle.Turnover = big.NewDecimal(110000)
+		ts.AddCandle(candle)
+	}
+	adjusted := corpaction.AdjustTimeSeries(ts, []corpaction.Action{{BookClose: date("2024-08-05"), BonusPercent: 10}})
+	assert.Len(adjusted.Candles, 2)
+	assert.InDelta(100, adjusted.Candles[0].ClosePrice.Float(), 1e-9)
+	assert.InDelta(1100, adjusted.Candles[0].Volume.Float(), 1e-9)
+	assert.InDelta(100, adjusted.Candles[0].VWAP.Float(), 1e-9)
+	assert.InDelta(110000, adjusted.Candles[0].Turnover.Float(), 1e-9)
+	assert.InDelta(100, adjusted.Candles[1].ClosePrice.Float(), 1e-9)
+	assert.InDelta(110, ts.Candles[0].ClosePrice.Float(), 1e-9)
+}
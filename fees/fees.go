@@ -0,0 +1,219 @@
+// Package fees models what a NEPSE trade costs: the broker commission, the SEBON fee, the DP charge
+// and the capital gains tax, so backtests report the net result of their trades.
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tier is the broker commission rate of the transactions up to UpTo rupees, a zero UpTo having no limit
+type Tier struct {
+	UpTo float64 `json:"up_to"`
+	// Rate is the commission in percent of the transaction amount
+	Rate float64 `json:"rate"`
+}
+
+// Schedule is the fees and tax of a trade
+type Schedule struct {
+	// Broker is the commission of the first tier an amount fits in, the rate applying to the whole amount
+	Broker []Tier `json:"broker"`
+	// BrokerMin is the lowest commission of a transaction
+	BrokerMin float64 `json:"broker_min"`
+	// SEBON is the SEBON fee in percent of the transaction amount
+	SEBON float64 `json:"sebon"`
+	// DP is the DP charge of selling a scrip on a day
+	DP float64 `json:"dp"`
+	// CGTShort and CGTLong are the capital gains tax in percent of the gain of the trades held
+	// less and at least LongTermDays
+	CGTShort     float64 `json:"cgt_short"`
+	CGTLong      float64 `json:"cgt_long"`
+	LongTermDays int     `json:"long_term_days"`
+}
+
+// NEPSE returns the schedule of NEPSE for individual investors
+func NEPSE() *Schedule {
+	return &Schedule{
+		Broker: []Tier{
+			{UpTo: 50000, Rate: 0.36},
+			{UpTo: 500000, Rate: 0.33},
+			{UpTo: 2000000, Rate: 0.31},
+			{UpTo: 10000000, Rate: 0.27},
+			{Rate: 0.24},
+		},
+		BrokerMin:    10,
+		SEBON:        0.015,
+		DP:           25,
+		CGTShort:     7.5,
+		CGTLong:      5,
+		LongTermDays: 365,
+	}
+}
+
+// ParseTiers reads broker tiers written as "up_to:rate" pairs separated by commas, like "50000:0.36,0:0.24",
+// a zero up_to being the tier without limit
+func ParseTiers(s string) ([]Tier, error) {
+	var tiers []Tier
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		upTo, rate, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("broker tier %q: want up_to:rate", field)
+		}
+		var tier Tier
+		var err error
+		if tier.UpTo, err = strconv.ParseFloat(strings.TrimSpace(upTo), 64); err != nil {
+			return nil, fmt.Errorf("broker tier %q: %w", field, err)
+		}
+		if tier.Rate, err = strconv.ParseFloat(strings.TrimSpace(rate), 64); err != nil {
+			return nil, fmt.Errorf("broker tier %q: %w", field, err)
+		}
+		tiers = append(tiers, tier)
+	}
+	// by limit, the tier without limit last
+	limit := func(t Tier) float64 {
+		if t.UpTo == 0 {
+			return math.Inf(1)
+		}
+		return t.UpTo
+	}
+	sort.SliceStable(tiers, func(i, j int) bool { return limit(tiers[i]) < limit(tiers[j]) })
+	return tiers, nil
+}
+
+// Parse returns NEPSE() with the values of keys, those of the [fees] section of config.ini:
+// broker (see ParseTiers), broker_min, sebon, dp, cgt_short, cgt_long and long_term_days
+func Parse(keys map[string]string) (*Schedule, error) {
+	s := NEPSE()
+	for key, value := range keys {
+		value = strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "broker":
+			s.Broker, err = ParseTiers(value)
+		case "broker_min":
+			s.BrokerMin, err = strconv.ParseFloat(value, 64)
+		case "sebon":
+			s.SEBON, err = strconv.ParseFloat(value, 64)
+		case "dp":
+			s.DP, err = strconv.ParseFloat(value, 64)
+		case "cgt_short":
+			s.CGTShort, err = strconv.ParseFloat(value, 64)
+		case "cgt_long":
+			s.CGTLong, err = strconv.ParseFloat(value, 64)
+		case "long_term_days":
+			s.LongTermDays, err = strconv.Atoi(value)
+		default:
+			err = errors.New("unknown key")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return s, nil
+}
+
+// Commission returns the broker commission of a transaction of amount rupees
+func (s *Schedule) Commission(amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	rate := 0.0
+	for _, tier := range s.Broker {
+		rate = tier.Rate
+		if tier.UpTo == 0 || amount <= tier.UpTo {
+			break
+		}
+	}
+	return math.Max(amount*rate/100, s.BrokerMin)
+}
+
+// BuyFees returns the fees of buying amount rupees of a scrip
+func (s *Schedule) BuyFees(amount float64) float64 {
+	return s.Commission(amount) + amount*s.SEBON/100
+}
+
+// SellFees returns the fees of selling amount rupees of a scrip, the DP charge included
+func (s *Schedule) SellFees(amount float64) float64 {
+	return s.Commission(amount) + amount*s.SEBON/100 + s.DP
+}
+
+// Tax returns the capital gains tax of gain on a trade held for days
+func (s *Schedule) Tax(gain float64, days int) float64 {
+	if gain <= 0 {
+		return 0
+	}
+	if days >= s.LongTermDays {
+		return gain * s.CGTLong / 100
+	}
+	return gain * s.CGTShort / 100
+}
+
+// Trade is a round trip, shares bought and later sold, and what it made
+type Trade struct {
+	Shares    float64   `json:"shares"`
+	BuyTime   time.Time `json:"buy_time"`
+	BuyPrice  float64   `json:"buy_price"`
+	SellTime  time.Time `json:"sell_time"`
+	SellPrice float64   `json:"sell_price"`
+	// HoldingDays are the calendar days from the buy to the sell
+	HoldingDays int `json:"holding_days"`
+	// Gross is the sell amount less the buy amount
+	Gross float64 `json:"gross"`
+	// Fees are the fees of the buy and of the sell
+	Fees float64 `json:"fees"`
+	// Tax is the capital gains tax of the gain after fees
+	Tax float64 `json:"tax"`
+	// Net is Gross less Fees and Tax
+	Net float64 `json:"net"`
+}
+
+// Trade returns the trade of buying shares at buyPrice on buyTime and selling them at sellPrice on sellTime.
+// A nil s is frictionless, the trade paying neither fees nor tax.
+func (s *Schedule) Trade(shares float64, buyTime time.Time, buyPrice float64, sellTime time.Time, sellPrice float64) Trade {
+	buy, sell := shares*buyPrice, shares*sellPrice
+	t := Trade{
+		Shares:      shares,
+		BuyTime:     buyTime,
+		BuyPrice:    buyPrice,
+		SellTime:    sellTime,
+		SellPrice:   sellPrice,
+		HoldingDays: int(sellTime.Sub(buyTime).Hours() / 24),
+		Gross:       sell - buy,
+	}
+	if s != nil {
+		t.Fees = s.BuyFees(buy) + s.SellFees(sell)
+		t.Tax = s.Tax(t.Gross-t.Fees, t.HoldingDays)
+	}
+	t.Net = t.Gross - t.Fees - t.Tax
+	return t
+}
+
+// Total is the sum of the results of trades
+type Total struct {
+	Trades int     `json:"trades"`
+	Gross  float64 `json:"gross"`
+	Fees   float64 `json:"fees"`
+	Tax    float64 `json:"tax"`
+	Net    float64 `json:"net"`
+}
+
+// Sum returns the total of trades
+func Sum(trades []Trade) Total {
+	total := Total{Trades: len(trades)}
+	for _, t := range trades {
+		total.Gross += t.Gross
+		total.Fees += t.Fees
+		total.Tax += t.Tax
+		total.Net += t.Net
+	}
+	return total
+}
@@ -0,0 +1,78 @@
+package fees_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/fees"
+)
+
+func TestCommission(t *testing.T) {
+	assert := assert.New(t)
+	s := fees.NEPSE()
+	// the minimum
+	assert.Equal(10.0, s.Commission(1000))
+	assert.Equal(0.0, s.Commission(0))
+	// the rate of the tier of the amount applies to all of it
+	assert.InDelta(180, s.Commission(50000), 1e-9)
+	assert.InDelta(165.0033, s.Commission(50001), 1e-9)
+	assert.InDelta(3100, s.Commission(1000000), 1e-9)
+	assert.InDelta(48000, s.Commission(20000000), 1e-9)
+
+	assert.InDelta(180+7.5, s.BuyFees(50000), 1e-9)
+	assert.InDelta(180+7.5+25, s.SellFees(50000), 1e-9)
+}
+
+func TestTrade(t *testing.T) {
+	assert := assert.New(t)
+	s := fees.NEPSE()
+	buy := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	short := s.Trade(100, buy, 500, buy.AddDate(0, 0, 30), 600)
+	assert.Equal(30, short.HoldingDays)
+	assert.Equal(10000.0, short.Gross)
+	assert.InDelta(180+7.5+198+9+25, short.Fees, 1e-9)
+	assert.InDelta((short.Gross-short.Fees)*0.075, short.Tax, 1e-9)
+	assert.InDelta(short.Gross-short.Fees-short.Tax, short.Net, 1e-9)
+
+	long := s.Trade(100, buy, 500, buy.AddDate(0, 0, 365), 600)
+	assert.InDelta((long.Gross-long.Fees)*0.05, long.Tax, 1e-9)
+
+	// a loss after fees pays no tax
+	loss := s.Trade(10, buy, 500, buy.AddDate(0, 0, 1), 502)
+	assert.Equal(0.0, loss.Tax)
+	assert.Less(loss.Net, 0.0)
+
+	// nil is frictionless
+	var none *fees.Schedule
+	free := none.Trade(100, buy, 500, buy.AddDate(0, 0, 1), 600)
+	assert.Equal(free.Gross, free.Net)
+
+	total := fees.Sum([]fees.Trade{short, long})
+	assert.Equal(2, total.Trades)
+	assert.InDelta(short.Net+long.Net, total.Net, 1e-9)
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	tiers, err := fees.ParseTiers("0:0.2, 1000:0.5,100:1")
+	assert.Nil(err)
+	assert.Equal([]fees.Tier{{UpTo: 100, Rate: 1}, {UpTo: 1000, Rate: 0.5}, {Rate: 0.2}}, tiers)
+	_, err = fees.ParseTiers("1000")
+	assert.NotNil(err)
+
+	s, err := fees.Parse(map[string]string{"dp": "0", "cgt_short": " 10", "long_term_days": "180"})
+	assert.Nil(err)
+	assert.Equal(0.0, s.DP)
+	assert.Equal(10.0, s.CGTShort)
+	assert.Equal(180, s.LongTermDays)
+	// keys left out are those of NEPSE
+	assert.Equal(fees.NEPSE().Broker, s.Broker)
+
+	_, err = fees.Parse(map[string]string{"vat": "13"})
+	assert.EqualError(err, "vat: unknown key")
+	_, err = fees.Parse(map[string]string{"sebon": "x"})
+	assert.NotNil(err)
+}
@@ -0,0 +1,138 @@
+package listing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Link is a ticker change, a rename or a merger, the history of Predecessor continuing as Successor
+type Link struct {
+	Predecessor string `json:"predecessor"`
+	Successor   string `json:"successor"`
+	// Effective is the first session of Successor after the change
+	Effective time.Time `json:"effective"`
+	// Ratio is the Successor shares received per Predecessor share, 1 for a rename
+	Ratio float64 `json:"ratio"`
+}
+
+// Lineage keeps the links between tickers
+type Lineage struct {
+	mu    sync.RWMutex
+	links []Link
+}
+
+// NewLineage returns a Lineage holding links
+func NewLineage(links ...Link) *Lineage {
+	l := &Lineage{}
+	for _, link := range links {
+		l.Add(link)
+	}
+	return l
+}
+
+// LoadLineage reads a lineage file with the columns predecessor,successor,effective,ratio,
+// an empty ratio is 1 and lines starting with # are skipped
+func LoadLineage(path string) (*Lineage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadLineage(file)
+}
+
+// ReadLineage reads links in the format of LoadLineage
+func ReadLineage(r io.Reader) (*Lineage, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	l := NewLineage()
+	for i, record := range records {
+		if len(record) < 3 {
+			return nil, fmt.Errorf("lineage line %d: want predecessor, successor and effective date", i+1)
+		}
+		effective, err := time.Parse(time.DateOnly, strings.TrimSpace(record[2]))
+		if err != nil {
+			// header line
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("lineage line %d: %w", i+1, err)
+		}
+		link := Link{
+			Predecessor: strings.TrimSpace(record[0]),
+			Successor:   strings.TrimSpace(record[1]),
+			Effective:   effective,
+			Ratio:       1,
+		}
+		if len(record) > 3 && strings.TrimSpace(record[3]) != "" {
+			if link.Ratio, err = strconv.ParseFloat(strings.TrimSpace(record[3]), 64); err != nil {
+				return nil, fmt.Errorf("lineage line %d: %w", i+1, err)
+			}
+		}
+		if link.Predecessor == "" || link.Successor == "" || link.Ratio <= 0 {
+			return nil, fmt.Errorf("lineage line %d: want two symbols and a positive ratio", i+1)
+		}
+		l.Add(link)
+	}
+	return l, nil
+}
+
+// Add stores link, replacing a link of the same predecessor and successor
+func (l *Lineage) Add(link Link) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if link.Ratio == 0 {
+		link.Ratio = 1
+	}
+	for i := range l.links {
+		if l.links[i].Predecessor == link.Predecessor && l.links[i].Successor == link.Successor {
+			l.links[i] = link
+			return
+		}
+	}
+	l.links = append(l.links, link)
+}
+
+// Predecessors returns the links into symbol, in the order they were added.
+// When several tickers merged into a new one its history follows the first of them.
+func (l *Lineage) Predecessors(symbol string) []Link {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var links []Link
+	for _, link := range l.links {
+		if link.Successor == symbol {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// Successor returns the link out of symbol, false when symbol still trades under its ticker
+func (l *Lineage) Successor(symbol string) (Link, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, link := range l.links {
+		if link.Predecessor == symbol {
+			return link, true
+		}
+	}
+	return Link{}, false
+}
+
+// Links returns every link, in the order they were added
+func (l *Lineage) Links() []Link {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]Link(nil), l.links...)
+}
@@ -0,0 +1,266 @@
+// Package listing is the symbol master: the company name, sector, instrument type,
+// listed shares, listing date and trading status of every ticker of the daily files,
+// which only carry the ticker.
+package listing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Instrument is the kind of security a ticker is
+type Instrument string
+
+const (
+	// Equity is an ordinary share
+	Equity Instrument = "equity"
+	// Promoter is a promoter share, traded under its own ticker
+	Promoter Instrument = "promoter"
+	// MutualFund is a close-ended mutual fund unit
+	MutualFund Instrument = "mutual_fund"
+	// Debenture is a corporate debenture
+	Debenture Instrument = "debenture"
+)
+
+// Status tells whether a ticker is traded
+type Status string
+
+const (
+	Active    Status = "active"
+	Suspended Status = "suspended"
+	Delisted  Status = "delisted"
+)
+
+// IndexPrefix starts the synthetic symbols of the indices computed from the listed securities, like ^NEPSE
+const IndexPrefix = "^"
+
+// IsIndex reports whether symbol is a synthetic index symbol
+func IsIndex(symbol string) bool {
+	return strings.HasPrefix(symbol, IndexPrefix)
+}
+
+// ErrUnknownSymbol is returned for tickers the master does not list
+var ErrUnknownSymbol = errors.New("unknown symbol")
+
+// Security is a ticker of the symbol master, also used as json
+type Security struct {
+	Symbol       string     `json:"symbol"`
+	Name         string     `json:"name,omitempty"`
+	Sector       string     `json:"sector,omitempty"`
+	Instrument   Instrument `json:"instrument,omitempty"`
+	ListedShares int64      `json:"listed_shares,omitempty"`
+	ListingDate  time.Time  `json:"listing_date,omitempty"`
+	Status       Status     `json:"status"`
+	// DelistingDate is the first session a delisted security no longer traded on, zero when not known
+	DelistingDate time.Time `json:"delisting_date,omitempty"`
+}
+
+// Master keeps the securities by symbol
+type Master struct {
+	mu         sync.RWMutex
+	securities map[string]Security
+}
+
+// NewMaster returns a Master listing securities
+func NewMaster(securities ...Security) *Master {
+	m := &Master{securities: make(map[string]Security)}
+	for _, security := range securities {
+		m.Add(security)
+	}
+	return m
+}
+
+// Load reads a symbol master file with the columns
+// symbol,name,sector,instrument,listed_shares,listing_date,status,delisting_date,
+// empty columns are zero, an empty status is active and lines starting with # are skipped
+func Load(path string) (*Master, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read reads securities in the format of Load
+func Read(r io.Reader) (*Master, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	m := NewMaster()
+	for i, record := range records {
+		field := func(j int) string {
+			if j < len(record) {
+				return strings.TrimSpace(record[j])
+			}
+			return ""
+		}
+		// header line
+		if i == 0 && strings.EqualFold(field(0), "symbol") {
+			continue
+		}
+		security := Security{
+			Symbol:     field(0),
+			Name:       field(1),
+			Sector:     field(2),
+			Instrument: Instrument(strings.ToLower(field(3))),
+			Status:     Status(strings.ToLower(field(6))),
+		}
+		if security.Symbol == "" {
+			return nil, fmt.Errorf("symbol master line %d: no symbol", i+1)
+		}
+		if shares := strings.ReplaceAll(field(4), ",", ""); shares != "" {
+			if security.ListedShares, err = strconv.ParseInt(shares, 10, 64); err != nil {
+				return nil, fmt.Errorf("symbol master line %d: %w", i+1, err)
+			}
+		}
+		if date := field(5); date != "" {
+			if security.ListingDate, err = time.Parse(time.DateOnly, date); err != nil {
+				return nil, fmt.Errorf("symbol master line %d: %w", i+1, err)
+			}
+		}
+		if date := field(7); date != "" {
+			if security.DelistingDate, err = time.Parse(time.DateOnly, date); err != nil {
+				return nil, fmt.Errorf("symbol master line %d: %w", i+1, err)
+			}
+		}
+		m.Add(security)
+	}
+	return m, nil
+}
+
+// Add lists security, replacing the security of the same symbol
+func (m *Master) Add(security Security) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if security.Status == "" {
+		security.Status = Active
+	}
+	m.securities[security.Symbol] = security
+}
+
+// Get returns the security of symbol
+func (m *Master) Get(symbol string) (Security, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	security, ok := m.securities[symbol]
+	return security, ok
+}
+
+// Len returns the number of securities listed
+func (m *Master) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.securities)
+}
+
+// Check returns ErrUnknownSymbol when the master lists securities but not symbol.
+// An empty master, when no symbol master file is provided, accepts every symbol,
+// index symbols are always accepted.
+func (m *Master) Check(symbol string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.securities) == 0 || IsIndex(symbol) {
+		return nil
+	}
+	if _, ok := m.securities[symbol]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
+	}
+	return nil
+}
+
+// Securities returns every security, by symbol
+func (m *Master) Securities() []Security {
+	return m.Filter(func(Security) bool { return true })
+}
+
+// Filter returns the securities keep is true for, by symbol
+func (m *Master) Filter(keep func(Security) bool) []Security {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var securities []Security
+	for _, security := range m.securities {
+		if keep(security) {
+			securities = append(securities, security)
+		}
+	}
+	sort.Slice(securities, func(i, j int) bool { return securities[i].Symbol < securities[j].Symbol })
+	return securities
+}
+
+// Group returns the securities by the key of each, by symbol within a group
+func (m *Master) Group(key func(Security) string) map[string][]Security {
+	groups := make(map[string][]Security)
+	for _, security := range m.Securities() {
+		k := key(security)
+		groups[k] = append(groups[k], security)
+	}
+	return groups
+}
+
+// BySector returns the securities grouped by sector
+func (m *Master) BySector() map[string][]Security {
+	return m.Group(func(s Security) string { return s.Sector })
+}
+
+// ByInstrument returns the securities grouped by instrument type
+func (m *Master) ByInstrument() map[Instrument][]Security {
+	groups := make(map[Instrument][]Security)
+	for _, security := range m.Securities() {
+		groups[security.Instrument] = append(groups[security.Instrument], security)
+	}
+	return groups
+}
+
+// Sectors returns the sectors of the securities, in alphabetical order
+func (m *Master) Sectors() []string {
+	sectors := make([]string, 0)
+	for sector := range m.BySector() {
+		if sector != "" {
+			sectors = append(sectors, sector)
+		}
+	}
+	sort.Strings(sectors)
+	return sectors
+}
+
+// InSector is a Filter predicate keeping the securities of sector, compared case-insensitively
+func InSector(sector string) func(Security) bool {
+	return func(s Security) bool { return strings.EqualFold(s.Sector, sector) }
+}
+
+// OfInstrument is a Filter predicate keeping the securities of the instrument types
+func OfInstrument(instruments ...Instrument) func(Security) bool {
+	return func(s Security) bool {
+		for _, instrument := range instruments {
+			if s.Instrument == instrument {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// WithStatus is a Filter predicate keeping the securities of the statuses
+func WithStatus(statuses ...Status) func(Security) bool {
+	return func(s Security) bool {
+		for _, status := range statuses {
+			if s.Status == status {
+				return true
+			}
+		}
+		return false
+	}
+}
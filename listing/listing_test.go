@@ -0,0 +1,101 @@
+package listing_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/listing"
+)
+
+const master = `# comment
+symbol,name,sector,instrument,listed_shares,listing_date,status,delisting_date
+NABIL,"Nabil Bank Limited",Commercial Banks,equity,"270,569,022",1986-01-01,
+NABILP,Nabil Bank Promoter Share,Commercial Banks,Promoter,100,,
+NICGF,NIC Asia Growth Fund,Mutual Fund,mutual_fund,,,
+UPPER,Upper Tamakoshi Hydropower,Hydro Power,equity,,,suspended
+`
+
+func TestRead(t *testing.T) {
+	assert := assert.New(t)
+	m, err := listing.Read(strings.NewReader(master))
+	assert.Nil(err)
+	assert.Equal(4, m.Len())
+	nabil, ok := m.Get("NABIL")
+	assert.True(ok)
+	assert.Equal(listing.Security{
+		Symbol:       "NABIL",
+		Name:         "Nabil Bank Limited",
+		Sector:       "Commercial Banks",
+		Instrument:   listing.Equity,
+		ListedShares: 270569022,
+		ListingDate:  time.Date(1986, 1, 1, 0, 0, 0, 0, time.UTC),
+		Status:       listing.Active,
+	}, nabil)
+	promoter, _ := m.Get("NABILP")
+	assert.Equal(listing.Promoter, promoter.Instrument)
+	m, err = listing.Read(strings.NewReader("GONE,Gone Finance,Finance,equity,,,delisted,2020-07-16\n"))
+	assert.Nil(err)
+	gone, _ := m.Get("GONE")
+	assert.Equal(listing.Delisted, gone.Status)
+	assert.Equal(time.Date(2020, 7, 16, 0, 0, 0, 0, time.UTC), gone.DelistingDate)
+
+	_, err = listing.Read(strings.NewReader("NABIL,Nabil,Banks,equity,many\n"))
+	assert.NotNil(err)
+}
+
+func TestFilterGroup(t *testing.T) {
+	assert := assert.New(t)
+	m, _ := listing.Read(strings.NewReader(master))
+	symbols := func(securities []listing.Security) []string {
+		var result []string
+		for _, security := range securities {
+			result = append(result, security.Symbol)
+		}
+		return result
+	}
+	assert.Equal([]string{"NABIL", "NABILP"}, symbols(m.Filter(listing.InSector("commercial banks"))))
+	assert.Equal([]string{"NABIL", "UPPER"}, symbols(m.Filter(listing.OfInstrument(listing.Equity))))
+	assert.Equal([]string{"UPPER"}, symbols(m.Filter(listing.WithStatus(listing.Suspended))))
+	assert.Equal([]string{"Commercial Banks", "Hydro Power", "Mutual Fund"}, m.Sectors())
+	assert.Len(m.BySector()["Commercial Banks"], 2)
+	assert.Equal([]string{"NICGF"}, symbols(m.ByInstrument()[listing.MutualFund]))
+}
+
+func TestCheck(t *testing.T) {
+	assert := assert.New(t)
+	// an empty master accepts every symbol
+	assert.Nil(listing.NewMaster().Check("ANY"))
+	m := listing.NewMaster(listing.Security{Symbol: "NABIL"})
+	assert.Nil(m.Check("NABIL"))
+	assert.True(errors.Is(m.Check("NABIL2082"), listing.ErrUnknownSymbol))
+}
+
+func TestReadLineage(t *testing.T) {
+	assert := assert.New(t)
+	l, err := listing.ReadLineage(strings.NewReader(`predecessor,successor,effective,ratio
+BOKL,NIMB,2022-06-01,0.5
+NIMB,NIMB,2022-06-01,x
+`))
+	assert.NotNil(err)
+
+	l, err = listing.ReadLineage(strings.NewReader(`# comment
+predecessor,successor,effective,ratio
+BOKL,NIMB,2022-06-01,0.5
+NIB,NIMB,2022-06-01,0.9
+KBL,KBLN,2023-01-10
+`))
+	assert.Nil(err)
+	assert.Len(l.Links(), 3)
+	predecessors := l.Predecessors("NIMB")
+	assert.Len(predecessors, 2)
+	assert.Equal("BOKL", predecessors[0].Predecessor)
+	link, ok := l.Successor("KBL")
+	assert.True(ok)
+	assert.Equal(listing.Link{Predecessor: "KBL", Successor: "KBLN", Effective: time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), Ratio: 1}, link)
+	_, ok = l.Successor("KBLN")
+	assert.False(ok)
+}
@@ -1,21 +1,84 @@
 package main
 
 import (
+	"time"
+
+	"github.com/oarkflow/search"
+	"github.com/sirupsen/logrus"
+
 	"github.com/oarkflow/nepse/app/models"
 	"github.com/oarkflow/nepse/app/server"
 	"github.com/oarkflow/nepse/config"
 	"github.com/oarkflow/nepse/log"
 	"github.com/oarkflow/nepse/nepse"
 	"github.com/oarkflow/nepse/scrape"
+	"github.com/oarkflow/nepse/stock"
 )
 
 func main() {
-	go func() {
-		nepse.InitCSVStock()
-		scrape.Scrape()
-	}()
 	config.InitConfig()
 	log.SetLogging()
 	models.InitDB()
-	server.Run()
+	client := stock.NewClientFromConfig(nil)
+	initSource(client)
+	stock.SetDefault(client)
+	server.Run(client)
+}
+
+// initSource sets the source of client to the one of the config, starting what keeps it up to date:
+// index, the files of dir indexed in the background, engine, the same files queried through the "stock" engine
+// once indexed, csv, the files of dir read as they are, or db, the bars of the DB.
+// Only index and engine load the files into the engine.
+func initSource(client *stock.Client) {
+	interval := time.Duration(config.Config.PollInterval) * time.Second
+	indices := nepse.NewIndexConfig(client.Master, client.Actions)
+	switch config.Config.Source {
+	case "index":
+		client.Source = stock.NewIndexedSource()
+		go func() {
+			nepse.InitCSVStock(indices, client.Calendar)
+			scrapeToday(client)
+			nepse.WatchCSVStock(interval, nil)
+		}()
+	case "engine":
+		nepse.InitCSVStock(indices, client.Calendar)
+		engine, err := search.GetEngine[map[string]any]("stock")
+		if err != nil {
+			logrus.Fatalf("engine source error: %v", err)
+		}
+		client.Source = stock.NewEngineSource(engine, nepse.StockStore())
+		go func() {
+			scrapeToday(client)
+			nepse.WatchCSVStock(interval, nil)
+		}()
+	case "csv":
+		source, err := stock.NewCSVSource(nepse.DataDir(), client.Calendar)
+		if err != nil {
+			logrus.Fatalf("csv source error: %v", err)
+		}
+		client.Source = source
+		go func() {
+			scrapeToday(client)
+			for range time.Tick(interval) {
+				if err := source.Refresh(); err != nil {
+					logrus.Warnf("csv source refresh error: %v", err)
+				}
+			}
+		}()
+	case "db":
+		source, err := models.NewDBSource(models.DB)
+		if err != nil {
+			logrus.Fatalf("db source error: %v", err)
+		}
+		client.Source = source
+	default:
+		logrus.Fatalf("unknown data source: %s", config.Config.Source)
+	}
+}
+
+// scrapeToday fetches the daily file of today, logging the failure
+func scrapeToday(client *stock.Client) {
+	if err := scrape.Scrape(client.Calendar); err != nil {
+		logrus.Warnf("scrape error: %v", err)
+	}
 }
@@ -0,0 +1,363 @@
+package nepse
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/oarkflow/errors"
+)
+
+// An archive holds the history of every symbol in a compact binary form:
+//
+//	magic "NEPSEARC", version byte, decimals byte, then a gzip stream of blocks,
+//	one per symbol, ended by an empty symbol.
+//
+// A block is the symbol length and bytes, the session count, the dates as days since 1970-01-01,
+// the first one then the gaps, and every column as the differences between consecutive values.
+// Prices, volume and turnover are scaled by 10^decimals to integers, transactions are kept as they are.
+// Integers are varints, signed ones zig-zag encoded. Version 2 added the 52 week high and low columns
+// after the transactions, version 1 archives are read with them zero. Version 3 added the confidence,
+// VWAP percentage, 120 and 180 days columns after those, read as zero from older archives.
+const (
+	archiveMagic   = "NEPSEARC"
+	archiveVersion = 3
+	// archiveDecimals is the precision kept, the CSV files have two decimals
+	archiveDecimals = 2
+)
+
+// ErrArchiveFormat is returned when reading something that is not an archive, or of a newer version
+var ErrArchiveFormat = errors.New("not a nepse archive")
+
+// ArchiveWriter writes a Series per block
+type ArchiveWriter struct {
+	zw    *gzip.Writer
+	bw    *bufio.Writer
+	buf   []byte
+	scale float64
+	// last is the symbol of the previous block, blocks are written in symbol order
+	last string
+}
+
+// NewArchiveWriter writes the archive header to w, the archive is complete once Close returns
+func NewArchiveWriter(w io.Writer) (*ArchiveWriter, error) {
+	header := append([]byte(archiveMagic), archiveVersion, archiveDecimals)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	zw := gzip.NewWriter(w)
+	return &ArchiveWriter{zw: zw, bw: bufio.NewWriter(zw), scale: math.Pow10(archiveDecimals)}, nil
+}
+
+// WriteSeries writes the block of s, symbols must be written in alphabetical order and at most once
+func (aw *ArchiveWriter) WriteSeries(s *Series) error {
+	if s.Symbol == "" {
+		return errors.New("archive: series without symbol")
+	}
+	if aw.last != "" && s.Symbol <= aw.last {
+		return fmt.Errorf("archive: %s written after %s", s.Symbol, aw.last)
+	}
+	aw.last = s.Symbol
+
+	b := aw.buf[:0]
+	b = binary.AppendUvarint(b, uint64(len(s.Symbol)))
+	b = append(b, s.Symbol...)
+	b = binary.AppendUvarint(b, uint64(s.Len()))
+	var prev int64
+	for i, date := range s.Date {
+		days := date.Unix() / 86400
+		if i == 0 {
+			b = binary.AppendVarint(b, days)
+		} else {
+			b = binary.AppendUvarint(b, uint64(days-prev))
+		}
+		prev = days
+	}
+	b = aw.appendColumns(b, s.Open, s.High, s.Low, s.Close, s.VWAP, s.Volume, s.PreviousClose, s.Turnover)
+	prev = 0
+	for _, value := range s.Transactions {
+		b = binary.AppendVarint(b, value-prev)
+		prev = value
+	}
+	b = aw.appendColumns(b, s.WeeksHigh52, s.WeeksLow52)
+	b = aw.appendColumns(b, s.Confidence, s.VWAPPercentage, s.Days120, s.Days180)
+	aw.buf = b
+	_, err := aw.bw.Write(b)
+	return err
+}
+
+// appendColumns appends scaled columns to b
+func (aw *ArchiveWriter) appendColumns(b []byte, columns ...[]float64) []byte {
+	for _, column := range columns {
+		var prev int64
+		for _, value := range column {
+			scaled := int64(math.Round(value * aw.scale))
+			b = binary.AppendVarint(b, scaled-prev)
+			prev = scaled
+		}
+	}
+	return b
+}
+
+// Close ends the archive, it does not close the underlying writer
+func (aw *ArchiveWriter) Close() error {
+	if err := aw.bw.WriteByte(0); err != nil {
+		return err
+	}
+	if err := aw.bw.Flush(); err != nil {
+		return err
+	}
+	return aw.zw.Close()
+}
+
+// ArchiveReader reads an archive a block at a time
+type ArchiveReader struct {
+	zr      *gzip.Reader
+	br      *bufio.Reader
+	scale   float64
+	version byte
+	done    bool
+}
+
+// NewArchiveReader reads the archive header from r
+func NewArchiveReader(r io.Reader) (*ArchiveReader, error) {
+	header := make([]byte, len(archiveMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrArchiveFormat
+	}
+	if string(header[:len(archiveMagic)]) != archiveMagic {
+		return nil, ErrArchiveFormat
+	}
+	version := header[len(archiveMagic)]
+	if version == 0 || version > archiveVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrArchiveFormat, version)
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveReader{
+		zr:      zr,
+		br:      bufio.NewReader(zr),
+		scale:   math.Pow10(int(header[len(archiveMagic)+1])),
+		version: version,
+	}, nil
+}
+
+// Next returns the Series of the next block, io.EOF after the last one
+func (ar *ArchiveReader) Next() (*Series, error) {
+	if ar.done {
+		return nil, io.EOF
+	}
+	length, err := ar.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		ar.done = true
+		// reading the gzip trailer checks the checksum of the whole archive
+		if _, err := io.Copy(io.Discard, ar.br); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	symbol := make([]byte, length)
+	if _, err := io.ReadFull(ar.br, symbol); err != nil {
+		return nil, ar.corrupt(err)
+	}
+	n, err := ar.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	s := &Series{
+		Symbol:         string(symbol),
+		Date:           make([]time.Time, n),
+		Open:           make([]float64, n),
+		High:           make([]float64, n),
+		Low:            make([]float64, n),
+		Close:          make([]float64, n),
+		VWAP:           make([]float64, n),
+		Volume:         make([]float64, n),
+		PreviousClose:  make([]float64, n),
+		Turnover:       make([]float64, n),
+		Transactions:   make([]int64, n),
+		WeeksHigh52:    make([]float64, n),
+		WeeksLow52:     make([]float64, n),
+		Confidence:     make([]float64, n),
+		VWAPPercentage: make([]float64, n),
+		Days120:        make([]float64, n),
+		Days180:        make([]float64, n),
+	}
+	var days int64
+	for i := range s.Date {
+		if i == 0 {
+			days, err = ar.varint()
+		} else {
+			var gap uint64
+			gap, err = ar.uvarint()
+			days += int64(gap)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.Date[i] = time.Unix(days*86400, 0).UTC()
+	}
+	if err := ar.columns(s.Open, s.High, s.Low, s.Close, s.VWAP, s.Volume, s.PreviousClose, s.Turnover); err != nil {
+		return nil, err
+	}
+	var value int64
+	for i := range s.Transactions {
+		delta, err := ar.varint()
+		if err != nil {
+			return nil, err
+		}
+		value += delta
+		s.Transactions[i] = value
+	}
+	if ar.version >= 2 {
+		if err := ar.columns(s.WeeksHigh52, s.WeeksLow52); err != nil {
+			return nil, err
+		}
+	}
+	if ar.version >= 3 {
+		if err := ar.columns(s.Confidence, s.VWAPPercentage, s.Days120, s.Days180); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// columns reads scaled columns
+func (ar *ArchiveReader) columns(columns ...[]float64) error {
+	for _, column := range columns {
+		var value int64
+		for i := range column {
+			delta, err := ar.varint()
+			if err != nil {
+				return err
+			}
+			value += delta
+			column[i] = float64(value) / ar.scale
+		}
+	}
+	return nil
+}
+
+// Close releases the decompressor, it does not close the underlying reader
+func (ar *ArchiveReader) Close() error {
+	return ar.zr.Close()
+}
+
+func (ar *ArchiveReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(ar.br)
+	return v, ar.corrupt(err)
+}
+
+func (ar *ArchiveReader) varint() (int64, error) {
+	v, err := binary.ReadVarint(ar.br)
+	return v, ar.corrupt(err)
+}
+
+// corrupt turns the end of the stream inside a block into an error, a block is never cut short
+func (ar *ArchiveReader) corrupt(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// WriteArchive writes the rows of LoadAllCsvFilesToMap, by date, to w as an archive
+func WriteArchive(w io.Writer, data map[string][]map[string]any) error {
+	dates := make([]string, 0, len(data))
+	for date := range data {
+		dates = append(dates, date)
+	}
+	// oldest first, so sessions are appended in order
+	sort.Strings(dates)
+	store := NewStore()
+	for _, date := range dates {
+		if err := store.AddRows(data[date]); err != nil {
+			return fmt.Errorf("%s: %w", date, err)
+		}
+	}
+	return store.WriteArchive(w)
+}
+
+// WriteArchive writes every series of s to w as an archive
+func (s *Store) WriteArchive(w io.Writer) error {
+	aw, err := NewArchiveWriter(w)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	symbols := make([]string, 0, len(s.series))
+	for symbol := range s.series {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		if err := aw.WriteSeries(s.series[symbol]); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+// ReadArchive reads a whole archive into a Store
+func ReadArchive(r io.Reader) (*Store, error) {
+	ar, err := NewArchiveReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer ar.Close()
+	s := NewStore()
+	for {
+		series, err := ar.Next()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.series[series.Symbol] = series
+	}
+}
+
+// SaveArchive writes the archive of s to path, replacing it once complete
+func (s *Store) SaveArchive(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	err = s.WriteArchive(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadArchive reads the archive at path into a Store
+func LoadArchive(path string) (*Store, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadArchive(bufio.NewReader(file))
+}
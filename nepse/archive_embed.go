@@ -0,0 +1,12 @@
+//go:build nepse_archive
+
+package nepse
+
+import _ "embed"
+
+// embeddedArchive is built into binaries built with -tags nepse_archive, after writing it with
+//
+//	go run ./cmd/archive -out nepse/nepse.archive
+//
+//go:embed nepse.archive
+var embeddedArchive []byte
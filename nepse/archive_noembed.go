@@ -0,0 +1,6 @@
+//go:build !nepse_archive
+
+package nepse
+
+// embeddedArchive is empty unless built with -tags nepse_archive
+var embeddedArchive []byte
@@ -0,0 +1,84 @@
+package nepse_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/nepse"
+)
+
+func TestArchive(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`,
+		`NABIL,45.09,500.00,510.00,495.00,505.00,503.00,"1,000.50",500.00,"503,000.00",10,5.00,15.00,1.00,3.03,0.40,490.00,480.00,600.00,400.00`)
+	writeCSV(t, dir, "2024-08-01.csv",
+		`ADBL,45.09,540.00,545.00,530.00,544.00,541.20,"50,000.00",539.00,"27,060,000.00",500,5.00,15.00,0.93,2.83,0.52,477.00,473.00,620.00,398.00`)
+	data, err := nepse.LoadAllCsvFilesToMap(dir)
+	assert.Nil(err)
+
+	var buf bytes.Buffer
+	assert.Nil(nepse.WriteArchive(&buf, data))
+	store, err := nepse.ReadArchive(bytes.NewReader(buf.Bytes()))
+	assert.Nil(err)
+	want, err := nepse.LoadStore(dir)
+	assert.Nil(err)
+	assert.Equal(want.Symbols(), store.Symbols())
+	for _, symbol := range want.Symbols() {
+		got, _ := store.Series(symbol)
+		expected, _ := want.Series(symbol)
+		assert.Equal(expected, got)
+	}
+
+	// blocks are streamed a symbol at a time
+	reader, err := nepse.NewArchiveReader(bytes.NewReader(buf.Bytes()))
+	assert.Nil(err)
+	series, err := reader.Next()
+	assert.Nil(err)
+	assert.Equal("ADBL", series.Symbol)
+	assert.Equal([]float64{50000, 93937}, series.Volume)
+	// the columns the store keeps besides the prices
+	row := series.Row(1)
+	assert.Equal(45.09, row.Confidence)
+	assert.Equal(0.32, row.VWAPPercentage)
+	assert.Equal(477.79, row.Days120)
+	assert.Equal(473.82, row.Days180)
+	assert.Equal(620.0, row.WeeksHigh52)
+	series, err = reader.Next()
+	assert.Nil(err)
+	assert.Equal("NABIL", series.Symbol)
+	assert.Equal([]int64{10}, series.Transactions)
+	_, err = reader.Next()
+	assert.Equal(io.EOF, err)
+
+	path := filepath.Join(dir, "archive", "nepse.archive")
+	assert.Nil(store.SaveArchive(path))
+	loaded, err := nepse.LoadArchive(path)
+	assert.Nil(err)
+	assert.Equal(3, loaded.Len())
+}
+
+func TestArchiveErrors(t *testing.T) {
+	assert := assert.New(t)
+	_, err := nepse.NewArchiveReader(bytes.NewReader([]byte("SYMBOL,DATE\n")))
+	assert.True(errors.Is(err, nepse.ErrArchiveFormat))
+
+	var buf bytes.Buffer
+	writer, err := nepse.NewArchiveWriter(&buf)
+	assert.Nil(err)
+	assert.Nil(writer.WriteSeries(&nepse.Series{Symbol: "NABIL"}))
+	assert.NotNil(writer.WriteSeries(&nepse.Series{Symbol: "ADBL"}))
+
+	// a cut archive is not read as a complete one
+	store := nepse.NewStore(nepse.StockData{Symbol: "ADBL", Date: day("2024-08-04"), ClosePrice: 548.5})
+	buf.Reset()
+	assert.Nil(store.WriteArchive(&buf))
+	_, err = nepse.ReadArchive(bytes.NewReader(buf.Bytes()[:buf.Len()-10]))
+	assert.NotNil(err)
+}
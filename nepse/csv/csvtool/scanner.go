@@ -74,6 +74,12 @@ const (
 	FN_ENCRYPT   = KEYWORD | iota
 	FN_DECRYPT   = KEYWORD | iota
 	FN_INC       = KEYWORD | iota
+	FN_BSDATE    = KEYWORD | iota
+	FN_BSYEAR    = KEYWORD | iota
+	FN_BSMONTH   = KEYWORD | iota
+	FN_BSMNAME   = KEYWORD | iota
+	FN_BSMDAY    = KEYWORD | iota
+	FN_FISCAL    = KEYWORD | iota
 	// special bits
 	SPECIALBIT = 1 << 21
 	SPECIAL    = FINAL | SPECIALBIT
@@ -214,6 +220,13 @@ var functionMap = map[string]int{
 	"hour":       FN_HOUR,
 	"encrypt":    FN_ENCRYPT,
 	"decrypt":    FN_DECRYPT,
+	// Bikram Sambat date parts, fiscalyear is the Shrawan to Asar year like "2081/82"
+	"bsdate":      FN_BSDATE,
+	"bsyear":      FN_BSYEAR,
+	"bsmonth":     FN_BSMONTH,
+	"bsmonthname": FN_BSMNAME,
+	"bsday":       FN_BSMDAY,
+	"fiscalyear":  FN_FISCAL,
 }
 var joinMap = map[string]int{
 	"inner": KW_INNER,
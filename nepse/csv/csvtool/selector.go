@@ -235,6 +235,8 @@ func execExpression(q *QuerySpecs, n *Node) (int, Value) {
 					v1 = text(v1.(date).val.Month().String())
 				case FN_WDAYNAME:
 					v1 = text(v1.(date).val.Weekday().String())
+				case FN_BSDATE, FN_BSYEAR, FN_BSMONTH, FN_BSMNAME, FN_BSMDAY, FN_FISCAL:
+					v1 = bsDatePart(n.tok1.(int), v1.(date).val)
 				}
 				// aggregate functions
 			} else {
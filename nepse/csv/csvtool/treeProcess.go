@@ -467,6 +467,8 @@ func enforceType(n *Node, t int) error {
 		case FN_WDAYNAME:
 			fallthrough
 		case FN_HOUR:
+			fallthrough
+		case FN_BSDATE, FN_BSYEAR, FN_BSMONTH, FN_BSMNAME, FN_BSMDAY, FN_FISCAL:
 			err = enforceType(n.node1, T_DATE)
 		case FN_STDEVP:
 			fallthrough
@@ -18,6 +18,8 @@ import (
 
 	"github.com/oarkflow/errors"
 
+	"github.com/oarkflow/nepse/bsdate"
+
 	d "github.com/araddon/dateparse"
 	bt "github.com/google/btree"
 	"golang.org/x/term"
@@ -223,6 +225,24 @@ func checkFunctionParamType(functionId, typ int) (int, error) {
 			return typ, err("can only find hour of date/time type")
 		}
 		typ = T_INT
+	case FN_BSYEAR:
+		fallthrough
+	case FN_BSMONTH:
+		fallthrough
+	case FN_BSMDAY:
+		if !intInList(typ, T_DATE) {
+			return typ, err("can only find bikram sambat date of date type")
+		}
+		typ = T_INT
+	case FN_BSDATE:
+		fallthrough
+	case FN_BSMNAME:
+		fallthrough
+	case FN_FISCAL:
+		if !intInList(typ, T_DATE) {
+			return typ, err("can only find bikram sambat date of date type")
+		}
+		typ = T_STRING
 	}
 	return typ, nil
 }
@@ -863,3 +883,25 @@ func promptPassword() string {
 	passbytes, _ := term.ReadPassword(0)
 	return string(passbytes)
 }
+
+// bsDatePart returns the Bikram Sambat part of t asked by one of the FN_BS functions,
+// or null when t is outside the years the BS calendar covers
+func bsDatePart(functionId int, t time.Time) Value {
+	bs, err := bsdate.FromAD(t)
+	if err != nil {
+		return null("")
+	}
+	switch functionId {
+	case FN_BSYEAR:
+		return integer(bs.Year)
+	case FN_BSMONTH:
+		return integer(bs.Month)
+	case FN_BSMNAME:
+		return text(bs.Month.String())
+	case FN_BSMDAY:
+		return integer(bs.Day)
+	case FN_FISCAL:
+		return text(bsdate.FiscalYearLabel(bs.FiscalYear()))
+	}
+	return text(bs.String())
+}
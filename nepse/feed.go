@@ -0,0 +1,61 @@
+package nepse
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/log"
+)
+
+// feedBuffer is the number of sessions a subscriber can lag behind before it misses sessions
+const feedBuffer = 1024
+
+// Feed hands the sessions published to it to its subscribers. The zero Feed has no subscriber
+// and is ready to use, it is safe for concurrent use.
+type Feed struct {
+	mu          sync.Mutex
+	subscribers map[chan StockData]bool
+}
+
+// Subscribe returns a channel getting the sessions published from now on, closed once ctx is done.
+// Publish does not wait for subscribers, one lagging more than a thousand sessions behind misses the next ones.
+func (f *Feed) Subscribe(ctx context.Context) <-chan StockData {
+	ch := make(chan StockData, feedBuffer)
+	f.mu.Lock()
+	if f.subscribers == nil {
+		f.subscribers = make(map[chan StockData]bool)
+	}
+	f.subscribers[ch] = true
+	f.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.subscribers, ch)
+		close(ch)
+	}()
+	return ch
+}
+
+// Publish hands rows to every subscriber
+func (f *Feed) Publish(rows ...StockData) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subscribers {
+		for _, row := range rows {
+			select {
+			case ch <- row:
+			default:
+				log.Warn().Msgf("Feed subscriber lagging, session %s %s dropped", row.Symbol, row.Date.Format(time.DateOnly))
+			}
+		}
+	}
+}
+
+// Len returns the number of subscribers
+func (f *Feed) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subscribers)
+}
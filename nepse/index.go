@@ -0,0 +1,232 @@
+package nepse
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/oarkflow/nepse/config"
+	"github.com/oarkflow/nepse/corpaction"
+	"github.com/oarkflow/nepse/listing"
+)
+
+// NepseIndex is the synthetic symbol of the index of every listed equity
+const NepseIndex = listing.IndexPrefix + "NEPSE"
+
+// Index is a market-cap-weighted price index of the equities of the symbol master, of a sector or of all of them
+type Index struct {
+	Symbol string `json:"symbol"`
+	// Sector is the sector of the members, empty for every sector
+	Sector string `json:"sector,omitempty"`
+}
+
+// IndexSymbol returns the synthetic symbol of the index of sector, like ^BANKING for "Banking"
+func IndexSymbol(sector string) string {
+	symbol := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, sector)
+	return listing.IndexPrefix + symbol
+}
+
+// IndexConfig is what indices are computed from
+type IndexConfig struct {
+	// Master gives the members, their sector and listed shares, equities without listed shares are left out
+	Master *listing.Master
+	// Actions back out the listed shares of the sessions before bonus and right shares,
+	// and adjust the divisor for the money right shares bring in
+	Actions *corpaction.Store
+	// BaseDate is the session the indices start at BaseValue, the first session of the members when zero
+	BaseDate  time.Time
+	BaseValue float64
+}
+
+// NewIndexConfig returns the IndexConfig of the members of master adjusted for actions,
+// based as in the [index] section of config.ini
+func NewIndexConfig(master *listing.Master, actions *corpaction.Store) *IndexConfig {
+	return &IndexConfig{
+		Master:    master,
+		Actions:   actions,
+		BaseDate:  config.Config.IndexBaseDate,
+		BaseValue: config.Config.IndexBaseValue,
+	}
+}
+
+// Indices returns the NEPSE index and the index of every sector of the master
+func (c *IndexConfig) Indices() []Index {
+	if c.Master == nil || c.Master.Len() == 0 {
+		return nil
+	}
+	indices := []Index{{Symbol: NepseIndex}}
+	for _, sector := range c.Master.Sectors() {
+		indices = append(indices, Index{Symbol: IndexSymbol(sector), Sector: sector})
+	}
+	return indices
+}
+
+// indexMember is a member of an index along the sessions of the index
+type indexMember struct {
+	series  *Series
+	shares  []float64
+	actions []corpaction.Action
+	// pos is the number of sessions of series up to the current session of the index
+	pos int
+	// previous is the session of series the member counted with in the previous session of the index,
+	// -1 when it did not count
+	previous int
+	// until is the first session of the index the member no longer counts in, zero while it is listed
+	until time.Time
+}
+
+// ComputeIndex returns the sessions of index, false when it has no member with sessions.
+// The index is the market cap of its members, the listed shares times the last close, divided by a divisor
+// set on the base date so the index starts at BaseValue. The divisor is adjusted so that listings,
+// delistings and corporate actions do not move the index: a member counts from its first session,
+// at its last close on the sessions it does not trade, until it is delisted in the master, on its delisting date
+// or else after its last session. A bonus share leaves the market cap unchanged and a right share adds its price.
+// Open, high and low use the same shares and divisor, volume, turnover and transactions are the sums of the members.
+func (s *Store) ComputeIndex(index Index, cfg *IndexConfig) (*Series, bool) {
+	securities := cfg.Master.Filter(func(security listing.Security) bool {
+		return security.Instrument == listing.Equity && security.ListedShares > 0 &&
+			(index.Sector == "" || strings.EqualFold(security.Sector, index.Sector))
+	})
+	s.mu.RLock()
+	var members []*indexMember
+	dates := make(map[time.Time]bool)
+	for _, security := range securities {
+		series, ok := s.series[security.Symbol]
+		if !ok || series.Len() == 0 {
+			continue
+		}
+		member := &indexMember{series: series.slice(0, series.Len()), previous: -1}
+		if security.Status == listing.Delisted {
+			member.until = security.DelistingDate
+			if member.until.IsZero() {
+				member.until = series.Date[series.Len()-1].Add(time.Nanosecond)
+			}
+		}
+		if cfg.Actions != nil {
+			member.actions = cfg.Actions.Actions(security.Symbol)
+		}
+		// the listed shares are today's, sessions before bonus and right shares had fewer
+		factors := corpaction.Factors(member.actions, member.series.Date, member.series.Close)
+		member.shares = make([]float64, len(factors))
+		for i, f := range factors {
+			member.shares[i] = float64(security.ListedShares) / f.Volume
+		}
+		for _, date := range member.series.Date {
+			dates[date] = true
+		}
+		members = append(members, member)
+	}
+	s.mu.RUnlock()
+
+	sessions := make([]time.Time, 0, len(dates))
+	for date := range dates {
+		if !date.Before(cfg.BaseDate) {
+			sessions = append(sessions, date)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Before(sessions[j]) })
+	baseValue := cfg.BaseValue
+	if baseValue <= 0 {
+		baseValue = 100
+	}
+
+	result := &Series{Symbol: index.Symbol}
+	var divisor, previousCap, previousValue float64
+	for _, date := range sessions {
+		var row StockData
+		var marketCap, openCap, highCap, lowCap, adjustedCap float64
+		for _, m := range members {
+			for m.pos < m.series.Len() && !m.series.Date[m.pos].After(date) {
+				m.pos++
+			}
+			i := m.pos - 1
+			// not listed yet, or delisted
+			if i < 0 || (!m.until.IsZero() && !date.Before(m.until)) {
+				m.previous = -1
+				continue
+			}
+			shares, close := m.shares[i], m.series.Close[i]
+			open, high, low := close, close, close
+			if m.series.Date[i].Equal(date) {
+				open, high, low = m.series.Open[i], m.series.High[i], m.series.Low[i]
+				row.Volume += m.series.Volume[i]
+				row.Turnover += m.series.Turnover[i]
+				row.Transactions += m.series.Transactions[i]
+			}
+			marketCap += shares * close
+			openCap += shares * open
+			highCap += shares * high
+			lowCap += shares * low
+			if m.previous >= 0 {
+				adjustedCap += shares * m.exPrice(i)
+			} else {
+				// a listing enters at its close
+				adjustedCap += shares * close
+			}
+			m.previous = i
+		}
+		if marketCap == 0 {
+			continue
+		}
+		switch {
+		case divisor == 0:
+			divisor = marketCap / baseValue
+		case previousCap > 0 && adjustedCap > 0:
+			divisor *= adjustedCap / previousCap
+		}
+		row.Date = date
+		row.OpenPrice = openCap / divisor
+		row.HighPrice = highCap / divisor
+		row.LowPrice = lowCap / divisor
+		row.ClosePrice = marketCap / divisor
+		row.VWAP = row.ClosePrice
+		row.PreviousClose = previousValue
+		result.append(row)
+		previousCap, previousValue = marketCap, row.ClosePrice
+	}
+	return result, result.Len() > 0
+}
+
+// exPrice returns the close the member counted with in the previous session of the index,
+// adjusted for the bonus and right shares of the book closures up to session i.
+// Cash dividends are not adjusted for, the index being a price index.
+func (m *indexMember) exPrice(i int) float64 {
+	price := m.series.Close[m.previous]
+	from, to := m.series.Date[m.previous], m.series.Date[i]
+	for _, action := range m.actions {
+		if action.BookClose.After(from) && !action.BookClose.After(to) {
+			price = (price + action.RightRatio*action.RightPrice) / (1 + action.BonusPercent/100 + action.RightRatio)
+		}
+	}
+	return price
+}
+
+// UpdateIndices recomputes the indices of cfg into s, replacing the index series s held before,
+// and returns the symbols of the indices computed
+func (s *Store) UpdateIndices(cfg *IndexConfig) []string {
+	computed := make(map[string]*Series)
+	var symbols []string
+	for _, index := range cfg.Indices() {
+		if series, ok := s.ComputeIndex(index, cfg); ok {
+			computed[index.Symbol] = series
+			symbols = append(symbols, index.Symbol)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for symbol := range s.series {
+		if listing.IsIndex(symbol) {
+			delete(s.series, symbol)
+		}
+	}
+	for symbol, series := range computed {
+		s.series[symbol] = series
+	}
+	return symbols
+}
@@ -0,0 +1,99 @@
+package nepse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/corpaction"
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
+)
+
+func TestComputeIndex(t *testing.T) {
+	assert := assert.New(t)
+	row := func(symbol, date string, close, volume float64) nepse.StockData {
+		return nepse.StockData{Symbol: symbol, Date: day(date), OpenPrice: close, HighPrice: close,
+			LowPrice: close, ClosePrice: close, Volume: volume, Transactions: 1}
+	}
+	store := nepse.NewStore(
+		row("BANK", "2024-07-01", 100, 10),
+		row("BANK", "2024-07-02", 110, 10),
+		// 10% bonus, 110 before is worth 100 now
+		row("BANK", "2024-07-03", 100, 10),
+		row("BANK", "2024-07-04", 100, 10),
+		// listed on 2024-07-04
+		row("HYDRO", "2024-07-04", 200, 5),
+		// not an equity
+		row("FUND", "2024-07-01", 10, 1),
+	)
+	cfg := &nepse.IndexConfig{
+		Master: listing.NewMaster(
+			listing.Security{Symbol: "BANK", Sector: "Commercial Banks", Instrument: listing.Equity, ListedShares: 110},
+			listing.Security{Symbol: "HYDRO", Sector: "Hydro Power", Instrument: listing.Equity, ListedShares: 50},
+			listing.Security{Symbol: "FUND", Sector: "Mutual Fund", Instrument: listing.MutualFund, ListedShares: 1000},
+		),
+		Actions: corpaction.NewStore(corpaction.Action{Symbol: "BANK", BookClose: day("2024-07-03"), BonusPercent: 10}),
+	}
+	assert.Equal("^HYDROPOWER", nepse.IndexSymbol("Hydro Power"))
+	assert.Equal([]nepse.Index{
+		{Symbol: "^NEPSE"},
+		{Symbol: "^COMMERCIALBANKS", Sector: "Commercial Banks"},
+		{Symbol: "^HYDROPOWER", Sector: "Hydro Power"},
+		{Symbol: "^MUTUALFUND", Sector: "Mutual Fund"},
+	}, cfg.Indices())
+
+	series, ok := store.ComputeIndex(nepse.Index{Symbol: nepse.NepseIndex}, cfg)
+	assert.True(ok)
+	assert.Equal([]time.Time{day("2024-07-01"), day("2024-07-02"), day("2024-07-03"), day("2024-07-04")}, series.Date)
+	// neither the bonus nor the listing moves the index
+	assert.InDeltaSlice([]float64{100, 110, 110, 110}, series.Close, 1e-9)
+	assert.InDeltaSlice([]float64{0, 100, 110, 110}, series.PreviousClose, 1e-9)
+	assert.Equal([]float64{10, 10, 10, 15}, series.Volume)
+
+	series, ok = store.ComputeIndex(nepse.Index{Symbol: "^HYDROPOWER", Sector: "hydro power"}, cfg)
+	assert.True(ok)
+	assert.InDeltaSlice([]float64{100}, series.Close, 1e-9)
+
+	cfg.BaseDate, cfg.BaseValue = day("2024-07-02"), 1000
+	series, _ = store.ComputeIndex(nepse.Index{Symbol: nepse.NepseIndex}, cfg)
+	assert.InDeltaSlice([]float64{1000, 1000, 1000}, series.Close, 1e-9)
+
+	// the indices are stored as synthetic symbols, not for sectors without equities
+	assert.Equal([]string{"^NEPSE", "^COMMERCIALBANKS", "^HYDROPOWER"}, store.UpdateIndices(cfg))
+	q := store.Quote("^COMMERCIALBANKS", time.Time{}, time.Time{})
+	assert.Len(q.Date, 3)
+	assert.Nil(listing.NewMaster(listing.Security{Symbol: "BANK"}).Check("^NEPSE"))
+}
+
+func TestComputeIndexDelisting(t *testing.T) {
+	assert := assert.New(t)
+	var rows []nepse.StockData
+	add := func(symbol string, close float64, dates ...string) {
+		for _, date := range dates {
+			rows = append(rows, nepse.StockData{Symbol: symbol, Date: day(date), OpenPrice: close, HighPrice: close,
+				LowPrice: close, ClosePrice: close})
+		}
+	}
+	add("BANK", 100, "2024-07-01", "2024-07-02", "2024-07-03")
+	add("BANK", 110, "2024-07-04")
+	// thinly traded, still listed
+	add("THIN", 50, "2024-07-01", "2024-07-02")
+	// delisted after its last session, and on a date after it
+	add("DEAD", 20, "2024-07-01", "2024-07-02")
+	add("GONE", 30, "2024-07-01", "2024-07-02")
+	store := nepse.NewStore(rows...)
+	cfg := &nepse.IndexConfig{Master: listing.NewMaster(
+		listing.Security{Symbol: "BANK", Instrument: listing.Equity, ListedShares: 100},
+		listing.Security{Symbol: "THIN", Instrument: listing.Equity, ListedShares: 100},
+		listing.Security{Symbol: "DEAD", Instrument: listing.Equity, ListedShares: 100, Status: listing.Delisted},
+		listing.Security{Symbol: "GONE", Instrument: listing.Equity, ListedShares: 100, Status: listing.Delisted,
+			DelistingDate: day("2024-07-04")},
+	)}
+
+	series, ok := store.ComputeIndex(nepse.Index{Symbol: nepse.NepseIndex}, cfg)
+	assert.True(ok)
+	// the delistings do not move the index, THIN counts at its last close when BANK rises 10%
+	assert.InDeltaSlice([]float64{100, 100, 100, (11000 + 5000) / 150.0}, series.Close, 1e-9)
+}
@@ -0,0 +1,430 @@
+package nepse
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/errors"
+	"github.com/oarkflow/log"
+	"github.com/oarkflow/search"
+
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/listing"
+)
+
+// SyncResult summarizes what a single Indexer.Sync pass changed
+type SyncResult struct {
+	Added   []string `json:"added,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Failed  []string `json:"failed,omitempty"`
+	Rows    int      `json:"rows"`
+	// Findings are the validation findings of the files indexed, when a Validator is set
+	Findings    []Finding `json:"findings,omitempty"`
+	Quarantined int       `json:"quarantined,omitempty"`
+	// Rejected counts the rows of Unknown, the symbols the symbol master does not list
+	Rejected int      `json:"rejected,omitempty"`
+	Unknown  []string `json:"unknown,omitempty"`
+	// Closed counts the files of dates the calendar has the market closed on, left out
+	Closed int `json:"closed,omitempty"`
+}
+
+// Changed reports whether the pass modified the engine
+func (r *SyncResult) Changed() bool {
+	return len(r.Added)+len(r.Updated)+len(r.Removed) > 0
+}
+
+// Indexer keeps a search engine in sync with the CSV files of a directory.
+// Files are compared against a manifest so only new or changed files are parsed and indexed.
+type Indexer struct {
+	dir          string
+	manifestPath string
+	snapshotPath string
+	engine       *search.Engine[map[string]any]
+	manifest     *Manifest
+	progress     func(done, total int)
+	validator    *Validator
+	store        *Store
+	listing      *listing.Master
+	indices      *IndexConfig
+	calendar     *calendar.Calendar
+	mu           sync.Mutex
+}
+
+// NewIndexer returns an Indexer for dir. manifest must describe what engine already holds;
+// pass NewManifest() for an empty engine. When manifestPath is not empty the manifest is
+// written there after every pass that changes the engine.
+func NewIndexer(dir, manifestPath string, engine *search.Engine[map[string]any], manifest *Manifest) *Indexer {
+	if manifest == nil {
+		manifest = NewManifest()
+	}
+	return &Indexer{
+		dir:          dir,
+		manifestPath: manifestPath,
+		engine:       engine,
+		manifest:     manifest,
+	}
+}
+
+// SetSnapshotPath makes every pass that changes the engine also write a snapshot to path
+func (ix *Indexer) SetSnapshotPath(path string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.snapshotPath = path
+}
+
+// SetProgress registers fn to be called with the files done and the total as a pass advances
+func (ix *Indexer) SetProgress(fn func(done, total int)) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.progress = fn
+}
+
+// SetValidator makes every pass validate the files it indexes, against the file of the previous date,
+// and leave out the rows the validator quarantines
+func (ix *Indexer) SetValidator(v *Validator) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.validator = v
+}
+
+// SetStore makes every pass also apply its changes to store, which must hold what the engine holds
+func (ix *Indexer) SetStore(store *Store) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.store = store
+}
+
+// SetListing makes every pass leave out the rows of symbols master does not list,
+// unless it is empty, and tag the others with their "Sector" and "Instrument"
+func (ix *Indexer) SetListing(master *listing.Master) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.listing = master
+}
+
+// SetIndices makes every pass that changes the store recompute the indices of cfg into it
+func (ix *Indexer) SetIndices(cfg *IndexConfig) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.indices = cfg
+}
+
+// SetCalendar makes every pass leave out the files of the dates cal has the market closed on,
+// like the Friday and Saturday files repeating the session of Thursday, and drop those already indexed
+func (ix *Indexer) SetCalendar(cal *calendar.Calendar) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.calendar = cal
+}
+
+// Sync indexes files that are new or changed since the last pass and drops rows of files
+// that no longer exist. A file that fails to parse is logged, left out of the manifest
+// and retried on the next pass.
+func (ix *Indexer) Sync() (*SyncResult, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	files, err := csvFiles(ix.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+	if ix.calendar != nil {
+		files = openFiles(files, ix.calendar, result)
+	}
+	seen := make(map[string]bool, len(files))
+	ahead := &parsedFile{}
+	// changes of the store, applied at the end of the pass so its series are sorted once
+	var storeRows []StockData
+	var storeRemoved []time.Time
+	for i, file := range files {
+		if ix.progress != nil {
+			ix.progress(i, len(files))
+		}
+		seen[file.Name] = true
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			log.Error().Err(err).Msgf("File %s stat failed", file.Path)
+			result.Failed = append(result.Failed, file.Name)
+			continue
+		}
+		entry, known := ix.manifest.Files[file.Name]
+		if known && entry.unchanged(info) {
+			continue
+		}
+		checksum, err := fileChecksum(file.Path)
+		if err != nil {
+			log.Error().Err(err).Msgf("File %s checksum failed", file.Path)
+			result.Failed = append(result.Failed, file.Name)
+			continue
+		}
+		if known && entry.Checksum == checksum {
+			// touched but not modified
+			entry.Size, entry.ModTime = info.Size(), info.ModTime()
+			ix.manifest.Files[file.Name] = entry
+			continue
+		}
+		var data []map[string]any
+		if ix.validator == nil {
+			data, err = ParseCSVFile(file.Path, nil)
+		} else {
+			data, err = ix.validateFile(files, i, ahead, result)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("File %s parse failed", file.Path)
+			result.Failed = append(result.Failed, file.Name)
+			continue
+		}
+		if ix.listing != nil {
+			data = ix.tag(file.Path, data, result)
+		}
+		if known {
+			if err := ix.removeDate(fileDate(file.Name)); err != nil {
+				return result, err
+			}
+			storeRemoved = appendDate(storeRemoved, file.Name)
+			result.Updated = append(result.Updated, file.Name)
+		} else {
+			result.Added = append(result.Added, file.Name)
+		}
+		ix.engine.InsertWithPool(data, runtime.NumCPU(), 1000)
+		if ix.store != nil {
+			storeRows = appendStockData(storeRows, file.Name, data)
+		}
+		ix.manifest.Files[file.Name] = ManifestEntry{
+			Name:     file.Name,
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			Rows:     len(data),
+			Checksum: checksum,
+		}
+		result.Rows += len(data)
+		log.Info().Msgf("File %s indexed", file.Path)
+	}
+
+	if ix.progress != nil {
+		ix.progress(len(files), len(files))
+	}
+
+	for name := range ix.manifest.Files {
+		if seen[name] {
+			continue
+		}
+		if err := ix.removeDate(fileDate(name)); err != nil {
+			return result, err
+		}
+		storeRemoved = appendDate(storeRemoved, name)
+		delete(ix.manifest.Files, name)
+		result.Removed = append(result.Removed, name)
+	}
+
+	if ix.store != nil {
+		ix.store.RemoveDates(storeRemoved...)
+		ix.store.Add(storeRows...)
+		if ix.indices != nil && result.Changed() {
+			ix.store.UpdateIndices(ix.indices)
+		}
+	}
+
+	if result.Changed() {
+		// cached search results may miss the rows just indexed
+		ix.engine.ClearCache()
+		if ix.manifestPath != "" {
+			if err := ix.manifest.Save(ix.manifestPath); err != nil {
+				return result, err
+			}
+		}
+		if ix.snapshotPath != "" {
+			if err := ix.snapshot(ix.snapshotPath); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// openFiles returns the files of the dates cal has the market open on, counting the others as Closed.
+// Files not named by their date are kept.
+func openFiles(files []FileInfo, cal *calendar.Calendar, result *SyncResult) []FileInfo {
+	open := files[:0:0]
+	for _, file := range files {
+		if date, err := time.Parse(time.DateOnly, fileDate(file.Name)); err == nil && !cal.IsTradingDay(date) {
+			result.Closed++
+			continue
+		}
+		open = append(open, file)
+	}
+	return open
+}
+
+// appendDate appends the date of the CSV file name to dates
+func appendDate(dates []time.Time, name string) []time.Time {
+	date, err := time.Parse(time.DateOnly, fileDate(name))
+	if err != nil {
+		return dates
+	}
+	return append(dates, date)
+}
+
+// appendStockData appends the rows of file that convert to StockData, the others are logged and left out
+func appendStockData(data []StockData, file string, rows []map[string]any) []StockData {
+	for _, row := range rows {
+		d, err := StockDataFromMap(row)
+		if err != nil {
+			log.Warn().Err(err).Msgf("File %s row not stored", file)
+			continue
+		}
+		data = append(data, d)
+	}
+	return data
+}
+
+// tag returns the rows of the symbols the listing knows, tagged with their sector and instrument type
+func (ix *Indexer) tag(path string, rows []map[string]any, result *SyncResult) []map[string]any {
+	kept := rows[:0:0]
+	rejected := 0
+	for _, row := range rows {
+		symbol := symbolOf(row)
+		if err := ix.listing.Check(symbol); err != nil {
+			if !slices.Contains(result.Unknown, symbol) {
+				result.Unknown = append(result.Unknown, symbol)
+			}
+			rejected++
+			continue
+		}
+		if security, ok := ix.listing.Get(symbol); ok {
+			row["Sector"] = security.Sector
+			row["Instrument"] = string(security.Instrument)
+		}
+		kept = append(kept, row)
+	}
+	if rejected > 0 {
+		log.Warn().Msgf("File %s: %d rows of unknown symbols rejected", path, rejected)
+		result.Rejected += rejected
+		sort.Strings(result.Unknown)
+	}
+	return kept
+}
+
+// parsedFile keeps the rows of the file parsed as previous file of another one,
+// files go newest first so it is the next file of the pass
+type parsedFile struct {
+	name     string
+	rows     []map[string]any
+	findings []Finding
+}
+
+func (p *parsedFile) parse(file FileInfo) ([]map[string]any, []Finding, error) {
+	if p.name == file.Name {
+		return p.rows, p.findings, nil
+	}
+	rows, findings, err := ParseCSVRows(file.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.name, p.rows, p.findings = file.Name, rows, findings
+	return rows, findings, nil
+}
+
+// validateFile parses files[i], validates it against files[i+1], the file of the previous date,
+// and returns the rows that are not quarantined
+func (ix *Indexer) validateFile(files []FileInfo, i int, ahead *parsedFile, result *SyncResult) ([]map[string]any, error) {
+	file := files[i]
+	rows, parseFindings, err := ahead.parse(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(parseFindings) > 0 && !ix.validator.Quarantine {
+		return nil, errors.New(parseFindings[0].Message)
+	}
+	var previous []map[string]any
+	if i+1 < len(files) {
+		if previous, _, err = ahead.parse(files[i+1]); err != nil {
+			log.Warn().Err(err).Msgf("File %s not validated against %s", file.Path, files[i+1].Path)
+		}
+	}
+	findings := ix.validator.Validate(file.Name, rows, previous, parseFindings...)
+	kept, quarantined := ix.validator.Filter(rows, findings)
+	if len(findings) > 0 {
+		log.Warn().Msgf("File %s: %d findings, %d rows quarantined", file.Path, len(findings), quarantined)
+	}
+	result.Findings = append(result.Findings, findings...)
+	result.Quarantined += quarantined
+	return kept, nil
+}
+
+// Snapshot writes the engine content and manifest to path
+func (ix *Indexer) Snapshot(path string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.snapshot(path)
+}
+
+func (ix *Indexer) snapshot(path string) error {
+	result, err := ix.engine.Search(&search.Params{Limit: ix.engine.DocumentLen()})
+	if err != nil {
+		return err
+	}
+	rows := make([]map[string]any, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		rows = append(rows, hit.Data)
+	}
+	return WriteSnapshot(path, ix.manifest, rows)
+}
+
+// Watch runs Sync every interval until stop is closed
+func (ix *Indexer) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			result, err := ix.Sync()
+			if err != nil {
+				log.Error().Err(err).Msg("Stock sync failed")
+				continue
+			}
+			if result.Changed() {
+				log.Info().Msgf("Stock synced: %d added, %d updated, %d removed, %d rows",
+					len(result.Added), len(result.Updated), len(result.Removed), result.Rows)
+			}
+		}
+	}
+}
+
+// Manifest returns a copy of the files currently ingested
+func (ix *Indexer) Manifest() *Manifest {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	manifest := NewManifest()
+	for name, entry := range ix.manifest.Files {
+		manifest.Files[name] = entry
+	}
+	return manifest
+}
+
+// removeDate deletes every row indexed for date
+func (ix *Indexer) removeDate(date string) error {
+	result, err := ix.engine.Search(&search.Params{
+		Condition: fmt.Sprintf("Date = '%s'", date),
+		Limit:     ix.engine.DocumentLen(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, hit := range result.Hits {
+		if err := ix.engine.Delete(&search.DeleteParams[map[string]any]{Id: hit.Id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package nepse_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/search"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
+)
+
+const csvHeader = "Symbol,Confidence,OpenPrice,HighPrice,LowPrice,ClosePrice,VWAP,Volume,PreviousClose,Turnover,Transactions,Difference,Range,DifferencePercentage,RangePercentage,VWAPPercentage,120Days,180Days,52WeeksHigh,52WeeksLow\n"
+
+func writeCSV(t *testing.T, dir, name string, rows ...string) {
+	t.Helper()
+	content := csvHeader
+	for _, row := range rows {
+		content += row + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexerSync(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "manifest", "manifest.json")
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`,
+		`NABIL,45.09,500.00,510.00,495.00,505.00,503.00,"1,000.00",500.00,"503,000.00",10,5.00,15.00,1.00,3.03,0.40,490.00,480.00,600.00,400.00`)
+
+	engine, err := search.New[map[string]any](&search.Config{})
+	assert.Nil(err)
+	indexer := nepse.NewIndexer(dir, manifestPath, engine, nepse.NewManifest())
+
+	result, err := indexer.Sync()
+	assert.Nil(err)
+	assert.Equal([]string{"2024-08-04.csv"}, result.Added)
+	assert.Equal(2, result.Rows)
+	assert.Equal(2, engine.DocumentLen())
+
+	// nothing changed
+	result, err = indexer.Sync()
+	assert.Nil(err)
+	assert.False(result.Changed())
+
+	// a new daily file is picked up alone
+	writeCSV(t, dir, "2024-08-05.csv",
+		`ADBL,45.09,548.50,560.00,545.00,555.00,552.00,"80,000.00",548.50,"44,160,000.00",700,6.50,15.00,1.19,2.75,0.54,478.00,474.00,620.00,398.00`)
+	result, err = indexer.Sync()
+	assert.Nil(err)
+	assert.Equal([]string{"2024-08-05.csv"}, result.Added)
+	assert.Equal(3, engine.DocumentLen())
+
+	// a rewritten file replaces its rows
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`)
+	later := time.Now().Add(time.Minute)
+	assert.Nil(os.Chtimes(filepath.Join(dir, "2024-08-04.csv"), later, later))
+	result, err = indexer.Sync()
+	assert.Nil(err)
+	assert.Equal([]string{"2024-08-04.csv"}, result.Updated)
+	assert.Equal(2, engine.DocumentLen())
+
+	// a deleted file drops its rows
+	assert.Nil(os.Remove(filepath.Join(dir, "2024-08-05.csv")))
+	result, err = indexer.Sync()
+	assert.Nil(err)
+	assert.Equal([]string{"2024-08-05.csv"}, result.Removed)
+	assert.Equal(1, engine.DocumentLen())
+
+	manifest, err := nepse.LoadManifest(manifestPath)
+	assert.Nil(err)
+	assert.Len(manifest.Files, 1)
+	assert.Equal(1, manifest.Files["2024-08-04.csv"].Rows)
+	assert.NotEmpty(manifest.Files["2024-08-04.csv"].Checksum)
+}
+
+func TestIndexerSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "stock.snapshot")
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`)
+
+	engine, _ := search.New[map[string]any](&search.Config{})
+	indexer := nepse.NewIndexer(dir, "", engine, nepse.NewManifest())
+	indexer.SetSnapshotPath(snapshotPath)
+	_, err := indexer.Sync()
+	assert.Nil(err)
+
+	snapshot, err := nepse.ReadSnapshot(snapshotPath)
+	assert.Nil(err)
+	assert.Len(snapshot.Rows, 1)
+	assert.Equal("ADBL", snapshot.Rows[0]["Symbol"])
+	assert.Equal(548.5, snapshot.Rows[0]["ClosePrice"])
+	assert.Equal(int64(858), snapshot.Rows[0]["Transactions"])
+
+	// an engine restored from the snapshot has nothing left to index
+	restored, _ := search.New[map[string]any](&search.Config{})
+	restored.InsertWithPool(snapshot.Rows, 1, 10)
+	result, err := nepse.NewIndexer(dir, "", restored, snapshot.Manifest).Sync()
+	assert.Nil(err)
+	assert.False(result.Changed())
+	assert.Equal(1, restored.DocumentLen())
+}
+
+func TestIndexerListing(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`,
+		`NABIL,45.09,500.00,510.00,495.00,505.00,503.00,"1,000.00",500.00,"503,000.00",10,5.00,15.00,1.00,3.03,0.40,490.00,480.00,600.00,400.00`)
+
+	engine, _ := search.New[map[string]any](&search.Config{})
+	indexer := nepse.NewIndexer(dir, "", engine, nepse.NewManifest())
+	indexer.SetListing(listing.NewMaster(listing.Security{Symbol: "ADBL", Sector: "Development Banks", Instrument: listing.Equity}))
+	result, err := indexer.Sync()
+	assert.Nil(err)
+	assert.Equal(1, result.Rejected)
+	assert.Equal([]string{"NABIL"}, result.Unknown)
+	assert.Equal(1, engine.DocumentLen())
+	hits, err := engine.Search(&search.Params{Condition: "Symbol = 'ADBL'"})
+	assert.Nil(err)
+	assert.Equal("Development Banks", hits.Hits[0].Data["Sector"])
+	assert.Equal("equity", hits.Hits[0].Data["Instrument"])
+}
+
+func TestIndexerCalendar(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	adbl := `ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`
+	// Thursday, then the Friday and Saturday files repeating it
+	for _, name := range []string{"2024-08-01.csv", "2024-08-02.csv", "2024-08-03.csv"} {
+		writeCSV(t, dir, name, adbl)
+	}
+
+	engine, _ := search.New[map[string]any](&search.Config{})
+	store := nepse.NewStore()
+	indexer := nepse.NewIndexer(dir, "", engine, nepse.NewManifest())
+	indexer.SetStore(store)
+	result, err := indexer.Sync()
+	assert.Nil(err)
+	assert.Len(result.Added, 3)
+
+	// the files of closed days already indexed are dropped
+	indexer.SetCalendar(calendar.New())
+	result, err = indexer.Sync()
+	assert.Nil(err)
+	assert.Equal(2, result.Closed)
+	assert.ElementsMatch([]string{"2024-08-02.csv", "2024-08-03.csv"}, result.Removed)
+	assert.Equal(1, engine.DocumentLen())
+	series, _ := store.Series("ADBL")
+	assert.Equal([]time.Time{day("2024-08-01")}, series.Date)
+
+	// and new ones left out
+	writeCSV(t, dir, "2024-08-09.csv", adbl)
+	result, err = indexer.Sync()
+	assert.Nil(err)
+	assert.False(result.Changed())
+	assert.Equal(3, result.Closed)
+}
+
+func TestStoreRemoveClosed(t *testing.T) {
+	store := nepse.NewStore(
+		nepse.StockData{Symbol: "ADBL", Date: day("2024-08-01"), ClosePrice: 548.5},
+		nepse.StockData{Symbol: "ADBL", Date: day("2024-08-02"), ClosePrice: 548.5},
+		nepse.StockData{Symbol: "NABIL", Date: day("2024-08-03"), ClosePrice: 505},
+	)
+	store.RemoveClosed(calendar.New())
+	assert.Equal(t, []string{"ADBL"}, store.Symbols())
+	assert.Equal(t, 1, store.Len())
+}
@@ -0,0 +1,163 @@
+package nepse
+
+import (
+	"time"
+
+	"github.com/markcheno/go-quote"
+
+	"github.com/oarkflow/nepse/corpaction"
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/techan"
+)
+
+// Segment is a run of sessions of a stitched Series taken from one raw symbol, also used as json
+type Segment struct {
+	Symbol string    `json:"symbol"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// Ratio is the successor shares one raw share turned into, the raw prices are divided by it
+	// and the raw volume multiplied by it
+	Ratio float64 `json:"ratio"`
+}
+
+// stitchPart is the raw sessions of a symbol and the ratio they are adjusted by
+type stitchPart struct {
+	series *Series
+	ratio  float64
+}
+
+// Stitch returns the sessions of symbol from from to to, both included, preceded by the history of
+// its predecessors in lineage, adjusted by the swap ratios, and the raw symbol of every segment.
+// A zero from or to leaves that end open. It is false when neither symbol nor a predecessor has sessions.
+func (s *Store) Stitch(symbol string, from, to time.Time, lineage *listing.Lineage) (*Series, []Segment, bool) {
+	s.mu.RLock()
+	parts := s.stitch(symbol, time.Time{}, 1, lineage, map[string]bool{})
+	s.mu.RUnlock()
+	if len(parts) == 0 {
+		return nil, nil, false
+	}
+
+	stitched := &Series{Symbol: symbol}
+	var sources []int
+	for n, part := range parts {
+		for i := 0; i < part.series.Len(); i++ {
+			row := part.series.Row(i)
+			if part.ratio != 1 {
+				row.OpenPrice /= part.ratio
+				row.HighPrice /= part.ratio
+				row.LowPrice /= part.ratio
+				row.ClosePrice /= part.ratio
+				row.VWAP /= part.ratio
+				row.PreviousClose /= part.ratio
+				row.Days120 /= part.ratio
+				row.Days180 /= part.ratio
+				row.WeeksHigh52 /= part.ratio
+				row.WeeksLow52 /= part.ratio
+				row.Volume *= part.ratio
+			}
+			stitched.append(row)
+			sources = append(sources, n)
+		}
+	}
+
+	start, end := 0, stitched.Len()
+	if !from.IsZero() {
+		start = stitched.Index(from)
+	}
+	if !to.IsZero() {
+		end = stitched.Index(to.Add(time.Nanosecond))
+	}
+	if end < start {
+		end = start
+	}
+	var segments []Segment
+	for i := start; i < end; i++ {
+		part := parts[sources[i]]
+		if i == start || sources[i] != sources[i-1] {
+			segments = append(segments, Segment{Symbol: part.series.Symbol, From: stitched.Date[i], Ratio: part.ratio})
+		}
+		segments[len(segments)-1].To = stitched.Date[i]
+	}
+	return stitched.slice(start, end), segments, true
+}
+
+// stitch returns the raw sessions of symbol before before, or all of them when it is zero,
+// preceded by those of its first predecessor having sessions, oldest first. ratio is the successor
+// shares a share of symbol turned into, seen guards against loops in the lineage.
+func (s *Store) stitch(symbol string, before time.Time, ratio float64, lineage *listing.Lineage, seen map[string]bool) []stitchPart {
+	if seen[symbol] {
+		return nil
+	}
+	seen[symbol] = true
+
+	var own *Series
+	if series, ok := s.series[symbol]; ok {
+		end := series.Len()
+		if !before.IsZero() {
+			end = series.Index(before)
+		}
+		if end > 0 {
+			own = series.slice(0, end)
+		}
+	}
+	var parts []stitchPart
+	for _, link := range lineage.Predecessors(symbol) {
+		// a successor that traded before the change, like the acquirer of a merger, keeps its own history
+		if own != nil && own.Date[0].Before(link.Effective) {
+			continue
+		}
+		cutoff := link.Effective
+		if !before.IsZero() && before.Before(cutoff) {
+			cutoff = before
+		}
+		if parts = s.stitch(link.Predecessor, cutoff, ratio*link.Ratio, lineage, seen); len(parts) > 0 {
+			break
+		}
+	}
+	if own != nil {
+		parts = append(parts, stitchPart{series: own, ratio: ratio})
+	}
+	return parts
+}
+
+// AdjustQuote returns a copy of q, stitched from segments, back-adjusted for the corporate actions
+// of the raw symbol of every segment, see StitchedFactors
+func AdjustQuote(actions *corpaction.Store, q *quote.Quote, segments []Segment) *quote.Quote {
+	return corpaction.ApplyQuote(q, StitchedFactors(actions, segments, q.Date, q.Close))
+}
+
+// AdjustTimeSeries is AdjustQuote for the candles of ts
+func AdjustTimeSeries(actions *corpaction.Store, ts *techan.TimeSeries, segments []Segment) *techan.TimeSeries {
+	dates := make([]time.Time, len(ts.Candles))
+	closes := make([]float64, len(ts.Candles))
+	for i, candle := range ts.Candles {
+		dates[i] = candle.Period.Start
+		closes[i] = candle.ClosePrice.Float()
+	}
+	return corpaction.ApplyTimeSeries(ts, StitchedFactors(actions, segments, dates, closes))
+}
+
+// StitchedFactors returns the adjustment factors of the sessions of a series stitched from segments.
+// The actions of the raw symbol of a segment adjust every session before their book close,
+// those of the predecessors too, on the raw closes of that symbol, the stitched closes times its ratio.
+func StitchedFactors(actions *corpaction.Store, segments []Segment, dates []time.Time, closes []float64) []corpaction.Factor {
+	factors := make([]corpaction.Factor, len(dates))
+	for i := range factors {
+		factors[i] = corpaction.Factor{Price: 1, Volume: 1}
+	}
+	end := 0
+	for _, segment := range segments {
+		for end < len(dates) && !dates[end].After(segment.To) {
+			end++
+		}
+		raw := make([]float64, end)
+		for i := range raw {
+			raw[i] = closes[i] * segment.Ratio
+		}
+		for i, f := range corpaction.Factors(actions.Actions(segment.Symbol), dates[:end], raw) {
+			factors[i].Price *= f.Price
+			factors[i].Volume *= f.Volume
+		}
+	}
+	return factors
+}
@@ -0,0 +1,104 @@
+package nepse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/corpaction"
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
+)
+
+func TestStitch(t *testing.T) {
+	assert := assert.New(t)
+	row := func(symbol, date string, close float64) nepse.StockData {
+		return nepse.StockData{Symbol: symbol, Date: day(date), ClosePrice: close, Volume: 100,
+			WeeksHigh52: close + 100, WeeksLow52: close - 100}
+	}
+	store := nepse.NewStore(
+		row("OLD", "2024-07-01", 400),
+		row("OLD", "2024-07-02", 410),
+		row("MID", "2024-07-03", 420),
+		row("MID", "2024-07-04", 430),
+		row("NEW", "2024-07-07", 440),
+		// merged into NEW, but OLD is listed first
+		row("OTHER", "2024-07-04", 999),
+		// kept trading after absorbing SMALL
+		row("BIG", "2024-07-01", 300),
+		row("SMALL", "2024-06-30", 100),
+		row("BIG", "2024-07-07", 310),
+	)
+	lineage := listing.NewLineage(
+		listing.Link{Predecessor: "OLD", Successor: "MID", Effective: day("2024-07-03"), Ratio: 2},
+		listing.Link{Predecessor: "MID", Successor: "NEW", Effective: day("2024-07-07")},
+		listing.Link{Predecessor: "OTHER", Successor: "NEW", Effective: day("2024-07-07"), Ratio: 0.5},
+		listing.Link{Predecessor: "SMALL", Successor: "BIG", Effective: day("2024-07-07"), Ratio: 0.5},
+	)
+
+	series, segments, ok := store.Stitch("NEW", time.Time{}, time.Time{}, lineage)
+	assert.True(ok)
+	assert.Equal("NEW", series.Symbol)
+	assert.Equal([]float64{200, 205, 420, 430, 440}, series.Close)
+	assert.Equal([]float64{200, 200, 100, 100, 100}, series.Volume)
+	assert.Equal([]float64{250, 255, 520, 530, 540}, series.WeeksHigh52)
+	assert.Equal([]float64{150, 155, 320, 330, 340}, series.WeeksLow52)
+	assert.Equal([]nepse.Segment{
+		{Symbol: "OLD", From: day("2024-07-01"), To: day("2024-07-02"), Ratio: 2},
+		{Symbol: "MID", From: day("2024-07-03"), To: day("2024-07-04"), Ratio: 1},
+		{Symbol: "NEW", From: day("2024-07-07"), To: day("2024-07-07"), Ratio: 1},
+	}, segments)
+
+	// ranges cut the segments
+	series, segments, _ = store.Stitch("NEW", day("2024-07-02"), day("2024-07-03"), lineage)
+	assert.Equal([]float64{205, 420}, series.Close)
+	assert.Len(segments, 2)
+	assert.Equal(day("2024-07-02"), segments[0].From)
+
+	series, segments, _ = store.Stitch("BIG", time.Time{}, time.Time{}, lineage)
+	assert.Equal([]float64{300, 310}, series.Close)
+	assert.Len(segments, 1)
+
+	_, _, ok = store.Stitch("UNKNOWN", time.Time{}, time.Time{}, lineage)
+	assert.False(ok)
+}
+
+func TestAdjustStitched(t *testing.T) {
+	assert := assert.New(t)
+	row := func(symbol, date string, close float64) nepse.StockData {
+		return nepse.StockData{Symbol: symbol, Date: day(date), OpenPrice: close, HighPrice: close, LowPrice: close,
+			ClosePrice: close, Volume: 100}
+	}
+	// OLD gave a 100% bonus on 2024-07-02, then every share turned into 2 of NEW, which paid Rs 10 on 2024-07-08
+	store := nepse.NewStore(
+		row("OLD", "2024-07-01", 400),
+		row("OLD", "2024-07-02", 200),
+		row("NEW", "2024-07-07", 100),
+		row("NEW", "2024-07-08", 90),
+	)
+	lineage := listing.NewLineage(listing.Link{Predecessor: "OLD", Successor: "NEW", Effective: day("2024-07-07"), Ratio: 2})
+	actions := corpaction.NewStore(
+		corpaction.Action{Symbol: "OLD", BookClose: day("2024-07-02"), BonusPercent: 100},
+		corpaction.Action{Symbol: "NEW", BookClose: day("2024-07-08"), CashDividend: 10},
+	)
+
+	series, segments, _ := store.Stitch("NEW", time.Time{}, time.Time{}, lineage)
+	assert.Equal([]float64{200, 100, 100, 90}, series.Close)
+	adjusted := nepse.AdjustQuote(actions, series.Quote(), segments)
+	// no drop left on either book close
+	for i, close := range []float64{90, 90, 90, 90} {
+		assert.InDelta(close, adjusted.Close[i], 1e-9)
+	}
+	assert.InDelta(100*2*2, adjusted.Volume[0], 1e-9)
+
+	// only the dividend of NEW without the bonus of OLD in the range
+	series, segments, _ = store.Stitch("NEW", day("2024-07-02"), time.Time{}, lineage)
+	adjusted = nepse.AdjustQuote(actions, series.Quote(), segments)
+	for i, close := range []float64{90, 90, 90} {
+		assert.InDelta(close, adjusted.Close[i], 1e-9)
+	}
+
+	ts := nepse.AdjustTimeSeries(actions, series.TimeSeries(), segments)
+	assert.InDelta(90, ts.Candles[0].ClosePrice.Float(), 1e-9)
+}
@@ -1,6 +1,7 @@
 package nepse
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/oarkflow/errors"
 	"github.com/oarkflow/log"
@@ -12,47 +13,281 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/config"
+	"github.com/oarkflow/nepse/listing"
 	"github.com/oarkflow/nepse/nepse/csv"
 )
 
-func InitCSVStock() {
-	engine, err := search.SetEngine[map[string]any]("stock", &search.Config{})
+var stockIndexer *Indexer
+
+// stockIndexKeys are the only fields the "stock" engine tokenizes, every lookup goes through them
+var stockIndexKeys = []string{"Symbol", "Date"}
+
+// InitCSVStock creates the "stock" engine and fills it, along with StockStore, from the snapshot,
+// when one exists, then from the CSV files added or changed since. Progress is reported through StockState.
+// The indexer rejects the rows of symbols the master of indices does not list and computes its indices,
+// the files of the dates cal has the market closed on are left out.
+func InitCSVStock(indices *IndexConfig, cal *calendar.Calendar) {
+	updateStockState(func(state *IngestState) {
+		*state = IngestState{Status: IngestLoading, StartedAt: time.Now()}
+	})
+	if err := initCSVStock(indices, cal); err != nil {
+		log.Error().Err(err).Msg("Stock indexing failed")
+		updateStockState(func(state *IngestState) {
+			state.Status = IngestFailed
+			state.Error = err.Error()
+		})
+		return
+	}
+	state := StockState()
+	log.Info().Msgf("Indexed stock: %d files, %d rows from %s in %s",
+		state.FilesTotal, state.Rows, state.Source, state.ReadyAt.Sub(state.StartedAt))
+}
+
+func initCSVStock(indices *IndexConfig, cal *calendar.Calendar) error {
+	engine, err := search.SetEngine[map[string]any]("stock", &search.Config{IndexKeys: stockIndexKeys})
 	if err != nil {
-		panic(err)
+		return err
 	}
+	if _, err := os.Stat(DataDir()); os.IsNotExist(err) {
+		return initArchiveStock(engine, indices, cal)
+	}
+	manifest := NewManifest()
+	source := "csv"
+	snapshotPath := config.Config.Snapshot
+	if snapshotPath != "" {
+		snapshot, err := ReadSnapshot(snapshotPath)
+		if err == nil {
+			log.Info().Msgf("Loading stock snapshot %s", snapshotPath)
+			engine.InsertWithPool(snapshot.Rows, runtime.NumCPU(), 1000)
+			stockStore.Add(appendStockData(nil, snapshotPath, snapshot.Rows)...)
+			manifest = snapshot.Manifest
+			source = "snapshot"
+		} else if !os.IsNotExist(err) {
+			log.Warn().Err(err).Msgf("Stock snapshot %s unusable, rebuilding from CSV files", snapshotPath)
+		}
+	}
+	updateStockState(func(state *IngestState) {
+		state.Source = source
+	})
+
+	stockIndexer = NewIndexer(DataDir(), config.Config.Manifest, engine, manifest)
+	stockIndexer.SetSnapshotPath(snapshotPath)
+	stockIndexer.SetValidator(NewValidatorFromConfig())
+	stockIndexer.SetStore(stockStore)
+	stockIndexer.SetListing(indices.Master)
+	stockIndexer.SetCalendar(cal)
+	stockIndexer.SetIndices(indices)
+	stockIndexer.SetProgress(func(done, total int) {
+		updateStockState(func(state *IngestState) {
+			state.FilesDone, state.FilesTotal = done, total
+		})
+	})
 	log.Info().Msg("Indexing stock")
-	_, err = LoadAllCsvFiles("./data/date", func(data []map[string]any) {
-		engine.InsertWithPool(data, runtime.NumCPU(), 1000)
+	result, err := stockIndexer.Sync()
+	if err != nil {
+		return err
+	}
+	if !result.Changed() {
+		// the store was filled from the snapshot, the pass did not compute the indices
+		stockStore.UpdateIndices(indices)
+	}
+	updateStockState(func(state *IngestState) {
+		state.Status = IngestReady
+		state.Rows = engine.DocumentLen()
+		state.ReadyAt = time.Now()
 	})
+	return nil
+}
+
+// initArchiveStock fills the "stock" engine and StockStore from the archive of config.ini,
+// or the one embedded in the binary, when there are no CSV files to index
+func initArchiveStock(engine *search.Engine[map[string]any], indices *IndexConfig, cal *calendar.Calendar) error {
+	store, source, err := openArchive()
 	if err != nil {
-		panic(err)
+		return err
+	}
+	log.Info().Msgf("Loading stock archive %s", source)
+	store.RemoveClosed(cal)
+	store.UpdateIndices(indices)
+	stockStore.replace(store)
+	rows := make([]map[string]any, 0, store.Len())
+	for _, symbol := range store.Symbols() {
+		if listing.IsIndex(symbol) {
+			continue
+		}
+		series, _ := store.Series(symbol)
+		for i := 0; i < series.Len(); i++ {
+			rows = append(rows, series.Row(i).Map())
+		}
+	}
+	engine.InsertWithPool(rows, runtime.NumCPU(), 1000)
+	updateStockState(func(state *IngestState) {
+		state.Source = source
+		state.Status = IngestReady
+		state.Rows = engine.DocumentLen()
+		state.ReadyAt = time.Now()
+	})
+	return nil
+}
+
+// openArchive reads the archive file of config.ini, or else the embedded archive
+func openArchive() (*Store, string, error) {
+	if path := config.Config.Archive; path != "" {
+		store, err := LoadArchive(path)
+		if err == nil || !os.IsNotExist(err) {
+			return store, path, err
+		}
+	}
+	if len(embeddedArchive) > 0 {
+		store, err := ReadArchive(bytes.NewReader(embeddedArchive))
+		return store, "embedded archive", err
+	}
+	return nil, "", fmt.Errorf("no CSV files in %s and no archive", DataDir())
+}
+
+// SyncCSVStock indexes CSV files added or changed since the last sync into the "stock" engine
+func SyncCSVStock() (*SyncResult, error) {
+	if stockIndexer == nil {
+		return nil, errors.New("stock engine not initialized")
+	}
+	return stockIndexer.Sync()
+}
+
+// WatchCSVStock polls the data directory every interval and indexes new or changed files,
+// until stop is closed
+func WatchCSVStock(interval time.Duration, stop <-chan struct{}) {
+	if stockIndexer == nil {
+		log.Error().Msg("Stock engine not initialized, not watching")
+		return
+	}
+	stockIndexer.Watch(interval, stop)
+}
+
+// DataDir returns the directory holding the daily CSV files
+func DataDir() string {
+	if config.Config.DataDir != "" {
+		return config.Config.DataDir
 	}
-	log.Info().Msg("Indexed stock")
+	return "./data/date"
 }
 
+// StockData is a typed row of a daily CSV file, the trading date being the date of the file
 type StockData struct {
-	Symbol               string  `csv:"Symbol"`
-	Date                 string  `csv:"Date"`
-	Confidence           float64 `csv:"Confidence"`
-	OpenPrice            float64 `csv:"OpenPrice"`
-	HighPrice            float64 `csv:"HighPrice"`
-	LowPrice             float64 `csv:"LowPrice"`
-	ClosePrice           float64 `csv:"ClosePrice"`
-	VWAP                 float64 `csv:"VWAP"`
-	Volume               float64 `csv:"Volume"`
-	PreviousClose        float64 `csv:"PreviousClose"`
-	Turnover             float64 `csv:"Turnover"`
-	Transactions         int     `csv:"Transactions"`
-	Difference           float64 `csv:"Difference"`
-	Range                float64 `csv:"Range"`
-	DifferencePercentage float64 `csv:"DifferencePercentage"`
-	RangePercentage      float64 `csv:"RangePercentage"`
-	VWAPPercentage       float64 `csv:"VWAPPercentage"`
-	_120Days             float64 `csv:"120Days"`
-	_180Days             float64 `csv:"180Days"`
-	_52WeeksHigh         float64 `csv:"52WeeksHigh"`
-	_52WeeksLow          float64 `csv:"52WeeksLow"`
+	Symbol               string    `csv:"Symbol"`
+	Date                 time.Time `csv:"Date"`
+	Confidence           float64   `csv:"Confidence"`
+	OpenPrice            float64   `csv:"OpenPrice"`
+	HighPrice            float64   `csv:"HighPrice"`
+	LowPrice             float64   `csv:"LowPrice"`
+	ClosePrice           float64   `csv:"ClosePrice"`
+	VWAP                 float64   `csv:"VWAP"`
+	Volume               float64   `csv:"Volume"`
+	PreviousClose        float64   `csv:"PreviousClose"`
+	Turnover             float64   `csv:"Turnover"`
+	Transactions         int64     `csv:"Transactions"`
+	Difference           float64   `csv:"Difference"`
+	Range                float64   `csv:"Range"`
+	DifferencePercentage float64   `csv:"DifferencePercentage"`
+	RangePercentage      float64   `csv:"RangePercentage"`
+	VWAPPercentage       float64   `csv:"VWAPPercentage"`
+	Days120              float64   `csv:"120Days"`
+	Days180              float64   `csv:"180Days"`
+	WeeksHigh52          float64   `csv:"52WeeksHigh"`
+	WeeksLow52           float64   `csv:"52WeeksLow"`
+}
+
+// Map returns d as a row of ParseCSVFile
+func (d StockData) Map() map[string]any {
+	return map[string]any{
+		"Symbol":               d.Symbol,
+		"Date":                 d.Date.Format(time.DateOnly),
+		"Confidence":           d.Confidence,
+		"OpenPrice":            d.OpenPrice,
+		"HighPrice":            d.HighPrice,
+		"LowPrice":             d.LowPrice,
+		"ClosePrice":           d.ClosePrice,
+		"VWAP":                 d.VWAP,
+		"Volume":               d.Volume,
+		"PreviousClose":        d.PreviousClose,
+		"Turnover":             d.Turnover,
+		"Transactions":         d.Transactions,
+		"Difference":           d.Difference,
+		"Range":                d.Range,
+		"DifferencePercentage": d.DifferencePercentage,
+		"RangePercentage":      d.RangePercentage,
+		"VWAPPercentage":       d.VWAPPercentage,
+		"120Days":              d.Days120,
+		"180Days":              d.Days180,
+		"52WeeksHigh":          d.WeeksHigh52,
+		"52WeeksLow":           d.WeeksLow52,
+	}
+}
+
+// StockDataFromMap converts a row parsed by ParseCSVFile. The price, volume, turnover and transaction
+// columns must have been parsed, the other columns are zero when missing, like "-" in the file.
+func StockDataFromMap(row map[string]any) (StockData, error) {
+	symbol, _ := row["Symbol"].(string)
+	if symbol == "" {
+		return StockData{}, errors.New("row without symbol")
+	}
+	date, err := time.Parse(time.DateOnly, fmt.Sprintf("%v", row["Date"]))
+	if err != nil {
+		return StockData{}, fmt.Errorf("%s: %w", symbol, err)
+	}
+	d := StockData{Symbol: symbol, Date: date}
+	required := map[string]*float64{
+		"OpenPrice":     &d.OpenPrice,
+		"HighPrice":     &d.HighPrice,
+		"LowPrice":      &d.LowPrice,
+		"ClosePrice":    &d.ClosePrice,
+		"VWAP":          &d.VWAP,
+		"Volume":        &d.Volume,
+		"PreviousClose": &d.PreviousClose,
+		"Turnover":      &d.Turnover,
+	}
+	for key, field := range required {
+		value, ok := row[key].(float64)
+		if !ok {
+			return StockData{}, fmt.Errorf("%s: %s is %v", symbol, key, row[key])
+		}
+		*field = value
+	}
+	if d.Transactions, err = transactions(row["Transactions"]); err != nil {
+		return StockData{}, fmt.Errorf("%s: %w", symbol, err)
+	}
+	optional := map[string]*float64{
+		"Confidence":           &d.Confidence,
+		"Difference":           &d.Difference,
+		"Range":                &d.Range,
+		"DifferencePercentage": &d.DifferencePercentage,
+		"RangePercentage":      &d.RangePercentage,
+		"VWAPPercentage":       &d.VWAPPercentage,
+		"120Days":              &d.Days120,
+		"180Days":              &d.Days180,
+		"52WeeksHigh":          &d.WeeksHigh52,
+		"52WeeksLow":           &d.WeeksLow52,
+	}
+	for key, field := range optional {
+		*field, _ = row[key].(float64)
+	}
+	return d, nil
+}
+
+// transactions accepts the int64 of ParseCSVFile as well as the float64 of rows read back from json
+func transactions(value any) (int64, error) {
+	switch value := value.(type) {
+	case int64:
+		return value, nil
+	case int:
+		return int64(value), nil
+	case float64:
+		return int64(value), nil
+	}
+	return 0, fmt.Errorf("Transactions is %v", value)
 }
 
 func parseFloat(value string) (float64, error) {
@@ -65,35 +300,60 @@ func parseInt(value string) (int64, error) {
 	return strconv.ParseInt(value, 10, 64)
 }
 
+// ParseCSVFile parses a daily CSV file, numbers are float64 except Transactions as int64,
+// and every row gets the Date of the file. The first unparsable cell fails the whole file.
 func ParseCSVFile(filename string, callback func([]map[string]any)) ([]map[string]any, error) {
-	file := strings.ReplaceAll(strings.TrimSuffix(filepath.Base(filename), ".csv"), "_", "-")
-	result, err := csv.QueryCsv(filename, "SELECT * FROM @file")
+	mapData, findings, err := ParseCSVRows(filename)
 	if err != nil {
 		return nil, err
 	}
+	if len(findings) > 0 {
+		return nil, errors.New(findings[0].Message)
+	}
+	if callback != nil {
+		callback(mapData)
+	}
+	return mapData, nil
+}
+
+// ParseCSVRows parses a daily CSV file like ParseCSVFile, but an unparsable cell does not fail the file,
+// it is reported as a RuleUnparsable finding and left as the text it was.
+// The error is only set when the file can not be read.
+func ParseCSVRows(filename string) ([]map[string]any, []Finding, error) {
+	file := fileDate(filename)
+	result, err := csv.QueryCsv(filename, "SELECT * FROM @file")
+	if err != nil {
+		return nil, nil, err
+	}
+	var findings []Finding
 	mapData := csv.PrepareCsvResponseWithHeaderJson(result)
 	for i, d := range mapData {
-		var err error
 		for key, val := range d {
+			var parsed any
+			var err error
 			if !slices.Contains([]string{"Symbol", "Transactions"}, key) && fmt.Sprintf("%v", val) != "-" {
-				d[key], err = parseFloat(fmt.Sprintf("%v", val))
-				if err != nil {
-					return nil, errors.Wrap(err, fmt.Sprintf(`%s + ":" + %v`, key, val), "")
-				}
+				parsed, err = parseFloat(fmt.Sprintf("%v", val))
 			} else if key == "Transactions" {
-				d[key], err = parseInt(fmt.Sprintf("%v", val))
-				if err != nil {
-					return nil, errors.Wrap(err, fmt.Sprintf(`%s + ":" + %v`, key, val), "")
-				}
+				parsed, err = parseInt(fmt.Sprintf("%v", val))
+			} else {
+				continue
 			}
+			if err != nil {
+				findings = append(findings, Finding{
+					File:    filepath.Base(filename),
+					Row:     i + 1,
+					Symbol:  fmt.Sprintf("%v", d["Symbol"]),
+					Rule:    RuleUnparsable,
+					Message: fmt.Sprintf("%s: %v", key, val),
+				})
+				continue
+			}
+			d[key] = parsed
 		}
 		d["Date"] = file
 		mapData[i] = d
 	}
-	if callback != nil {
-		callback(mapData)
-	}
-	return mapData, nil
+	return mapData, findings, nil
 }
 
 type FileInfo struct {
@@ -101,8 +361,13 @@ type FileInfo struct {
 	Name string
 }
 
-func LoadAllCsvFiles(directory string, callback func([]map[string]any)) ([]map[string]interface{}, error) {
-	var allData []map[string]interface{}
+// fileDate returns the trading date a CSV file holds, taken from its name
+func fileDate(filename string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(filepath.Base(filename), ".csv"), "_", "-")
+}
+
+// csvFiles lists the CSV files under directory, newest date first
+func csvFiles(directory string) ([]FileInfo, error) {
 	var files []FileInfo
 	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -113,7 +378,6 @@ func LoadAllCsvFiles(directory string, callback func([]map[string]any)) ([]map[s
 		}
 		return nil
 	})
-
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +385,15 @@ func LoadAllCsvFiles(directory string, callback func([]map[string]any)) ([]map[s
 	sort.Slice(files, func(i, j int) bool {
 		return files[i].Name > files[j].Name
 	})
+	return files, nil
+}
+
+func LoadAllCsvFiles(directory string, callback func([]map[string]any)) ([]map[string]interface{}, error) {
+	var allData []map[string]interface{}
+	files, err := csvFiles(directory)
+	if err != nil {
+		return nil, err
+	}
 	for _, path := range files {
 		data, err := ParseCSVFile(path.Path, callback)
 		if err != nil {
@@ -136,26 +409,12 @@ func LoadAllCsvFiles(directory string, callback func([]map[string]any)) ([]map[s
 
 func LoadAllCsvFilesToMap(directory string) (map[string][]map[string]any, error) {
 	allData := make(map[string][]map[string]any)
-	var files []FileInfo
-	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".csv") {
-			files = append(files, FileInfo{Path: path, Name: info.Name()})
-		}
-		return nil
-	})
-
+	files, err := csvFiles(directory)
 	if err != nil {
 		return nil, err
 	}
-
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name > files[j].Name
-	})
 	for _, path := range files {
-		date := strings.ReplaceAll(strings.TrimSuffix(filepath.Base(path.Path), ".csv"), "_", "-")
+		date := fileDate(path.Path)
 		data, err := ParseCSVFile(path.Path, nil)
 		if err != nil {
 			return nil, err
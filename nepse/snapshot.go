@@ -0,0 +1,83 @@
+package nepse
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+)
+
+// snapshotVersion is bumped whenever the layout of Snapshot changes,
+// older snapshots are then ignored and rebuilt from the CSV files
+const snapshotVersion = 1
+
+// Snapshot is the serialized content of the "stock" engine together with the
+// manifest of the files it was built from
+type Snapshot struct {
+	Version   int
+	CreatedAt time.Time
+	Manifest  *Manifest
+	Rows      []map[string]any
+}
+
+// WriteSnapshot writes rows and manifest to path as a gzipped gob stream
+func WriteSnapshot(path string, manifest *Manifest, rows []map[string]any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	zw := gzip.NewWriter(file)
+	err = gob.NewEncoder(zw).Encode(Snapshot{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now(),
+		Manifest:  manifest,
+		Rows:      rows,
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot
+func ReadSnapshot(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var snapshot Snapshot
+	if err := gob.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d, want %d", snapshot.Version, snapshotVersion)
+	}
+	if snapshot.Manifest == nil {
+		snapshot.Manifest = NewManifest()
+	}
+	return &snapshot, nil
+}
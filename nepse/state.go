@@ -0,0 +1,55 @@
+package nepse
+
+import (
+	"sync"
+	"time"
+)
+
+// IngestStatus is the stage the stock data ingest is in
+type IngestStatus string
+
+const (
+	// IngestIdle means no ingest has been started
+	IngestIdle IngestStatus = "idle"
+	// IngestLoading means the snapshot or the CSV files are being indexed
+	IngestLoading IngestStatus = "loading"
+	// IngestReady means the "stock" engine can be queried
+	IngestReady IngestStatus = "ready"
+	// IngestFailed means the ingest stopped with an error
+	IngestFailed IngestStatus = "failed"
+)
+
+// IngestState reports the progress of the stock data ingest, also used as json
+type IngestState struct {
+	Status     IngestStatus `json:"status"`
+	Source     string       `json:"source,omitempty"`
+	FilesDone  int          `json:"files_done"`
+	FilesTotal int          `json:"files_total"`
+	Rows       int          `json:"rows"`
+	Error      string       `json:"error,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	ReadyAt    time.Time    `json:"ready_at"`
+}
+
+// Ready reports whether the stock data can be queried
+func (s IngestState) Ready() bool {
+	return s.Status == IngestReady
+}
+
+var (
+	stateMutex sync.RWMutex
+	stockState = IngestState{Status: IngestIdle}
+)
+
+// StockState returns the current state of the stock data ingest
+func StockState() IngestState {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	return stockState
+}
+
+func updateStockState(update func(state *IngestState)) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	update(&stockState)
+}
@@ -0,0 +1,482 @@
+package nepse
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/markcheno/go-quote"
+
+	"github.com/oarkflow/nepse/big"
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/techan"
+)
+
+// Series is the history of a symbol as columns, one entry per session in date order
+type Series struct {
+	Symbol        string
+	Date          []time.Time
+	Open          []float64
+	High          []float64
+	Low           []float64
+	Close         []float64
+	VWAP          []float64
+	Volume        []float64
+	PreviousClose []float64
+	Turnover      []float64
+	Transactions  []int64
+	// WeeksHigh52 and WeeksLow52 are the 52 week high and low the daily files give
+	WeeksHigh52 []float64
+	WeeksLow52  []float64
+	// Confidence, VWAPPercentage, Days120 and Days180 are the columns of the same name the daily files give
+	Confidence     []float64
+	VWAPPercentage []float64
+	Days120        []float64
+	Days180        []float64
+}
+
+// Len returns the number of sessions of s
+func (s *Series) Len() int {
+	return len(s.Date)
+}
+
+// Row returns session i of s, the differences and ranges computed from its prices
+func (s *Series) Row(i int) StockData {
+	d := StockData{
+		Symbol:         s.Symbol,
+		Date:           s.Date[i],
+		OpenPrice:      s.Open[i],
+		HighPrice:      s.High[i],
+		LowPrice:       s.Low[i],
+		ClosePrice:     s.Close[i],
+		VWAP:           s.VWAP[i],
+		Volume:         s.Volume[i],
+		PreviousClose:  s.PreviousClose[i],
+		Turnover:       s.Turnover[i],
+		Transactions:   s.Transactions[i],
+		WeeksHigh52:    s.WeeksHigh52[i],
+		WeeksLow52:     s.WeeksLow52[i],
+		Confidence:     s.Confidence[i],
+		VWAPPercentage: s.VWAPPercentage[i],
+		Days120:        s.Days120[i],
+		Days180:        s.Days180[i],
+		Difference:     s.Close[i] - s.PreviousClose[i],
+		Range:          s.High[i] - s.Low[i],
+	}
+	if d.PreviousClose != 0 {
+		d.DifferencePercentage = d.Difference / d.PreviousClose * 100
+	}
+	if d.LowPrice != 0 {
+		d.RangePercentage = d.Range / d.LowPrice * 100
+	}
+	return d
+}
+
+// Index returns the first session on or after date, Len() when there is none
+func (s *Series) Index(date time.Time) int {
+	return sort.Search(len(s.Date), func(i int) bool { return !s.Date[i].Before(date) })
+}
+
+// Range returns a copy of the sessions from from to to, both included.
+// A zero from or to leaves that end open.
+func (s *Series) Range(from, to time.Time) *Series {
+	start, end := 0, len(s.Date)
+	if !from.IsZero() {
+		start = s.Index(from)
+	}
+	if !to.IsZero() {
+		end = sort.Search(len(s.Date), func(i int) bool { return s.Date[i].After(to) })
+	}
+	if end < start {
+		end = start
+	}
+	return s.slice(start, end)
+}
+
+// slice returns a copy of the sessions [start, end)
+func (s *Series) slice(start, end int) *Series {
+	return &Series{
+		Symbol:         s.Symbol,
+		Date:           append([]time.Time(nil), s.Date[start:end]...),
+		Open:           append([]float64(nil), s.Open[start:end]...),
+		High:           append([]float64(nil), s.High[start:end]...),
+		Low:            append([]float64(nil), s.Low[start:end]...),
+		Close:          append([]float64(nil), s.Close[start:end]...),
+		VWAP:           append([]float64(nil), s.VWAP[start:end]...),
+		Volume:         append([]float64(nil), s.Volume[start:end]...),
+		PreviousClose:  append([]float64(nil), s.PreviousClose[start:end]...),
+		Turnover:       append([]float64(nil), s.Turnover[start:end]...),
+		Transactions:   append([]int64(nil), s.Transactions[start:end]...),
+		WeeksHigh52:    append([]float64(nil), s.WeeksHigh52[start:end]...),
+		WeeksLow52:     append([]float64(nil), s.WeeksLow52[start:end]...),
+		Confidence:     append([]float64(nil), s.Confidence[start:end]...),
+		VWAPPercentage: append([]float64(nil), s.VWAPPercentage[start:end]...),
+		Days120:        append([]float64(nil), s.Days120[start:end]...),
+		Days180:        append([]float64(nil), s.Days180[start:end]...),
+	}
+}
+
+// Quote returns the sessions of s as a Quote
+func (s *Series) Quote() *quote.Quote {
+	q := quote.NewQuote(s.Symbol, s.Len())
+	copy(q.Date, s.Date)
+	copy(q.Open, s.Open)
+	copy(q.High, s.High)
+	copy(q.Low, s.Low)
+	copy(q.Close, s.Close)
+	copy(q.Volume, s.Volume)
+	return &q
+}
+
+// TimeSeries returns the sessions of s as daily candles, each spanning the 24 hours of its session date,
+// the trade count being the transactions and the VWAP and turnover those of the session
+func (s *Series) TimeSeries() *techan.TimeSeries {
+	ts := techan.NewTimeSeries()
+	for i, date := range s.Date {
+		candle := techan.NewCandle(techan.NewTimePeriod(date, 24*time.Hour))
+		candle.OpenPrice = big.NewDecimal(s.Open[i])
+		candle.MaxPrice = big.NewDecimal(s.High[i])
+		candle.MinPrice = big.NewDecimal(s.Low[i])
+		candle.ClosePrice = big.NewDecimal(s.Close[i])
+		candle.Volume = big.NewDecimal(s.Volume[i])
+		candle.TradeCount = uint(s.Transactions[i])
+		candle.VWAP = big.NewDecimal(s.VWAP[i])
+		candle.Turnover = big.NewDecimal(s.Turnover[i])
+		ts.AddCandle(candle)
+	}
+	return ts
+}
+
+func (s *Series) append(d StockData) {
+	s.Date = append(s.Date, d.Date)
+	s.Open = append(s.Open, d.OpenPrice)
+	s.High = append(s.High, d.HighPrice)
+	s.Low = append(s.Low, d.LowPrice)
+	s.Close = append(s.Close, d.ClosePrice)
+	s.VWAP = append(s.VWAP, d.VWAP)
+	s.Volume = append(s.Volume, d.Volume)
+	s.PreviousClose = append(s.PreviousClose, d.PreviousClose)
+	s.Turnover = append(s.Turnover, d.Turnover)
+	s.Transactions = append(s.Transactions, d.Transactions)
+	s.WeeksHigh52 = append(s.WeeksHigh52, d.WeeksHigh52)
+	s.WeeksLow52 = append(s.WeeksLow52, d.WeeksLow52)
+	s.Confidence = append(s.Confidence, d.Confidence)
+	s.VWAPPercentage = append(s.VWAPPercentage, d.VWAPPercentage)
+	s.Days120 = append(s.Days120, d.Days120)
+	s.Days180 = append(s.Days180, d.Days180)
+}
+
+// keep moves session i to j, keeping the sessions for which it is called in order
+func (s *Series) keep(i, j int) {
+	s.Date[j] = s.Date[i]
+	s.Open[j] = s.Open[i]
+	s.High[j] = s.High[i]
+	s.Low[j] = s.Low[i]
+	s.Close[j] = s.Close[i]
+	s.VWAP[j] = s.VWAP[i]
+	s.Volume[j] = s.Volume[i]
+	s.PreviousClose[j] = s.PreviousClose[i]
+	s.Turnover[j] = s.Turnover[i]
+	s.Transactions[j] = s.Transactions[i]
+	s.WeeksHigh52[j] = s.WeeksHigh52[i]
+	s.WeeksLow52[j] = s.WeeksLow52[i]
+	s.Confidence[j] = s.Confidence[i]
+	s.VWAPPercentage[j] = s.VWAPPercentage[i]
+	s.Days120[j] = s.Days120[i]
+	s.Days180[j] = s.Days180[i]
+}
+
+func (s *Series) truncate(n int) {
+	s.Date = s.Date[:n]
+	s.Open = s.Open[:n]
+	s.High = s.High[:n]
+	s.Low = s.Low[:n]
+	s.Close = s.Close[:n]
+	s.VWAP = s.VWAP[:n]
+	s.Volume = s.Volume[:n]
+	s.PreviousClose = s.PreviousClose[:n]
+	s.Turnover = s.Turnover[:n]
+	s.Transactions = s.Transactions[:n]
+	s.WeeksHigh52 = s.WeeksHigh52[:n]
+	s.WeeksLow52 = s.WeeksLow52[:n]
+	s.Confidence = s.Confidence[:n]
+	s.VWAPPercentage = s.VWAPPercentage[:n]
+	s.Days120 = s.Days120[:n]
+	s.Days180 = s.Days180[:n]
+}
+
+// normalize sorts the sessions by date, of sessions of the same date the last one added is kept
+func (s *Series) normalize() {
+	if !sort.IsSorted(seriesByDate{s}) {
+		sort.Stable(seriesByDate{s})
+	}
+	n := 0
+	for i := range s.Date {
+		if n > 0 && s.Date[n-1].Equal(s.Date[i]) {
+			n--
+		}
+		s.keep(i, n)
+		n++
+	}
+	s.truncate(n)
+}
+
+// seriesByDate sorts the sessions of a Series by date
+type seriesByDate struct{ *Series }
+
+func (s seriesByDate) Less(i, j int) bool { return s.Date[i].Before(s.Date[j]) }
+func (s seriesByDate) Swap(i, j int) {
+	s.Date[i], s.Date[j] = s.Date[j], s.Date[i]
+	s.Open[i], s.Open[j] = s.Open[j], s.Open[i]
+	s.High[i], s.High[j] = s.High[j], s.High[i]
+	s.Low[i], s.Low[j] = s.Low[j], s.Low[i]
+	s.Close[i], s.Close[j] = s.Close[j], s.Close[i]
+	s.VWAP[i], s.VWAP[j] = s.VWAP[j], s.VWAP[i]
+	s.Volume[i], s.Volume[j] = s.Volume[j], s.Volume[i]
+	s.PreviousClose[i], s.PreviousClose[j] = s.PreviousClose[j], s.PreviousClose[i]
+	s.Turnover[i], s.Turnover[j] = s.Turnover[j], s.Turnover[i]
+	s.Transactions[i], s.Transactions[j] = s.Transactions[j], s.Transactions[i]
+	s.WeeksHigh52[i], s.WeeksHigh52[j] = s.WeeksHigh52[j], s.WeeksHigh52[i]
+	s.WeeksLow52[i], s.WeeksLow52[j] = s.WeeksLow52[j], s.WeeksLow52[i]
+	s.Confidence[i], s.Confidence[j] = s.Confidence[j], s.Confidence[i]
+	s.VWAPPercentage[i], s.VWAPPercentage[j] = s.VWAPPercentage[j], s.VWAPPercentage[i]
+	s.Days120[i], s.Days120[j] = s.Days120[j], s.Days120[i]
+	s.Days180[i], s.Days180[j] = s.Days180[j], s.Days180[i]
+}
+
+// Store keeps the price history of every symbol as a Series. It is safe for concurrent use,
+// the Series it returns are copies.
+type Store struct {
+	mu     sync.RWMutex
+	series map[string]*Series
+	// feed gets the sessions Add appends after the last one of their symbol
+	feed Feed
+}
+
+// NewStore returns a Store holding rows
+func NewStore(rows ...StockData) *Store {
+	s := &Store{series: make(map[string]*Series)}
+	s.Add(rows...)
+	return s
+}
+
+// LoadStore builds a Store from the CSV files of dir
+func LoadStore(dir string) (*Store, error) {
+	files, err := csvFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := NewStore()
+	// oldest first, so sessions are appended in order
+	for i := len(files) - 1; i >= 0; i-- {
+		rows, err := ParseCSVFile(files[i].Path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.AddRows(rows); err != nil {
+			return nil, fmt.Errorf("%s: %w", files[i].Name, err)
+		}
+	}
+	return s, nil
+}
+
+// Add stores rows, a row replaces the session of the same symbol and date
+func (s *Store) Add(rows ...StockData) {
+	if len(rows) == 0 {
+		return
+	}
+	subscribed := s.feed.Len() > 0
+	var latest []StockData
+	s.mu.Lock()
+	// series whose new sessions are not all after the ones they had
+	unordered := make(map[*Series]bool)
+	for _, row := range rows {
+		series, ok := s.series[row.Symbol]
+		if !ok {
+			series = &Series{Symbol: row.Symbol}
+			s.series[row.Symbol] = series
+		}
+		if n := series.Len(); n > 0 && !row.Date.After(series.Date[n-1]) {
+			unordered[series] = true
+		} else if subscribed {
+			latest = append(latest, row)
+		}
+		series.append(row)
+	}
+	for series := range unordered {
+		series.normalize()
+	}
+	s.mu.Unlock()
+	s.feed.Publish(latest...)
+}
+
+// Subscribe returns a channel getting the sessions added from now on that are later than
+// the last session of their symbol, closed once ctx is done. The sessions of the indices are not sent.
+func (s *Store) Subscribe(ctx context.Context) <-chan StockData {
+	return s.feed.Subscribe(ctx)
+}
+
+// replace makes s hold the series of other, which must not be used anymore
+func (s *Store) replace(other *Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.series = other.series
+}
+
+// AddRows stores rows parsed by ParseCSVFile, nothing is stored when a row can not be converted
+func (s *Store) AddRows(rows []map[string]any) error {
+	data := make([]StockData, len(rows))
+	for i, row := range rows {
+		d, err := StockDataFromMap(row)
+		if err != nil {
+			return err
+		}
+		data[i] = d
+	}
+	s.Add(data...)
+	return nil
+}
+
+// RemoveDates drops the sessions of dates of every symbol
+func (s *Store) RemoveDates(dates ...time.Time) {
+	if len(dates) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for symbol, series := range s.series {
+		n := 0
+		for i, date := range series.Date {
+			if !containsDate(dates, date) {
+				series.keep(i, n)
+				n++
+			}
+		}
+		series.truncate(n)
+		if n == 0 {
+			delete(s.series, symbol)
+		}
+	}
+}
+
+// RemoveClosed removes the sessions of the dates cal has the market closed on
+func (s *Store) RemoveClosed(cal *calendar.Calendar) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for symbol, series := range s.series {
+		n := 0
+		for i, date := range series.Date {
+			if cal.IsTradingDay(date) {
+				series.keep(i, n)
+				n++
+			}
+		}
+		series.truncate(n)
+		if n == 0 {
+			delete(s.series, symbol)
+		}
+	}
+}
+
+func containsDate(dates []time.Time, date time.Time) bool {
+	for _, d := range dates {
+		if d.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// Symbols returns the symbols having at least one session, in alphabetical order
+func (s *Store) Symbols() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	symbols := make([]string, 0, len(s.series))
+	for symbol := range s.series {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Len returns the number of sessions of every symbol together
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, series := range s.series {
+		n += series.Len()
+	}
+	return n
+}
+
+// Series returns a copy of the whole history of symbol, false when the symbol is unknown
+func (s *Store) Series(symbol string) (*Series, bool) {
+	return s.Range(symbol, time.Time{}, time.Time{})
+}
+
+// Range returns a copy of the sessions of symbol from from to to, both included,
+// a zero from or to leaves that end open. It is false when the symbol is unknown.
+func (s *Store) Range(symbol string, from, to time.Time) (*Series, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	series, ok := s.series[symbol]
+	if !ok {
+		return nil, false
+	}
+	return series.Range(from, to), true
+}
+
+// Last returns the latest session of symbol
+func (s *Store) Last(symbol string) (StockData, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	series, ok := s.series[symbol]
+	if !ok || series.Len() == 0 {
+		return StockData{}, false
+	}
+	return series.Row(series.Len() - 1), true
+}
+
+// Date returns the sessions of every symbol traded on date, by symbol
+func (s *Store) Date(date time.Time) []StockData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rows []StockData
+	for _, series := range s.series {
+		if i := series.Index(date); i < series.Len() && series.Date[i].Equal(date) {
+			rows = append(rows, series.Row(i))
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Symbol < rows[j].Symbol })
+	return rows
+}
+
+// Quote returns the sessions of symbol from from to to as a Quote, empty when the symbol is unknown
+func (s *Store) Quote(symbol string, from, to time.Time) *quote.Quote {
+	series, ok := s.Range(symbol, from, to)
+	if !ok {
+		q := quote.NewQuote(symbol, 0)
+		return &q
+	}
+	return series.Quote()
+}
+
+// TimeSeries returns the sessions of symbol from from to to as daily candles, empty when the symbol is unknown
+func (s *Store) TimeSeries(symbol string, from, to time.Time) *techan.TimeSeries {
+	series, ok := s.Range(symbol, from, to)
+	if !ok {
+		return techan.NewTimeSeries()
+	}
+	return series.TimeSeries()
+}
+
+var stockStore = NewStore()
+
+// StockStore returns the store kept in sync with the "stock" engine by InitCSVStock,
+// empty until the stock is indexed
+func StockStore() *Store {
+	return stockStore
+}
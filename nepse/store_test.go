@@ -0,0 +1,181 @@
+package nepse_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/search"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/nepse"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func TestStockDataFromMap(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,-,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,-,620.00,398.00`)
+	rows, err := nepse.ParseCSVFile(filepath.Join(dir, "2024-08-04.csv"), nil)
+	assert.Nil(err)
+	d, err := nepse.StockDataFromMap(rows[0])
+	assert.Nil(err)
+	assert.Equal("ADBL", d.Symbol)
+	assert.Equal(day("2024-08-04"), d.Date)
+	assert.Equal(548.5, d.ClosePrice)
+	assert.Equal(93937.0, d.Volume)
+	assert.Equal(int64(858), d.Transactions)
+	assert.Equal(477.79, d.Days120)
+	// missing optional columns are zero
+	assert.Zero(d.Confidence)
+	assert.Zero(d.Days180)
+
+	rows[0]["ClosePrice"] = "oops"
+	_, err = nepse.StockDataFromMap(rows[0])
+	assert.NotNil(err)
+}
+
+func TestStore(t *testing.T) {
+	assert := assert.New(t)
+	row := func(symbol, date string, close float64) nepse.StockData {
+		return nepse.StockData{Symbol: symbol, Date: day(date), OpenPrice: close, HighPrice: close + 1,
+			LowPrice: close - 1, ClosePrice: close, VWAP: close, Volume: 10, Turnover: 10 * close, Transactions: 2}
+	}
+	store := nepse.NewStore(
+		row("ADBL", "2024-08-05", 550),
+		row("ADBL", "2024-08-01", 540),
+		row("NABIL", "2024-08-04", 500),
+	)
+	// out of order and replacing a session
+	store.Add(row("ADBL", "2024-08-04", 545), row("ADBL", "2024-08-05", 555))
+	assert.Equal([]string{"ADBL", "NABIL"}, store.Symbols())
+	assert.Equal(4, store.Len())
+
+	series, ok := store.Series("ADBL")
+	assert.True(ok)
+	assert.Equal([]time.Time{day("2024-08-01"), day("2024-08-04"), day("2024-08-05")}, series.Date)
+	assert.Equal([]float64{540, 545, 555}, series.Close)
+
+	series, ok = store.Range("ADBL", day("2024-08-02"), day("2024-08-04"))
+	assert.True(ok)
+	assert.Equal([]float64{545}, series.Close)
+	series, _ = store.Range("ADBL", day("2024-08-02"), time.Time{})
+	assert.Equal(2, series.Len())
+	series, _ = store.Range("ADBL", day("2024-08-06"), day("2024-08-02"))
+	assert.Zero(series.Len())
+	_, ok = store.Range("UNKNOWN", time.Time{}, time.Time{})
+	assert.False(ok)
+
+	rows := store.Date(day("2024-08-04"))
+	assert.Len(rows, 2)
+	assert.Equal("ADBL", rows[0].Symbol)
+	assert.Equal(2.0, rows[1].Range)
+	last, ok := store.Last("ADBL")
+	assert.True(ok)
+	assert.Equal(555.0, last.ClosePrice)
+
+	q := store.Quote("ADBL", day("2024-08-04"), time.Time{})
+	assert.Equal("ADBL", q.Symbol)
+	assert.Equal([]float64{545, 555}, q.Close)
+	assert.Equal([]float64{546, 556}, q.High)
+	assert.Empty(store.Quote("UNKNOWN", time.Time{}, time.Time{}).Date)
+
+	ts := store.TimeSeries("ADBL", time.Time{}, time.Time{})
+	assert.Len(ts.Candles, 3)
+	assert.Equal(day("2024-08-04"), ts.Candles[1].Period.Start)
+	assert.InDelta(545, ts.Candles[1].ClosePrice.Float(), 1e-9)
+	assert.Equal(day("2024-08-05"), ts.Candles[1].Period.End)
+	assert.Equal(uint(2), ts.Candles[1].TradeCount)
+	assert.InDelta(545, ts.Candles[1].VWAP.Float(), 1e-9)
+	assert.InDelta(5450, ts.Candles[1].Turnover.Float(), 1e-9)
+
+	store.RemoveDates(day("2024-08-04"))
+	assert.Equal([]string{"ADBL"}, store.Symbols())
+	assert.Equal(2, store.Len())
+}
+
+func TestIndexerStore(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`,
+		`NABIL,45.09,500.00,510.00,495.00,505.00,503.00,"1,000.00",500.00,"503,000.00",10,5.00,15.00,1.00,3.03,0.40,490.00,480.00,600.00,400.00`)
+	writeCSV(t, dir, "2024-08-05.csv",
+		`ADBL,45.09,548.50,560.00,545.00,555.00,552.00,"80,000.00",548.50,"44,160,000.00",700,6.50,15.00,1.19,2.75,0.54,478.00,474.00,620.00,398.00`)
+
+	engine, _ := search.New[map[string]any](&search.Config{})
+	store := nepse.NewStore()
+	indexer := nepse.NewIndexer(dir, "", engine, nepse.NewManifest())
+	indexer.SetStore(store)
+	_, err := indexer.Sync()
+	assert.Nil(err)
+	assert.Equal(3, store.Len())
+	series, _ := store.Series("ADBL")
+	assert.Equal([]float64{548.5, 555}, series.Close)
+	assert.Equal([]int64{858, 700}, series.Transactions)
+
+	// a rewritten file replaces its sessions, a removed file drops them
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,550.00,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`)
+	assert.Nil(os.Remove(filepath.Join(dir, "2024-08-05.csv")))
+	_, err = indexer.Sync()
+	assert.Nil(err)
+	assert.Equal([]string{"ADBL"}, store.Symbols())
+	series, _ = store.Series("ADBL")
+	assert.Equal([]float64{550}, series.Close)
+
+	loaded, err := nepse.LoadStore(dir)
+	assert.Nil(err)
+	assert.Equal(1, loaded.Len())
+}
+
+// benchmarkRows returns symbols*sessions rows, the size of a few years of NEPSE history when large
+func benchmarkRows(symbols, sessions int) []map[string]any {
+	rows := make([]map[string]any, 0, symbols*sessions)
+	start := day("2020-01-01")
+	for s := 0; s < symbols; s++ {
+		for d := 0; d < sessions; d++ {
+			rows = append(rows, map[string]any{
+				"Symbol": fmt.Sprintf("SYM%03d", s), "Date": start.AddDate(0, 0, d).Format(time.DateOnly),
+				"OpenPrice": 100.0, "HighPrice": 101.0, "LowPrice": 99.0, "ClosePrice": 100.0, "VWAP": 100.0,
+				"Volume": 10.0, "PreviousClose": 100.0, "Turnover": 1000.0, "Transactions": int64(2),
+			})
+		}
+	}
+	return rows
+}
+
+func BenchmarkStoreRange(b *testing.B) {
+	store := nepse.NewStore()
+	if err := store.AddRows(benchmarkRows(100, 500)); err != nil {
+		b.Fatal(err)
+	}
+	from, to := day("2020-06-01"), day("2020-09-01")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		store.Quote("SYM050", from, to)
+	}
+}
+
+func BenchmarkEngineRange(b *testing.B) {
+	engine, _ := search.New[map[string]any](&search.Config{IndexKeys: []string{"Symbol", "Date"}})
+	engine.InsertWithPool(benchmarkRows(100, 500), 4, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.ClearCache()
+		_, err := engine.Search(&search.Params{
+			Condition: "Symbol = 'SYM050' AND Date BETWEEN '2020-06-01' AND '2020-09-01'",
+			Limit:     engine.DocumentLen(),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package nepse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/nepse/config"
+)
+
+// Severity tells how bad a finding is, rows with error findings can be quarantined
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	// SeverityOff disables a rule
+	SeverityOff Severity = "off"
+)
+
+// Names of the validation rules, also used as keys of the [validate.rules] section of config.ini
+const (
+	RuleUnparsable            = "unparsable"
+	RuleHighBelowLow          = "high_below_low"
+	RuleCloseOutsideRange     = "close_outside_range"
+	RuleTurnoverWithoutVolume = "turnover_without_volume"
+	RuleDuplicateSymbol       = "duplicate_symbol"
+	RulePreviousClose         = "previous_close_mismatch"
+	RuleRepeatedSession       = "repeated_session"
+)
+
+// defaultSeverities are the severities of the rules unless configured otherwise
+var defaultSeverities = map[string]Severity{
+	RuleUnparsable:            SeverityError,
+	RuleHighBelowLow:          SeverityError,
+	RuleCloseOutsideRange:     SeverityError,
+	RuleTurnoverWithoutVolume: SeverityWarning,
+	RuleDuplicateSymbol:       SeverityError,
+	RulePreviousClose:         SeverityWarning,
+	RuleRepeatedSession:       SeverityWarning,
+}
+
+// Finding is a rule a row of a CSV file breaks, also used as json
+type Finding struct {
+	File string `json:"file"`
+	// Row is the data row of the file, starting at 1 after the header
+	Row      int      `json:"row,omitempty"`
+	Symbol   string   `json:"symbol,omitempty"`
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Report gathers the findings of the files validated, also used as json
+type Report struct {
+	Files       int       `json:"files"`
+	Rows        int       `json:"rows"`
+	Quarantined int       `json:"quarantined"`
+	Findings    []Finding `json:"findings"`
+}
+
+// Count returns the number of findings of severity
+func (r *Report) Count(severity Severity) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Validator checks the rows of daily CSV files, alone and against the file of the previous date
+type Validator struct {
+	// Severities overrides the severity of rules, SeverityOff disables a rule
+	Severities map[string]Severity
+	// Tolerance is the relative difference allowed between PreviousClose and the Close of the previous file
+	Tolerance float64
+	// Quarantine keeps rows with error findings out of the engine, the rest of the file is indexed
+	Quarantine bool
+}
+
+// NewValidator returns a Validator running every rule with its default severity
+func NewValidator() *Validator {
+	return &Validator{Severities: map[string]Severity{}, Tolerance: 0.005}
+}
+
+// NewValidatorFromConfig returns the Validator configured in the [validate] sections of config.ini
+func NewValidatorFromConfig() *Validator {
+	v := NewValidator()
+	v.Quarantine = config.Config.ValidateQuarantine
+	if config.Config.ValidateTolerance > 0 {
+		v.Tolerance = config.Config.ValidateTolerance
+	}
+	for rule, severity := range config.Config.ValidateRules {
+		v.Severities[rule] = Severity(strings.ToLower(severity))
+	}
+	return v
+}
+
+func (v *Validator) severity(rule string) Severity {
+	if severity, ok := v.Severities[rule]; ok {
+		return severity
+	}
+	return defaultSeverities[rule]
+}
+
+// Validate checks rows of file, previous being the rows of the file of the previous date, or nil.
+// findings already made while parsing, like unparsable cells, are passed along with their configured severity.
+func (v *Validator) Validate(file string, rows, previous []map[string]any, findings ...Finding) []Finding {
+	var result []Finding
+	add := func(row int, symbol, rule, message string) {
+		severity := v.severity(rule)
+		if severity == SeverityOff {
+			return
+		}
+		result = append(result, Finding{File: file, Row: row, Symbol: symbol, Rule: rule, Severity: severity, Message: message})
+	}
+	for _, finding := range findings {
+		add(finding.Row, finding.Symbol, finding.Rule, finding.Message)
+	}
+
+	if repeatsSession(rows, previous) {
+		// a copy of the previous session, usually saved on a day the market was closed,
+		// its previous closes are those of the copied session
+		add(0, "", RuleRepeatedSession, "same prices and volume as the previous file")
+		previous = nil
+	}
+	previousClose := make(map[string]float64, len(previous))
+	for _, row := range previous {
+		if close, ok := number(row, "ClosePrice"); ok {
+			previousClose[symbolOf(row)] = close
+		}
+	}
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		n, symbol := i+1, symbolOf(row)
+		if first, ok := seen[symbol]; ok {
+			add(n, symbol, RuleDuplicateSymbol, fmt.Sprintf("symbol already on row %d", first))
+		} else {
+			seen[symbol] = n
+		}
+
+		high, hasHigh := number(row, "HighPrice")
+		low, hasLow := number(row, "LowPrice")
+		close, hasClose := number(row, "ClosePrice")
+		if hasHigh && hasLow && high < low {
+			add(n, symbol, RuleHighBelowLow, fmt.Sprintf("high %v below low %v", high, low))
+		}
+		if hasHigh && hasLow && hasClose && high >= low && (close > high || close < low) {
+			add(n, symbol, RuleCloseOutsideRange, fmt.Sprintf("close %v outside %v - %v", close, low, high))
+		}
+		volume, hasVolume := number(row, "Volume")
+		turnover, hasTurnover := number(row, "Turnover")
+		if hasVolume && hasTurnover && volume == 0 && turnover != 0 {
+			add(n, symbol, RuleTurnoverWithoutVolume, fmt.Sprintf("turnover %v with zero volume", turnover))
+		}
+		prevClose, hasPrevClose := number(row, "PreviousClose")
+		if close, ok := previousClose[symbol]; ok && hasPrevClose && close > 0 &&
+			math.Abs(prevClose-close) > v.Tolerance*close {
+			add(n, symbol, RulePreviousClose, fmt.Sprintf("previous close %v, previous file closed at %v", prevClose, close))
+		}
+	}
+	return result
+}
+
+// Filter returns rows without the rows quarantined for findings, which must be findings of rows.
+// Nothing is dropped unless Quarantine is set.
+func (v *Validator) Filter(rows []map[string]any, findings []Finding) (kept []map[string]any, quarantined int) {
+	if !v.Quarantine {
+		return rows, 0
+	}
+	bad := make(map[int]bool)
+	for _, finding := range findings {
+		if finding.Severity == SeverityError && finding.Row > 0 {
+			bad[finding.Row] = true
+		}
+	}
+	if len(bad) == 0 {
+		return rows, 0
+	}
+	kept = make([]map[string]any, 0, len(rows)-len(bad))
+	for i, row := range rows {
+		if !bad[i+1] {
+			kept = append(kept, row)
+		}
+	}
+	return kept, len(rows) - len(kept)
+}
+
+// ValidateDir validates every CSV file of dir, each one against the file of the previous date
+func (v *Validator) ValidateDir(dir string) (*Report, error) {
+	files, err := csvFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	// oldest first, so the previous file is at hand
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	report := &Report{Findings: []Finding{}}
+	var previous []map[string]any
+	for _, file := range files {
+		rows, parseFindings, err := ParseCSVRows(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		findings := v.Validate(file.Name, rows, previous, parseFindings...)
+		_, quarantined := v.Filter(rows, findings)
+		report.Files++
+		report.Rows += len(rows)
+		report.Quarantined += quarantined
+		report.Findings = append(report.Findings, findings...)
+		previous = rows
+	}
+	return report, nil
+}
+
+// repeatsSession reports whether every symbol rows share with previous has the same prices and volume
+func repeatsSession(rows, previous []map[string]any) bool {
+	if len(previous) == 0 {
+		return false
+	}
+	bySymbol := make(map[string]map[string]any, len(previous))
+	for _, row := range previous {
+		bySymbol[symbolOf(row)] = row
+	}
+	common := 0
+	for _, row := range rows {
+		prev, ok := bySymbol[symbolOf(row)]
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"OpenPrice", "HighPrice", "LowPrice", "ClosePrice", "Volume"} {
+			if row[key] != prev[key] {
+				return false
+			}
+		}
+		common++
+	}
+	return common > 0
+}
+
+// number returns the value of a numeric column, false when it is missing or was not parsed
+func number(row map[string]any, key string) (float64, bool) {
+	value, ok := row[key].(float64)
+	return value, ok
+}
+
+func symbolOf(row map[string]any) string {
+	return fmt.Sprintf("%v", row["Symbol"])
+}
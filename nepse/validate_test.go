@@ -0,0 +1,113 @@
+package nepse_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/oarkflow/search"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/nepse"
+)
+
+func TestValidatorRules(t *testing.T) {
+	assert := assert.New(t)
+	previous := []map[string]any{
+		{"Symbol": "ADBL", "ClosePrice": 544.0},
+		{"Symbol": "NABIL", "ClosePrice": 500.0},
+	}
+	rows := []map[string]any{
+		{"Symbol": "ADBL", "HighPrice": 554.0, "LowPrice": 538.0, "ClosePrice": 548.5, "Volume": 100.0, "Turnover": 54850.0, "PreviousClose": 544.0},
+		{"Symbol": "NABIL", "HighPrice": 495.0, "LowPrice": 510.0, "ClosePrice": 505.0, "Volume": 0.0, "Turnover": 10.0, "PreviousClose": 480.0},
+		{"Symbol": "NICA", "HighPrice": 510.0, "LowPrice": 495.0, "ClosePrice": 520.0, "Volume": "-", "Turnover": 10.0},
+		{"Symbol": "ADBL", "HighPrice": 554.0, "LowPrice": 538.0, "ClosePrice": 548.5},
+	}
+
+	v := nepse.NewValidator()
+	findings := v.Validate("2024-08-05.csv", rows, previous)
+	rules := map[int][]string{}
+	for _, finding := range findings {
+		assert.Equal("2024-08-05.csv", finding.File)
+		rules[finding.Row] = append(rules[finding.Row], finding.Rule)
+	}
+	assert.Empty(rules[1])
+	assert.ElementsMatch([]string{nepse.RuleHighBelowLow, nepse.RuleTurnoverWithoutVolume, nepse.RulePreviousClose}, rules[2])
+	// missing volume is not checked
+	assert.Equal([]string{nepse.RuleCloseOutsideRange}, rules[3])
+	assert.Equal([]string{nepse.RuleDuplicateSymbol}, rules[4])
+
+	// nothing is quarantined by default
+	kept, quarantined := v.Filter(rows, findings)
+	assert.Len(kept, 4)
+	assert.Zero(quarantined)
+	// only rows with error findings are quarantined
+	v.Quarantine = true
+	kept, quarantined = v.Filter(rows, findings)
+	assert.Equal(3, quarantined)
+	assert.Equal("ADBL", kept[0]["Symbol"])
+
+	// rules can be turned off or downgraded
+	v.Severities[nepse.RuleDuplicateSymbol] = nepse.SeverityOff
+	v.Severities[nepse.RuleHighBelowLow] = nepse.SeverityWarning
+	findings = v.Validate("2024-08-05.csv", rows, previous)
+	_, quarantined = v.Filter(rows, findings)
+	assert.Equal(1, quarantined)
+	for _, finding := range findings {
+		assert.NotEqual(nepse.RuleDuplicateSymbol, finding.Rule)
+	}
+
+	// a copy of the previous session is reported once, not as mismatching previous closes
+	findings = nepse.NewValidator().Validate("2024-08-06.csv", rows[:1], rows[:1])
+	assert.Len(findings, 1)
+	assert.Equal(nepse.RuleRepeatedSession, findings[0].Rule)
+}
+
+func TestValidateDir(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeCSV(t, dir, "2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`)
+	writeCSV(t, dir, "2024-08-05.csv",
+		`ADBL,45.09,548.50,560.00,545.00,555.00,552.00,"80,000.00",540.00,"44,160,000.00",700,6.50,15.00,1.19,2.75,0.54,478.00,474.00,620.00,398.00`,
+		`NABIL,45.09,500.00,510.00,495.00,oops,503.00,"1,000.00",500.00,"503,000.00",10,5.00,15.00,1.00,3.03,0.40,490.00,480.00,600.00,400.00`)
+
+	v := nepse.NewValidator()
+	v.Quarantine = true
+	report, err := v.ValidateDir(dir)
+	assert.Nil(err)
+	assert.Equal(2, report.Files)
+	assert.Equal(3, report.Rows)
+	assert.Equal(1, report.Quarantined)
+	assert.Equal(1, report.Count(nepse.SeverityError))
+	assert.Equal(1, report.Count(nepse.SeverityWarning))
+	for _, finding := range report.Findings {
+		assert.Equal("2024-08-05.csv", finding.File)
+		if finding.Severity == nepse.SeverityError {
+			assert.Equal(nepse.RuleUnparsable, finding.Rule)
+			assert.Equal("NABIL", finding.Symbol)
+		} else {
+			assert.Equal(nepse.RulePreviousClose, finding.Rule)
+			assert.Equal("ADBL", finding.Symbol)
+		}
+	}
+
+	// the indexer keeps the rest of the file when quarantining
+	engine, _ := search.New[map[string]any](&search.Config{})
+	indexer := nepse.NewIndexer(dir, filepath.Join(dir, "manifest.json"), engine, nepse.NewManifest())
+	indexer.SetValidator(v)
+	result, err := indexer.Sync()
+	assert.Nil(err)
+	assert.Len(result.Added, 2)
+	assert.Equal(1, result.Quarantined)
+	assert.Len(result.Findings, 2)
+	assert.Equal(2, engine.DocumentLen())
+
+	// without quarantine an unparsable file is not indexed, like without a validator
+	engine, _ = search.New[map[string]any](&search.Config{})
+	indexer = nepse.NewIndexer(dir, "", engine, nepse.NewManifest())
+	indexer.SetValidator(nepse.NewValidator())
+	result, err = indexer.Sync()
+	assert.Nil(err)
+	assert.Equal([]string{"2024-08-05.csv"}, result.Failed)
+	assert.Equal(1, engine.DocumentLen())
+}
@@ -0,0 +1,199 @@
+package panel
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Axis is the direction an operation goes along
+type Axis int
+
+const (
+	// Rows compares the symbols on each date, cross-sectionally
+	Rows Axis = iota
+	// Columns compares the dates of each symbol, along its history
+	Columns
+)
+
+// Matrix is the values of one field, Values[d][s] being the value of Symbols[s] on Dates[d], NaN when missing
+type Matrix struct {
+	Dates   []time.Time
+	Symbols []string
+	Values  [][]float64
+}
+
+// newMatrix returns a matrix of NaN values
+func newMatrix(dates []time.Time, symbols []string) *Matrix {
+	m := &Matrix{Dates: dates, Symbols: symbols, Values: make([][]float64, len(dates))}
+	for d := range m.Values {
+		m.Values[d] = make([]float64, len(symbols))
+		for s := range m.Values[d] {
+			m.Values[d][s] = math.NaN()
+		}
+	}
+	return m
+}
+
+// Row returns the values of the symbols on date, false when m does not have date
+func (m *Matrix) Row(date time.Time) ([]float64, bool) {
+	d := sort.Search(len(m.Dates), func(i int) bool { return !m.Dates[i].Before(date) })
+	if d == len(m.Dates) || !m.Dates[d].Equal(date) {
+		return nil, false
+	}
+	return append([]float64(nil), m.Values[d]...), true
+}
+
+// Column returns the values of symbol on every date, false when m does not have symbol
+func (m *Matrix) Column(symbol string) ([]float64, bool) {
+	for s := range m.Symbols {
+		if m.Symbols[s] == symbol {
+			values := make([]float64, len(m.Dates))
+			for d := range m.Dates {
+				values[d] = m.Values[d][s]
+			}
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+// Between returns the dates of m from from to to, both included, a zero from or to leaving that end open
+func (m *Matrix) Between(from, to time.Time) *Matrix {
+	start, end := 0, len(m.Dates)
+	if !from.IsZero() {
+		start = sort.Search(len(m.Dates), func(i int) bool { return !m.Dates[i].Before(from) })
+	}
+	if !to.IsZero() {
+		end = sort.Search(len(m.Dates), func(i int) bool { return m.Dates[i].After(to) })
+	}
+	if end < start {
+		end = start
+	}
+	sliced := &Matrix{Dates: append([]time.Time(nil), m.Dates[start:end]...), Symbols: m.Symbols}
+	for _, row := range m.Values[start:end] {
+		sliced.Values = append(sliced.Values, append([]float64(nil), row...))
+	}
+	return sliced
+}
+
+// Select returns the symbols of m among symbols, in the order of m
+func (m *Matrix) Select(symbols ...string) *Matrix {
+	want := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		want[symbol] = true
+	}
+	var columns []int
+	sliced := &Matrix{Dates: m.Dates}
+	for s, symbol := range m.Symbols {
+		if want[symbol] {
+			columns = append(columns, s)
+			sliced.Symbols = append(sliced.Symbols, symbol)
+		}
+	}
+	sliced.Values = make([][]float64, len(m.Dates))
+	for d, row := range m.Values {
+		sliced.Values[d] = make([]float64, len(columns))
+		for i, s := range columns {
+			sliced.Values[d][i] = row[s]
+		}
+	}
+	return sliced
+}
+
+// Returns returns the change of every symbol over periods dates, value / value periods dates earlier - 1,
+// NaN for the first periods dates and when either value is missing or the earlier one is zero
+func (m *Matrix) Returns(periods int) *Matrix {
+	return m.apply(Columns, func(values []float64) []float64 {
+		returns := make([]float64, len(values))
+		for i := range values {
+			returns[i] = math.NaN()
+			if i >= periods && periods > 0 && values[i-periods] != 0 {
+				returns[i] = values[i]/values[i-periods] - 1
+			}
+		}
+		return returns
+	})
+}
+
+// Rank returns the rank of every value along axis, 1 for the lowest, ties sharing their average rank.
+// Missing values are not ranked and stay NaN.
+func (m *Matrix) Rank(axis Axis) *Matrix {
+	return m.apply(axis, func(values []float64) []float64 {
+		ranks := make([]float64, len(values))
+		var order []int
+		for i, v := range values {
+			ranks[i] = math.NaN()
+			if !math.IsNaN(v) {
+				order = append(order, i)
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+		for i := 0; i < len(order); {
+			j := i + 1
+			for j < len(order) && values[order[j]] == values[order[i]] {
+				j++
+			}
+			// ranks i+1 to j shared
+			rank := float64(i+1+j) / 2
+			for _, k := range order[i:j] {
+				ranks[k] = rank
+			}
+			i = j
+		}
+		return ranks
+	})
+}
+
+// ZScore returns how many standard deviations every value is from the mean of the values along axis,
+// zero when they are all equal. Missing values are left out of the mean and stay NaN.
+func (m *Matrix) ZScore(axis Axis) *Matrix {
+	return m.apply(axis, func(values []float64) []float64 {
+		var n, sum, squares float64
+		for _, v := range values {
+			if !math.IsNaN(v) {
+				n++
+				sum += v
+				squares += v * v
+			}
+		}
+		mean := sum / n
+		sd := math.Sqrt(math.Max(squares/n-mean*mean, 0))
+		scores := make([]float64, len(values))
+		for i, v := range values {
+			switch {
+			case math.IsNaN(v):
+				scores[i] = math.NaN()
+			case sd < 1e-12:
+				scores[i] = 0
+			default:
+				scores[i] = (v - mean) / sd
+			}
+		}
+		return scores
+	})
+}
+
+// apply returns m with f applied to every row or every column
+func (m *Matrix) apply(axis Axis, f func([]float64) []float64) *Matrix {
+	result := &Matrix{Dates: m.Dates, Symbols: m.Symbols, Values: make([][]float64, len(m.Dates))}
+	if axis == Rows {
+		for d, row := range m.Values {
+			result.Values[d] = f(row)
+		}
+		return result
+	}
+	for d := range result.Values {
+		result.Values[d] = make([]float64, len(m.Symbols))
+	}
+	values := make([]float64, len(m.Dates))
+	for s := range m.Symbols {
+		for d := range m.Dates {
+			values[d] = m.Values[d][s]
+		}
+		for d, v := range f(values) {
+			result.Values[d][s] = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,265 @@
+// Package panel aligns the sessions of many symbols on a common date index, a dates × symbols × fields panel,
+// for ranking, correlation and relative strength across symbols.
+package panel
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
+)
+
+// Field is a column of the sessions of a symbol, named like the column of the daily files
+type Field string
+
+const (
+	Open         Field = "OpenPrice"
+	High         Field = "HighPrice"
+	Low          Field = "LowPrice"
+	Close        Field = "ClosePrice"
+	VWAP         Field = "VWAP"
+	Volume       Field = "Volume"
+	Turnover     Field = "Turnover"
+	Transactions Field = "Transactions"
+)
+
+// Fields are the fields a panel is built with when none is given
+var Fields = []Field{Open, High, Low, Close, VWAP, Volume, Turnover, Transactions}
+
+// Missing is what is done with the dates a symbol has no session on
+type Missing int
+
+const (
+	// NaN leaves the values of the symbol NaN on those dates
+	NaN Missing = iota
+	// ForwardFill repeats the last prices of the symbol, volume, turnover and transactions being zero
+	// as nothing traded. Dates before the first session of the symbol stay NaN.
+	ForwardFill
+	// Drop keeps only the dates every symbol has a session on
+	Drop
+)
+
+// Options select what a panel is built from
+type Options struct {
+	// Symbols are the columns of the panel, every symbol of the store but the indices when empty.
+	// Symbols without sessions are left out.
+	Symbols []string
+	// Fields are the fields of the panel, Fields when empty
+	Fields []Field
+	// From and To are the first and last dates, both included, a zero one leaving that end open
+	From, To time.Time
+	Missing  Missing
+}
+
+// Panel is the values of Fields of Symbols on Dates, the dates any of the symbols has a session on
+type Panel struct {
+	Dates   []time.Time
+	Symbols []string
+	fields  []Field
+	values  map[Field]*Matrix
+}
+
+// Build returns the panel of the sessions of store selected by opts
+func Build(store *nepse.Store, opts Options) *Panel {
+	symbols := opts.Symbols
+	if len(symbols) == 0 {
+		for _, symbol := range store.Symbols() {
+			if !listing.IsIndex(symbol) {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = Fields
+	}
+
+	p := &Panel{fields: append([]Field(nil), fields...), values: make(map[Field]*Matrix, len(fields))}
+	var all []*nepse.Series
+	dates := make(map[time.Time]int)
+	for _, symbol := range symbols {
+		series, ok := store.Range(symbol, opts.From, opts.To)
+		if !ok || series.Len() == 0 {
+			continue
+		}
+		for _, date := range series.Date {
+			dates[date]++
+		}
+		p.Symbols = append(p.Symbols, symbol)
+		all = append(all, series)
+	}
+	for date, n := range dates {
+		if opts.Missing != Drop || n == len(all) {
+			p.Dates = append(p.Dates, date)
+		}
+	}
+	sort.Slice(p.Dates, func(i, j int) bool { return p.Dates[i].Before(p.Dates[j]) })
+
+	for _, field := range fields {
+		m := newMatrix(p.Dates, p.Symbols)
+		for j, series := range all {
+			values := column(series, field)
+			i := 0
+			for d, date := range p.Dates {
+				for i < series.Len() && series.Date[i].Before(date) {
+					i++
+				}
+				switch {
+				case i < series.Len() && series.Date[i].Equal(date):
+					m.Values[d][j] = values[i]
+				case opts.Missing == ForwardFill && i > 0:
+					if field == Volume || field == Turnover || field == Transactions {
+						m.Values[d][j] = 0
+					} else {
+						m.Values[d][j] = values[i-1]
+					}
+				}
+			}
+		}
+		p.values[field] = m
+	}
+	return p
+}
+
+// column returns the values of field of the sessions of series, nil for an unknown field
+func column(series *nepse.Series, field Field) []float64 {
+	switch field {
+	case Open:
+		return series.Open
+	case High:
+		return series.High
+	case Low:
+		return series.Low
+	case Close:
+		return series.Close
+	case VWAP:
+		return series.VWAP
+	case Volume:
+		return series.Volume
+	case Turnover:
+		return series.Turnover
+	case Transactions:
+		values := make([]float64, series.Len())
+		for i, n := range series.Transactions {
+			values[i] = float64(n)
+		}
+		return values
+	}
+	values := make([]float64, series.Len())
+	for i := range values {
+		values[i] = math.NaN()
+	}
+	return values
+}
+
+// Fields returns the fields of p, in the order they were built or added
+func (p *Panel) Fields() []Field {
+	return append([]Field(nil), p.fields...)
+}
+
+// Field returns the values of field, false when p does not have it
+func (p *Panel) Field(field Field) (*Matrix, bool) {
+	m, ok := p.values[field]
+	return m, ok
+}
+
+// With adds m, like the returns or ranks of a field, as field, replacing a field of that name.
+// m must have the dates and symbols of p.
+func (p *Panel) With(field Field, m *Matrix) error {
+	if len(m.Dates) != len(p.Dates) || len(m.Symbols) != len(p.Symbols) {
+		return fmt.Errorf("panel field %s: %d dates × %d symbols, want %d × %d",
+			field, len(m.Dates), len(m.Symbols), len(p.Dates), len(p.Symbols))
+	}
+	if _, ok := p.values[field]; !ok {
+		p.fields = append(p.fields, field)
+	}
+	p.values[field] = m
+	return nil
+}
+
+// Between returns the dates of p from from to to, both included, a zero from or to leaving that end open
+func (p *Panel) Between(from, to time.Time) *Panel {
+	return p.each(func(m *Matrix) *Matrix { return m.Between(from, to) })
+}
+
+// Select returns the symbols of p among symbols, in the order of p
+func (p *Panel) Select(symbols ...string) *Panel {
+	return p.each(func(m *Matrix) *Matrix { return m.Select(symbols...) })
+}
+
+// Sector returns the symbols of p master lists in sector
+func (p *Panel) Sector(master *listing.Master, sector string) *Panel {
+	var symbols []string
+	for _, security := range master.Filter(listing.InSector(sector)) {
+		symbols = append(symbols, security.Symbol)
+	}
+	return p.Select(symbols...)
+}
+
+// each returns p with f applied to the values of every field
+func (p *Panel) each(f func(*Matrix) *Matrix) *Panel {
+	sliced := &Panel{fields: p.Fields(), values: make(map[Field]*Matrix, len(p.values))}
+	for field, m := range p.values {
+		sliced.values[field] = f(m)
+		sliced.Dates, sliced.Symbols = sliced.values[field].Dates, sliced.values[field].Symbols
+	}
+	if len(p.values) == 0 {
+		sliced.Dates, sliced.Symbols = p.Dates, p.Symbols
+	}
+	return sliced
+}
+
+// WriteCSV writes p as csv, one line per date and symbol with the columns Date, Symbol and the fields of p,
+// like the daily files, so the csvtool engine can query it. NaN values are empty and the lines
+// of a symbol on a date it has no value of any field on are left out.
+func (p *Panel) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"Date", "Symbol"}
+	for _, field := range p.fields {
+		header = append(header, string(field))
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for d, date := range p.Dates {
+		for j, symbol := range p.Symbols {
+			record[0], record[1] = date.Format(time.DateOnly), symbol
+			empty := true
+			for k, field := range p.fields {
+				record[k+2] = ""
+				if v := p.values[field].Values[d][j]; !math.IsNaN(v) {
+					record[k+2] = strconv.FormatFloat(v, 'f', -1, 64)
+					empty = false
+				}
+			}
+			if !empty {
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// SaveCSV writes p to the file path in the format of WriteCSV
+func (p *Panel) SaveCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteCSV(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,135 @@
+package panel_test
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/panel"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func store() *nepse.Store {
+	row := func(symbol, d string, close float64) nepse.StockData {
+		return nepse.StockData{Symbol: symbol, Date: date(d), OpenPrice: close, HighPrice: close, LowPrice: close,
+			ClosePrice: close, VWAP: close, Volume: 10, Turnover: 10 * close, Transactions: 1}
+	}
+	return nepse.NewStore(
+		row("ADBL", "2024-08-01", 100),
+		row("ADBL", "2024-08-02", 110),
+		row("ADBL", "2024-08-04", 121),
+		row("NABIL", "2024-08-01", 500),
+		row("NABIL", "2024-08-04", 400),
+		row("NICA", "2024-08-02", 300),
+		row("NICA", "2024-08-04", 300),
+		row("^NEPSE", "2024-08-04", 2000),
+	)
+}
+
+func TestBuild(t *testing.T) {
+	assert := assert.New(t)
+	p := panel.Build(store(), panel.Options{Fields: []panel.Field{panel.Close, panel.Volume}})
+	assert.Equal([]string{"ADBL", "NABIL", "NICA"}, p.Symbols)
+	assert.Equal([]time.Time{date("2024-08-01"), date("2024-08-02"), date("2024-08-04")}, p.Dates)
+	assert.Equal([]panel.Field{panel.Close, panel.Volume}, p.Fields())
+	_, ok := p.Field(panel.Open)
+	assert.False(ok)
+
+	closes, ok := p.Field(panel.Close)
+	assert.True(ok)
+	nabil, _ := closes.Column("NABIL")
+	assert.Equal(500.0, nabil[0])
+	assert.True(math.IsNaN(nabil[1]))
+	row, ok := closes.Row(date("2024-08-01"))
+	assert.True(ok)
+	assert.True(math.IsNaN(row[2]))
+
+	p = panel.Build(store(), panel.Options{Missing: panel.ForwardFill})
+	closes, _ = p.Field(panel.Close)
+	volumes, _ := p.Field(panel.Volume)
+	nabil, _ = closes.Column("NABIL")
+	assert.Equal([]float64{500, 500, 400}, nabil)
+	nabil, _ = volumes.Column("NABIL")
+	assert.Equal([]float64{10, 0, 10}, nabil)
+	// not listed yet
+	nica, _ := closes.Column("NICA")
+	assert.True(math.IsNaN(nica[0]))
+
+	p = panel.Build(store(), panel.Options{Missing: panel.Drop})
+	assert.Equal([]time.Time{date("2024-08-04")}, p.Dates)
+
+	p = panel.Build(store(), panel.Options{Symbols: []string{"ADBL", "UNKNOWN"}, From: date("2024-08-02")})
+	assert.Equal([]string{"ADBL"}, p.Symbols)
+	assert.Len(p.Dates, 2)
+}
+
+func TestSlice(t *testing.T) {
+	assert := assert.New(t)
+	p := panel.Build(store(), panel.Options{})
+	sliced := p.Between(date("2024-08-02"), time.Time{}).Select("NICA", "ADBL")
+	assert.Equal([]string{"ADBL", "NICA"}, sliced.Symbols)
+	assert.Equal([]time.Time{date("2024-08-02"), date("2024-08-04")}, sliced.Dates)
+	closes, _ := sliced.Field(panel.Close)
+	assert.Equal([][]float64{{110, 300}, {121, 300}}, closes.Values)
+
+	master := listing.NewMaster(
+		listing.Security{Symbol: "ADBL", Sector: "Commercial Banks"},
+		listing.Security{Symbol: "NABIL", Sector: "Commercial Banks"},
+		listing.Security{Symbol: "NICA", Sector: "Development Banks"},
+	)
+	assert.Equal([]string{"ADBL", "NABIL"}, p.Sector(master, "commercial banks").Symbols)
+}
+
+func TestOperations(t *testing.T) {
+	assert := assert.New(t)
+	p := panel.Build(store(), panel.Options{Missing: panel.ForwardFill})
+	closes, _ := p.Field(panel.Close)
+
+	returns := closes.Returns(1)
+	adbl, _ := returns.Column("ADBL")
+	assert.True(math.IsNaN(adbl[0]))
+	assert.InDeltaSlice([]float64{0.1, 0.1}, adbl[1:], 1e-9)
+
+	ranks := closes.Rank(panel.Rows)
+	row, _ := ranks.Row(date("2024-08-04"))
+	assert.Equal([]float64{1, 3, 2}, row)
+	row, _ = ranks.Row(date("2024-08-01"))
+	assert.Equal(1.0, row[0])
+	assert.Equal(2.0, row[1])
+	assert.True(math.IsNaN(row[2]))
+	nica, _ := closes.Rank(panel.Columns).Column("NICA")
+	assert.Equal([]float64{1.5, 1.5}, nica[1:])
+
+	scores := closes.ZScore(panel.Rows)
+	row, _ = scores.Row(date("2024-08-01"))
+	assert.InDeltaSlice([]float64{-1, 1}, row[:2], 1e-9)
+	nica, _ = closes.ZScore(panel.Columns).Column("NICA")
+	assert.Equal([]float64{0, 0}, nica[1:])
+
+	assert.Nil(p.With("CloseRank", ranks))
+	assert.Contains(p.Fields(), panel.Field("CloseRank"))
+	assert.NotNil(p.With("Bad", ranks.Select("ADBL")))
+}
+
+func TestWriteCSV(t *testing.T) {
+	assert := assert.New(t)
+	p := panel.Build(store(), panel.Options{Fields: []panel.Field{panel.Close, panel.Transactions}})
+	var buf bytes.Buffer
+	assert.Nil(p.WriteCSV(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal("Date,Symbol,ClosePrice,Transactions", lines[0])
+	assert.Equal("2024-08-01,ADBL,100,1", lines[1])
+	// NICA did not trade on the first date, NABIL not on the second
+	assert.Len(lines, 8)
+	assert.Equal("2024-08-02,NICA,300,1", lines[4])
+}
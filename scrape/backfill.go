@@ -0,0 +1,124 @@
+package scrape
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/nepse/calendar"
+)
+
+// Outcome is what backfilling a single date resulted in
+type Outcome string
+
+const (
+	// OutcomeWritten means a CSV file was written for the date
+	OutcomeWritten Outcome = "written"
+	// OutcomeExists means a CSV file for the date was already there
+	OutcomeExists Outcome = "exists"
+	// OutcomeEmpty means the source had no rows for the date, usually a holiday
+	OutcomeEmpty Outcome = "empty"
+	// OutcomeFailed means fetching or saving the date failed
+	OutcomeFailed Outcome = "failed"
+)
+
+// DateResult is the outcome of backfilling one date, also used as json
+type DateResult struct {
+	Date    string  `json:"date"`
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// BackfillParam recieves the range of dates to backfill and how politely to do it
+type BackfillParam struct {
+	From time.Time
+	To   time.Time
+	// Dir is where the CSV files are written, and looked up to skip existing dates
+	Dir string
+	// Workers bounds how many dates are fetched at the same time
+	Workers int
+	// Delay is the minimum time between two requests to the source
+	Delay time.Duration
+	// Calendar picks the dates to fetch, the weekdays but Friday and Saturday when nil
+	Calendar *calendar.Calendar
+}
+
+// Backfill fetches every trading day between From and To, both included, that has no CSV file yet,
+// and reports the outcome of each date in date order. A source whose ByDate is false would save the
+// latest trading day under every date, it is rejected with ErrLatestOnly before fetching anything.
+func Backfill(src Source, bp BackfillParam) ([]DateResult, error) {
+	if dated, ok := src.(interface{ ByDate() bool }); ok && !dated.ByDate() {
+		return nil, ErrLatestOnly
+	}
+	var results []DateResult
+	var pending []time.Time
+	cal := bp.Calendar
+	if cal == nil {
+		cal = calendar.New()
+	}
+	for _, date := range cal.TradingDays(bp.From, bp.To) {
+		path := filepath.Join(bp.Dir, date.Format(time.DateOnly)+".csv")
+		if _, err := os.Stat(path); err == nil {
+			results = append(results, DateResult{Date: date.Format(time.DateOnly), Outcome: OutcomeExists})
+			continue
+		}
+		pending = append(pending, date)
+	}
+
+	workers := bp.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	dates := make(chan time.Time)
+	done := make(chan DateResult)
+	var throttle <-chan time.Time
+	if bp.Delay > 0 {
+		ticker := time.NewTicker(bp.Delay)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for date := range dates {
+				done <- backfillDate(src, bp.Dir, date)
+			}
+		}()
+	}
+	go func() {
+		for i, date := range pending {
+			if throttle != nil && i > 0 {
+				<-throttle
+			}
+			dates <- date
+		}
+		close(dates)
+		wg.Wait()
+		close(done)
+	}()
+	for result := range done {
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Date < results[j].Date })
+	return results, nil
+}
+
+func backfillDate(src Source, dir string, date time.Time) DateResult {
+	result := DateResult{Date: date.Format(time.DateOnly), Outcome: OutcomeWritten}
+	err := parseDate(src, dir, date)
+	switch {
+	case errors.Is(err, ErrNoData):
+		result.Outcome = OutcomeEmpty
+	case err != nil:
+		result.Outcome = OutcomeFailed
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package scrape
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackfill(t *testing.T) {
+	assert := assert.New(t)
+	tradingPage, _ := os.ReadFile(filepath.Join("testdata", "2024-08-05.html"))
+	closedPage, _ := os.ReadFile(filepath.Join("testdata", "2024-08-03.html"))
+
+	var mu sync.Mutex
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		posted = append(posted, r.PostForm.Get("date"))
+		mu.Unlock()
+		assert.Equal("POST", r.Method)
+		assert.Equal("all_sec", r.PostForm.Get("sector"))
+		switch r.PostForm.Get("date") {
+		case "2024-08-05":
+			w.Write(tradingPage)
+		case "2024-08-07":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write(closedPage)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "2024-08-06.csv"), []byte("Symbol\n"), 0o644)
+
+	src := &HTTPSource{
+		URL:       server.URL,
+		Selector:  defaultSelector,
+		DateParam: "date",
+		Form:      map[string]string{"sector": "all_sec"},
+	}
+	results, err := Backfill(src, BackfillParam{
+		From:    time.Date(2024, 8, 2, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2024, 8, 7, 0, 0, 0, 0, time.UTC),
+		Dir:     dir,
+		Workers: 2,
+		Delay:   time.Millisecond,
+	})
+
+	assert.Nil(err)
+	// Friday 2nd and Saturday 3rd are not trading days
+	assert.ElementsMatch([]string{"2024-08-04", "2024-08-05", "2024-08-07"}, posted)
+	assert.Len(results, 4)
+	assert.Equal(DateResult{Date: "2024-08-04", Outcome: OutcomeEmpty}, results[0])
+	assert.Equal(DateResult{Date: "2024-08-05", Outcome: OutcomeWritten}, results[1])
+	assert.Equal(DateResult{Date: "2024-08-06", Outcome: OutcomeExists}, results[2])
+	assert.Equal("2024-08-07", results[3].Date)
+	assert.Equal(OutcomeFailed, results[3].Outcome)
+	assert.NotEmpty(results[3].Error)
+
+	assert.FileExists(filepath.Join(dir, "2024-08-05.csv"))
+	assert.NoFileExists(filepath.Join(dir, "2024-08-04.csv"))
+	assert.NoFileExists(filepath.Join(dir, "2024-08-07.csv"))
+}
+
+func TestBackfillLatestOnly(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	// without DateParam every date would get the page of the latest trading day
+	results, err := Backfill(&HTTPSource{URL: server.URL, Selector: defaultSelector}, BackfillParam{
+		From: time.Date(2024, 8, 4, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 8, 5, 0, 0, 0, 0, time.UTC),
+		Dir:  dir,
+	})
+	assert.ErrorIs(err, ErrLatestOnly)
+	assert.Nil(results)
+	assert.Zero(requests)
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(entries)
+}
@@ -3,15 +3,16 @@ package scrape
 import (
 	"encoding/csv"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/gocolly/colly/v2"
 	"github.com/oarkflow/anonymizer"
 	"github.com/oarkflow/search"
+
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/nepse"
 )
 
 var headerMapping = map[string]string{
@@ -149,24 +150,39 @@ func RenameHeaders(inputFile, outputFile string, headMap ...map[string]string) e
 	return nil
 }
 
-func Scrape() error {
+// Scrape fetches the sessions of today into the data dir and the "stock" engine, unless cal has the market closed
+// or they are already indexed. Without the engine, as with the csv source, only the file is written when missing.
+// A nil cal is closed on the weekends only.
+func Scrape(cal *calendar.Calendar) error {
+	if cal == nil {
+		cal = calendar.New()
+	}
+	now := time.Now()
+	if !cal.IsTradingDay(now) {
+		return nil
+	}
 	engine, err := search.GetEngine[map[string]any]("stock")
 	if err != nil {
-		return err
+		if _, err := os.Stat(filepath.Join(nepse.DataDir(), now.Format(time.DateOnly)+".csv")); err == nil {
+			return nil
+		}
+		return parseDate(NewSource(), nepse.DataDir(), now)
 	}
-	now := time.Now()
 	result, err := engine.Search(&search.Params{Query: now.Format(time.DateOnly), Properties: []string{"Date"}})
 	if err != nil {
 		return err
 	}
-	dateStr := now.Format("01/02/2006")
 	if result.Count == 0 {
-		err = parseDate(now)
+		err = parseDate(NewSource(), nepse.DataDir(), now)
 		if err != nil {
 			return err
 		}
+		// make the new file visible without waiting for the next poll
+		if _, err := nepse.SyncCSVStock(); err != nil {
+			return err
+		}
 	}
-	return RenameHeaders(dateStr, dateStr)
+	return nil
 }
 
 /*err := renameDir("./data/date2", "<year>_<month>_<date>.csv", "<year>-<month>-<date>.csv")
@@ -179,57 +195,23 @@ if err != nil {
 	panic(err)
 }*/
 
-func parseDate(date time.Time) error {
-	dateStr := date.Format("01/02/2006")
-	url := "https://www.sharesansar.com/today-share-price"
-	df := scrapeData(url, dateStr)
+// parseDate fetches the share price table of date from src and saves it as <dir>/<date>.csv
+func parseDate(src Source, dir string, date time.Time) error {
+	df, err := src.Fetch(date)
+	if err != nil {
+		return err
+	}
 	finalDf := cleanDf(df)
-	path := fmt.Sprintf("data/date/%s.csv", date.Format(time.DateOnly))
-	saveCSV(finalDf, path)
-	return RenameHeaders(path, path)
-}
-
-func scrapeData(url string, date string) [][]string {
-	c := colly.NewCollector(
-		colly.AllowedDomains("www.sharesansar.com"),
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/102.0.5005.115 Safari/537.36"),
-	)
-
-	var df [][]string
-	var headers []string
-
-	c.OnHTML("table.table-bordered", func(e *colly.HTMLElement) {
-		e.ForEach("tr", func(_ int, el *colly.HTMLElement) {
-			var row []string
-			el.ForEach("th, td", func(_ int, cell *colly.HTMLElement) {
-				text := strings.TrimSpace(cell.Text)
-				row = append(row, text)
-			})
-			if len(headers) == 0 {
-				headers = row
-			} else {
-				df = append(df, row)
-			}
-		})
-	})
-
-	c.OnRequest(func(r *colly.Request) {
-		fmt.Println("Visiting", r.URL.String())
-		r.Ctx.Put("date", date)
-	})
-
-	c.OnResponse(func(r *colly.Response) {
-		fmt.Println("Visited", r.Request.URL.String())
-	})
-
-	c.OnError(func(r *colly.Response, err error) {
-		log.Println("Error:", r.StatusCode, err)
-	})
-
-	// Visit the target URL
-	c.Visit(url)
-
-	return append([][]string{headers}, df...)
+	path := filepath.Join(dir, fmt.Sprintf("%s.csv", date.Format(time.DateOnly)))
+	if err := saveCSV(finalDf, path); err != nil {
+		return err
+	}
+	if err := RenameHeaders(path, path, src.Headers()); err != nil {
+		// a file with page headers would be taken as already scraped
+		os.Remove(path)
+		return err
+	}
+	return nil
 }
 
 func cleanDf(df [][]string) [][]string {
@@ -251,20 +233,16 @@ func cleanDf(df [][]string) [][]string {
 	return newDf
 }
 
-func saveCSV(data [][]string, filename string) {
+func saveCSV(data [][]string, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
-		log.Fatalf("Could not create CSV file: %v", err)
+		return fmt.Errorf("could not create CSV file: %v", err)
 	}
 	defer file.Close()
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	for _, row := range data {
-		err := writer.Write(row)
-		if err != nil {
-			log.Fatalf("Could not write to CSV file: %v", err)
-		}
+	if err := writer.WriteAll(data); err != nil {
+		return fmt.Errorf("could not write to CSV file: %v", err)
 	}
+	return nil
 }
@@ -0,0 +1,100 @@
+package scrape
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	tradingDate = time.Date(2024, 8, 5, 0, 0, 0, 0, time.UTC)
+	closedDate  = time.Date(2024, 8, 3, 0, 0, 0, 0, time.UTC)
+)
+
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func replayServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	page, err := os.ReadFile(filepath.Join("testdata", "2024-08-05.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(page)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseDateHTTPSource(t *testing.T) {
+	assert := assert.New(t)
+	server := replayServer(t)
+	dir := t.TempDir()
+	record := t.TempDir()
+
+	src := &HTTPSource{URL: server.URL, Selector: defaultSelector, RecordDir: record}
+	assert.Nil(parseDate(src, dir, tradingDate))
+
+	records := readCSV(t, filepath.Join(dir, "2024-08-05.csv"))
+	assert.Len(records, 3) // header and two unique rows
+	assert.Equal([]string{"Symbol", "Confidence", "OpenPrice", "HighPrice", "LowPrice", "ClosePrice"}, records[0][:6])
+	assert.Equal("52WeeksLow", records[0][19])
+	assert.Equal([]string{"ADBL", "45.09", "544.00"}, records[1][:3])
+	assert.Equal("93,937.00", records[1][7])
+
+	// the recorded page replays to the same CSV
+	replayed := t.TempDir()
+	assert.Nil(parseDate(&ReplaySource{Dir: record, Selector: defaultSelector}, replayed, tradingDate))
+	assert.Equal(records, readCSV(t, filepath.Join(replayed, "2024-08-05.csv")))
+}
+
+func TestParseDateReplaySource(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	src := &ReplaySource{Dir: "testdata", Selector: defaultSelector}
+
+	assert.Nil(parseDate(src, dir, tradingDate))
+	assert.FileExists(filepath.Join(dir, "2024-08-05.csv"))
+
+	// a closed market renders the table without rows
+	assert.ErrorIs(parseDate(src, dir, closedDate), ErrNoData)
+	assert.NoFileExists(filepath.Join(dir, "2024-08-03.csv"))
+
+	// wrong selector
+	src.Selector = "table.missing"
+	assert.ErrorIs(parseDate(src, dir, tradingDate), ErrNoData)
+}
+
+func TestParseDateCustomHeaders(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	mapping := map[string]string{}
+	for page, column := range headerMapping {
+		mapping[page] = column
+	}
+	mapping["Vol"] = "TradedShares"
+	src := &ReplaySource{Dir: "testdata", Selector: defaultSelector, Mapping: mapping}
+
+	assert.Nil(parseDate(src, dir, tradingDate))
+	records := readCSV(t, filepath.Join(dir, "2024-08-05.csv"))
+	assert.Equal("TradedShares", records[0][7])
+}
@@ -0,0 +1,200 @@
+package scrape
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+
+	"github.com/oarkflow/nepse/config"
+)
+
+const (
+	defaultURL       = "https://www.sharesansar.com/today-share-price"
+	defaultSelector  = "table.table-bordered"
+	defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/102.0.5005.115 Safari/537.36"
+)
+
+// ErrNoData is returned when a page has no share price rows for the date,
+// usually because the market was closed
+var ErrNoData = errors.New("no share price data")
+
+// ErrLatestOnly is returned when fetching past dates from a source that only shows the latest trading day
+var ErrLatestOnly = errors.New("source fetches the latest trading day only")
+
+// Source fetches the share price table of a trading date.
+// The first row is the header row, as printed on the page.
+type Source interface {
+	Fetch(date time.Time) ([][]string, error)
+	// Headers maps the page headers to the CSV headers
+	Headers() map[string]string
+}
+
+// HTTPSource scrapes the share price table from a web page.
+// When DateParam is set the date is posted as that form field, along with Form,
+// otherwise the page is fetched as is and shows the latest trading day.
+type HTTPSource struct {
+	URL        string
+	Selector   string
+	UserAgent  string
+	Mapping    map[string]string
+	DateParam  string
+	DateFormat string
+	Form       map[string]string
+	// RecordDir, when not empty, keeps every fetched page as <date>.html for ReplaySource
+	RecordDir string
+}
+
+// Fetch visits the page and parses its share price table
+func (s *HTTPSource) Fetch(date time.Time) ([][]string, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	userAgent := s.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	c := colly.NewCollector(
+		colly.AllowedDomains(u.Hostname()),
+		colly.UserAgent(userAgent),
+	)
+
+	var body []byte
+	c.OnRequest(func(r *colly.Request) {
+		fmt.Println("Visiting", r.URL.String())
+	})
+	c.OnResponse(func(r *colly.Response) {
+		fmt.Println("Visited", r.Request.URL.String())
+		body = r.Body
+	})
+
+	if s.DateParam == "" {
+		err = c.Visit(s.URL)
+	} else {
+		err = c.Post(s.URL, s.formData(date))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.RecordDir != "" {
+		if err := os.MkdirAll(s.RecordDir, 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(pagePath(s.RecordDir, date), body, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return parseTable(bytes.NewReader(body), s.Selector)
+}
+
+func (s *HTTPSource) formData(date time.Time) map[string]string {
+	layout := s.DateFormat
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	form := make(map[string]string, len(s.Form)+1)
+	for key, value := range s.Form {
+		form[key] = value
+	}
+	form[s.DateParam] = date.Format(layout)
+	return form
+}
+
+// ByDate reports whether Fetch gets the page of the date asked for, rather than the latest trading day
+func (s *HTTPSource) ByDate() bool {
+	return s.DateParam != ""
+}
+
+// Headers maps the page headers to the CSV headers
+func (s *HTTPSource) Headers() map[string]string {
+	return mappingOrDefault(s.Mapping)
+}
+
+// ReplaySource reads saved pages named <date>.html from Dir, instead of visiting the web
+type ReplaySource struct {
+	Dir      string
+	Selector string
+	Mapping  map[string]string
+}
+
+// Fetch parses the share price table of the page saved for date
+func (s *ReplaySource) Fetch(date time.Time) ([][]string, error) {
+	file, err := os.Open(pagePath(s.Dir, date))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseTable(file, s.Selector)
+}
+
+// Headers maps the page headers to the CSV headers
+func (s *ReplaySource) Headers() map[string]string {
+	return mappingOrDefault(s.Mapping)
+}
+
+// NewSource returns the Source configured in the [scrape] section of config.ini
+func NewSource() Source {
+	conf := config.Config
+	selector := conf.ScrapeSelector
+	if selector == "" {
+		selector = defaultSelector
+	}
+	if conf.ScrapeSource == "replay" {
+		return &ReplaySource{Dir: conf.ScrapeReplayDir, Selector: selector, Mapping: conf.ScrapeHeaders}
+	}
+	u := conf.ScrapeURL
+	if u == "" {
+		u = defaultURL
+	}
+	return &HTTPSource{
+		URL:        u,
+		Selector:   selector,
+		Mapping:    conf.ScrapeHeaders,
+		DateParam:  conf.ScrapeDateParam,
+		DateFormat: conf.ScrapeDateFormat,
+		Form:       conf.ScrapeForm,
+		RecordDir:  conf.ScrapeRecordDir,
+	}
+}
+
+func pagePath(dir string, date time.Time) string {
+	return filepath.Join(dir, date.Format(time.DateOnly)+".html")
+}
+
+func mappingOrDefault(mapping map[string]string) map[string]string {
+	if len(mapping) == 0 {
+		return headerMapping
+	}
+	return mapping
+}
+
+// parseTable reads the rows of the first table matching selector, header row first
+func parseTable(r io.Reader, selector string) ([][]string, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var df [][]string
+	doc.Find(selector).First().Find("tr").Each(func(_ int, tr *goquery.Selection) {
+		var row []string
+		tr.Find("th, td").Each(func(_ int, cell *goquery.Selection) {
+			row = append(row, strings.TrimSpace(cell.Text()))
+		})
+		if len(row) > 0 {
+			df = append(df, row)
+		}
+	})
+	if len(df) < 2 {
+		return nil, ErrNoData
+	}
+	return df, nil
+}
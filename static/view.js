@@ -86,19 +86,18 @@ export function viewBacktestResults(results_element, results, onchangeFunc) {
 
     const time = new Date(results.timestamp)
 
-    results_element.innerHTML = `
-        <p>Symbol: ${results.symbol} Latest Time: ${time.toString()}</p>
-        <input type="checkbox" id="signal" value="ema">
-        [EMA] Performance: ${results.ema_performance} Short: ${results.ema_short} Long: ${results.ema_long}
-        <input type="checkbox" id="signal" value="bb">
-        [BB] Performance: ${results.bb_performance} N: ${results.bb_n} K: ${results.bb_k}
-        <input type="checkbox" id="signal" value="macd">
-        [MACD] Performance: ${results.macd_performance} Fast: ${results.macd_fast} Slow: ${results.macd_slow} Signal: ${results.macd_signal}
-        <input type="checkbox" id="signal" value="rsi">
-        [RSI] Performance: ${results.rsi_performance} Period: ${results.rsi_period} Buy: ${results.rsi_buythread} Sell: ${results.rsi_sellthread}
-        <input type="checkbox" id="signal" value="willr">
-        [WILLr] Performance: ${results.willr_performance} Period: ${results.willr_period} Buy: ${results.willr_buythread} Sell: ${results.willr_sellthread}
-    `
+    let html = `<p>Symbol: ${results.symbol} Latest Time: ${time.toString()}</p>`
+    for (let result of results.strategies) {
+        const params = Object.entries(result.params).map(([name, value]) => `${name}: ${value}`).join(" ")
+        html += `
+        <input type="checkbox" id="signal" value="${result.strategy}">
+        [${result.name}] Net: ${result.performance} (Gross: ${result.gross} Fees: ${result.fees} Tax: ${result.tax}) ${params}
+        <br>Return: ${result.total_return.toFixed(2)}% CAGR: ${result.cagr.toFixed(2)}% Max DD: ${result.max_drawdown.toFixed(2)}% (${result.max_drawdown_days} days)
+        Sharpe: ${result.sharpe.toFixed(2)} Sortino: ${result.sortino.toFixed(2)} Calmar: ${result.calmar.toFixed(2)}
+        Win: ${result.win_rate.toFixed(1)}% PF: ${result.profit_factor.toFixed(2)} Trades: ${result.trade_count}<br>
+        `
+    }
+    results_element.innerHTML = html
 
     // setting eventListener function for a part of signal
     const signals = results_element.querySelectorAll("#signal");
@@ -117,13 +116,13 @@ export function viewTrade(trade_element, results) {
         return
     }
 
-    trade_element.innerHTML = `
-        [EMA] <span style=${styleSet(results.last_ema, results.today_ema)}>${results.last_ema}</span>
-        [BB] <span style=${styleSet(results.last_bb, results.today_bb)}>${results.last_bb}</span>
-        [MACD] <span style=${styleSet(results.last_macd, results.today_macd)}>${results.last_macd}</span>
-        [RSI] <span style=${styleSet(results.last_rsi, results.today_rsi)}>${results.last_rsi}</span>
-        [WILLr] <span style=${styleSet(results.last_willr, results.today_willr)}>${results.last_willr}</span>
-    `
+    let html = ""
+    for (let trade of results) {
+        html += `
+        [${trade.name}] <span style=${styleSet(trade.last, trade.today)}>${trade.last}</span>
+        `
+    }
+    trade_element.innerHTML = html
 }
 
 function styleSet(signal, today_trade) {
@@ -148,7 +147,7 @@ function styleSet(signal, today_trade) {
 // viewSignal views signal(BUY or SELL) for some indicators, when checkbox is checked
 export function viewSignal(symbol, signalName, signals) {
     let data = []
-    for (let signal of signals[`${signalName}_signals`]) {
+    for (let signal of signals[signalName]) {
         data.push(
             {
                 x: signal.time,
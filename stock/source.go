@@ -0,0 +1,258 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oarkflow/search"
+
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/nepse"
+)
+
+// DataSource is where the daily sessions of the symbols come from
+type DataSource interface {
+	// Symbols returns the symbols having sessions, in alphabetical order
+	Symbols() ([]string, error)
+	// History returns the sessions of symbol from from to to, both included, a zero from or to
+	// leaving that end open, empty when symbol has none
+	History(symbol string, from, to time.Time) (*nepse.Series, error)
+	// Latest returns the last session of symbol, ErrNoData when it has none
+	Latest(symbol string) (nepse.StockData, error)
+	// Subscribe returns a channel getting the new sessions of every symbol, closed once ctx is done
+	Subscribe(ctx context.Context) (<-chan nepse.StockData, error)
+}
+
+var (
+	_ DataSource = (*StoreSource)(nil)
+	_ DataSource = (*EngineSource)(nil)
+	_ DataSource = (*CSVSource)(nil)
+)
+
+var (
+	// ErrNoData is returned for a symbol a source has no session of
+	ErrNoData = errors.New("no data")
+	// ErrNoSubscription is returned by the sources that can not tell new sessions
+	ErrNoSubscription = errors.New("source does not publish new sessions")
+)
+
+// StoreSource reads the sessions of a nepse.Store, new sessions are those added to the store
+type StoreSource struct {
+	store *nepse.Store
+}
+
+// NewStoreSource returns the source of the sessions of store
+func NewStoreSource(store *nepse.Store) *StoreSource {
+	return &StoreSource{store: store}
+}
+
+// NewMemorySource returns a source holding rows, an in-memory fixture for tests and replays:
+// sessions given to Add are sent to the subscribers
+func NewMemorySource(rows ...nepse.StockData) *StoreSource {
+	return NewStoreSource(nepse.NewStore(rows...))
+}
+
+// Store returns the store of s
+func (s *StoreSource) Store() *nepse.Store {
+	return s.store
+}
+
+// Add adds rows to the store of s
+func (s *StoreSource) Add(rows ...nepse.StockData) {
+	s.store.Add(rows...)
+}
+
+func (s *StoreSource) Symbols() ([]string, error) {
+	return s.store.Symbols(), nil
+}
+
+func (s *StoreSource) History(symbol string, from, to time.Time) (*nepse.Series, error) {
+	series, ok := s.store.Range(symbol, from, to)
+	if !ok {
+		return &nepse.Series{Symbol: symbol}, nil
+	}
+	return series, nil
+}
+
+func (s *StoreSource) Latest(symbol string) (nepse.StockData, error) {
+	last, ok := s.store.Last(symbol)
+	if !ok {
+		return nepse.StockData{}, fmt.Errorf("%w: %s", ErrNoData, symbol)
+	}
+	return last, nil
+}
+
+func (s *StoreSource) Subscribe(ctx context.Context) (<-chan nepse.StockData, error) {
+	return s.store.Subscribe(ctx), nil
+}
+
+// indexedSource is the source of nepse.StockStore(), not ready until the stock is indexed
+type indexedSource struct {
+	*StoreSource
+}
+
+// State returns the ingest state of the stock
+func (s indexedSource) State() nepse.IngestState {
+	return nepse.StockState()
+}
+
+// NewIndexedSource returns the source of nepse.StockStore(), filled by nepse.InitCSVStock.
+// Its State is that of the ingest, the server answers once the stock is indexed.
+func NewIndexedSource() DataSource {
+	return indexedSource{NewStoreSource(nepse.StockStore())}
+}
+
+// Readiness is implemented by the sources loading their sessions in the background
+type Readiness interface {
+	State() nepse.IngestState
+}
+
+// EngineSource queries the rows of a search engine, like the "stock" engine of nepse.InitCSVStock
+type EngineSource struct {
+	engine *search.Engine[map[string]any]
+	// updates is the store kept in sync with the engine, telling its new sessions
+	updates *nepse.Store
+}
+
+// NewEngineSource returns the source of the rows of engine. updates, which may be nil, is a store
+// kept in sync with engine, like nepse.StockStore(), the new sessions are those added to it.
+func NewEngineSource(engine *search.Engine[map[string]any], updates *nepse.Store) *EngineSource {
+	return &EngineSource{engine: engine, updates: updates}
+}
+
+// search returns the rows of the engine matching condition, every row when it is empty
+func (s *EngineSource) search(condition string) ([]nepse.StockData, error) {
+	result, err := s.engine.Search(&search.Params{
+		Condition: condition,
+		Limit:     s.engine.DocumentLen(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]nepse.StockData, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		row, err := nepse.StockDataFromMap(hit.Data)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (s *EngineSource) Symbols() ([]string, error) {
+	rows, err := s.search("")
+	if err != nil {
+		return nil, err
+	}
+	return nepse.NewStore(rows...).Symbols(), nil
+}
+
+func (s *EngineSource) History(symbol string, from, to time.Time) (*nepse.Series, error) {
+	// Symbol is matched exactly, a full text query would also match longer symbols like debentures of the company
+	first, last := "0001-01-01", "9999-12-31"
+	if !from.IsZero() {
+		first = from.Format(time.DateOnly)
+	}
+	if !to.IsZero() {
+		last = to.Format(time.DateOnly)
+	}
+	rows, err := s.search(fmt.Sprintf("Symbol = '%s' AND Date BETWEEN '%s' AND '%s'",
+		strings.ReplaceAll(symbol, "'", "''"), first, last))
+	if err != nil {
+		return nil, err
+	}
+	series, ok := nepse.NewStore(rows...).Series(symbol)
+	if !ok {
+		return &nepse.Series{Symbol: symbol}, nil
+	}
+	return series, nil
+}
+
+func (s *EngineSource) Latest(symbol string) (nepse.StockData, error) {
+	return latest(s, symbol)
+}
+
+func (s *EngineSource) Subscribe(ctx context.Context) (<-chan nepse.StockData, error) {
+	if s.updates == nil {
+		return nil, ErrNoSubscription
+	}
+	return s.updates.Subscribe(ctx), nil
+}
+
+// latest returns the last session of the history of symbol in source
+func latest(source DataSource, symbol string) (nepse.StockData, error) {
+	series, err := source.History(symbol, time.Time{}, time.Time{})
+	if err != nil {
+		return nepse.StockData{}, err
+	}
+	if series.Len() == 0 {
+		return nepse.StockData{}, fmt.Errorf("%w: %s", ErrNoData, symbol)
+	}
+	return series.Row(series.Len() - 1), nil
+}
+
+// CSVSource reads the daily CSV files of a directory, named by their date like the files of nepse.InitCSVStock
+type CSVSource struct {
+	*StoreSource
+	dir      string
+	calendar *calendar.Calendar
+
+	mu     sync.Mutex
+	loaded map[string]bool
+}
+
+// NewCSVSource returns the source of the CSV files of dir, read at once. The files of the dates cal
+// has the market closed on, like the Friday and Saturday files repeating Thursday, are left out,
+// a nil cal keeps every file.
+func NewCSVSource(dir string, cal *calendar.Calendar) (*CSVSource, error) {
+	s := &CSVSource{StoreSource: NewMemorySource(), dir: dir, calendar: cal, loaded: make(map[string]bool)}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Refresh reads the files added to the directory since the last read, oldest first,
+// their sessions are sent to the subscribers
+func (s *CSVSource) Refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.csv"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		if _, err := os.Stat(s.dir); err != nil {
+			return err
+		}
+	}
+	// the names are dates, in order
+	sort.Strings(paths)
+	for _, path := range paths {
+		if s.loaded[path] {
+			continue
+		}
+		if date, err := time.Parse(time.DateOnly, strings.TrimSuffix(filepath.Base(path), ".csv")); err == nil &&
+			s.calendar != nil && !s.calendar.IsTradingDay(date) {
+			s.loaded[path] = true
+			continue
+		}
+		rows, err := nepse.ParseCSVFile(path, nil)
+		if err != nil {
+			return err
+		}
+		if err := s.store.AddRows(rows); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		s.loaded[path] = true
+	}
+	return nil
+}
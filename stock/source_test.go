@@ -0,0 +1,155 @@
+package stock_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/search"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/stock"
+)
+
+const csvHeader = "Symbol,Confidence,OpenPrice,HighPrice,LowPrice,ClosePrice,VWAP,Volume,PreviousClose,Turnover,Transactions,Difference,Range,DifferencePercentage,RangePercentage,VWAPPercentage,120Days,180Days,52WeeksHigh,52WeeksLow\n"
+
+func day(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func row(symbol, date string, close float64) nepse.StockData {
+	return nepse.StockData{Symbol: symbol, Date: day(date), OpenPrice: close, HighPrice: close + 1,
+		LowPrice: close - 1, ClosePrice: close, VWAP: close, Volume: 10, Turnover: 10 * close, Transactions: 2}
+}
+
+// testSource checks source holds the sessions of ADBL on 2024-08-04 and 2024-08-05 and of NABIL on 2024-08-04
+func testSource(t *testing.T, source stock.DataSource) {
+	assert := assert.New(t)
+	symbols, err := source.Symbols()
+	assert.Nil(err)
+	assert.Equal([]string{"ADBL", "NABIL"}, symbols)
+
+	series, err := source.History("ADBL", time.Time{}, time.Time{})
+	assert.Nil(err)
+	assert.Equal([]time.Time{day("2024-08-04"), day("2024-08-05")}, series.Date)
+	series, err = source.History("ADBL", day("2024-08-05"), time.Time{})
+	assert.Nil(err)
+	assert.Equal([]time.Time{day("2024-08-05")}, series.Date)
+	series, err = source.History("ADB", time.Time{}, time.Time{})
+	assert.Nil(err)
+	assert.Equal(0, series.Len())
+
+	last, err := source.Latest("ADBL")
+	assert.Nil(err)
+	assert.Equal(day("2024-08-05"), last.Date)
+	_, err = source.Latest("TEST")
+	assert.True(errors.Is(err, stock.ErrNoData))
+}
+
+func TestMemorySource(t *testing.T) {
+	assert := assert.New(t)
+	source := stock.NewMemorySource(row("ADBL", "2024-08-04", 545), row("ADBL", "2024-08-05", 550), row("NABIL", "2024-08-04", 500))
+	testSource(t, source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := source.Subscribe(ctx)
+	assert.Nil(err)
+	// a replaced session is not new
+	source.Add(row("ADBL", "2024-08-05", 555), row("ADBL", "2024-08-06", 560))
+	assert.Equal(day("2024-08-06"), (<-ch).Date)
+	cancel()
+	_, open := <-ch
+	assert.False(open)
+}
+
+func TestEngineSource(t *testing.T) {
+	assert := assert.New(t)
+	engine, err := search.New[map[string]any](&search.Config{})
+	assert.Nil(err)
+	var rows []map[string]any
+	for _, r := range []nepse.StockData{row("ADBL", "2024-08-04", 545), row("ADBL", "2024-08-05", 550),
+		row("NABIL", "2024-08-04", 500), row("ADBLD83", "2024-08-04", 1000)} {
+		rows = append(rows, r.Map())
+	}
+	engine.InsertWithPool(rows, 1, 10)
+
+	source := stock.NewEngineSource(engine, nil)
+	series, err := source.History("ADBL", time.Time{}, time.Time{})
+	assert.Nil(err)
+	assert.Equal([]float64{545, 550}, series.Close)
+	last, err := source.Latest("ADBL")
+	assert.Nil(err)
+	assert.Equal(550.0, last.ClosePrice)
+	_, err = source.Subscribe(context.Background())
+	assert.True(errors.Is(err, stock.ErrNoSubscription))
+}
+
+func TestCSVSource(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	write := func(name string, rows ...string) {
+		content := csvHeader
+		for _, row := range rows {
+			content += row + "\n"
+		}
+		assert.Nil(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("2024-08-04.csv",
+		`ADBL,45.09,544.00,554.00,538.00,548.50,546.77,"93,937.00",544.00,"51,361,611.70",858,4.50,16.00,0.83,2.97,0.32,477.79,473.82,620.00,398.00`,
+		`NABIL,45.09,500.00,510.00,495.00,505.00,503.00,"1,000.00",500.00,"503,000.00",10,5.00,15.00,1.00,3.03,0.40,490.00,480.00,600.00,400.00`)
+	write("2024-08-05.csv",
+		`ADBL,45.09,548.50,560.00,545.00,555.00,552.00,"80,000.00",548.50,"44,160,000.00",700,6.50,15.00,1.19,2.75,0.54,478.00,474.00,620.00,398.00`)
+
+	source, err := stock.NewCSVSource(dir, nil)
+	assert.Nil(err)
+	testSource(t, source)
+
+	// a new file is read by Refresh and its sessions sent to the subscribers
+	ch, _ := source.Subscribe(context.Background())
+	write("2024-08-06.csv",
+		`ADBL,45.09,555.00,565.00,550.00,560.00,558.00,"70,000.00",555.00,"39,060,000.00",600,5.00,15.00,0.90,2.70,0.54,478.00,474.00,620.00,398.00`)
+	assert.Nil(source.Refresh())
+	latest := <-ch
+	assert.Equal("ADBL", latest.Symbol)
+	assert.Equal(560.0, latest.ClosePrice)
+
+	_, err = stock.NewCSVSource(filepath.Join(dir, "missing"), nil)
+	assert.NotNil(err)
+}
+
+func TestCSVSourceCalendar(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	// a file every day up to today, those of Friday and Saturday repeating Thursday like the scraped files
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for date := today.AddDate(0, 0, -21); !date.After(today); date = date.AddDate(0, 0, 1) {
+		session := date
+		for session.Weekday() == time.Friday || session.Weekday() == time.Saturday {
+			session = session.AddDate(0, 0, -1)
+		}
+		close := fmt.Sprintf("%d.00", 100+session.Day())
+		row := "NABIL,45.09," + strings.Repeat(close+",", 4) + close + `,"1,000.00",100.00,"100,000.00",10,0,0,0,0,0,0,0,0,0`
+		assert.Nil(os.WriteFile(filepath.Join(dir, date.Format(time.DateOnly)+".csv"), []byte(csvHeader+row+"\n"), 0o644))
+	}
+
+	cal := calendar.New()
+	source, err := stock.NewCSVSource(dir, cal)
+	assert.Nil(err)
+	client := stock.NewClient(source)
+	client.Calendar = cal
+	q, err := client.GetStockData("NABIL", 10, false)
+	assert.Nil(err)
+	// ten sessions across the weekends, none of them on a Friday or Saturday
+	assert.Len(q.Date, 10)
+	for _, date := range q.Date {
+		assert.True(cal.IsTradingDay(date), date)
+	}
+}
@@ -1,57 +1,274 @@
 package stock
 
 import (
-	"fmt"
+	"errors"
+	"math"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/markcheno/go-quote"
-	"github.com/oarkflow/search"
+	"github.com/sirupsen/logrus"
+
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/config"
+	"github.com/oarkflow/nepse/corpaction"
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
+	"github.com/oarkflow/nepse/techan"
 )
 
-const timeFormat = "2006-01-02"
+// Client reads the sessions of symbols from a DataSource. The symbol master, lineage, corporate actions
+// and calendar left nil are empty, accepting every symbol and closed on the weekends only.
+type Client struct {
+	Source   DataSource
+	Master   *listing.Master
+	Lineage  *listing.Lineage
+	Actions  *corpaction.Store
+	Calendar *calendar.Calendar
+}
+
+// NewClient returns a Client reading source
+func NewClient(source DataSource) *Client {
+	return &Client{Source: source}
+}
+
+// NewClientFromConfig returns a Client reading source with the symbol master, lineage, corporate actions
+// and holidays of the files of the [data] section of config.ini, warning of the files listing nothing
+// as the features they drive stay off
+func NewClientFromConfig(source DataSource) *Client {
+	c := &Client{
+		Source:   source,
+		Master:   load("symbol master", config.Config.Symbols, listing.Load, listing.NewMaster()),
+		Lineage:  load("lineage", config.Config.Lineage, listing.LoadLineage, listing.NewLineage()),
+		Actions:  load("corporate action", config.Config.Actions, corpaction.Load, corpaction.NewStore()),
+		Calendar: load("holiday", config.Config.Holidays, calendar.Load, calendar.New()),
+	}
+	if c.Master.Len() == 0 {
+		logrus.Warnf("symbol master %s lists no ticker: every symbol is accepted, without sector and indices", config.Config.Symbols)
+	}
+	if len(c.Lineage.Links()) == 0 {
+		logrus.Warnf("lineage %s lists no rename or merger: no history is stitched", config.Config.Lineage)
+	}
+	if len(c.Actions.Symbols()) == 0 {
+		logrus.Warnf("corporate action file %s lists no action: adjusted prices are the raw prices", config.Config.Actions)
+	}
+	if len(c.Calendar.Holidays()) == 0 {
+		logrus.Warnf("holiday file %s lists no holiday: the market is closed on the weekends only", config.Config.Holidays)
+	}
+	return c
+}
+
+// load reads the file at path with read, empty when there is no such file or, logged, it is malformed
+func load[T any](name, path string, read func(string) (T, error), empty T) T {
+	v, err := read(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.Warnf("%s file %s error: %v", name, path, err)
+		}
+		return empty
+	}
+	return v
+}
+
+var (
+	defaultClient *Client
+	defaultOnce   sync.Once
+	defaultMutex  sync.RWMutex
+)
+
+// Default returns the client of nepse.StockStore(), the sessions indexed by nepse.InitCSVStock,
+// with the reference files of config.ini. It is built once, every package reads them through it.
+func Default() *Client {
+	defaultOnce.Do(func() {
+		defaultMutex.Lock()
+		defer defaultMutex.Unlock()
+		defaultClient = NewClientFromConfig(NewIndexedSource())
+	})
+	defaultMutex.RLock()
+	defer defaultMutex.RUnlock()
+	return defaultClient
+}
+
+// SetDefault replaces the client returned by Default
+func SetDefault(c *Client) {
+	defaultOnce.Do(func() {})
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultClient = c
+}
+
+func (c *Client) master() *listing.Master {
+	if c.Master != nil {
+		return c.Master
+	}
+	return listing.NewMaster()
+}
+
+func (c *Client) lineage() *listing.Lineage {
+	if c.Lineage != nil {
+		return c.Lineage
+	}
+	return listing.NewLineage()
+}
+
+func (c *Client) actions() *corpaction.Store {
+	if c.Actions != nil {
+		return c.Actions
+	}
+	return corpaction.NewStore()
+}
+
+func (c *Client) calendar() *calendar.Calendar {
+	if c.Calendar != nil {
+		return c.Calendar
+	}
+	return calendar.New()
+}
 
-// GetStockData dawnloads daily stockdata for symbol(GOOGL, FB...etc) during today ~ before dayPeriod.
-// dayPeriod must be day(1day, 30days...etc).
-// If stock data is not dawnloaded due to bad symbol, output panic.
+// Check returns listing.ErrUnknownSymbol when the symbol master of c does not list symbol
+func (c *Client) Check(symbol string) error {
+	return c.master().Check(symbol)
+}
+
+// State returns the ingest state of the source of c and true, false when the source has no data
+// to wait for, its sessions being ready once it is created
+func (c *Client) State() (nepse.IngestState, bool) {
+	if r, ok := c.Source.(Readiness); ok {
+		return r.State(), true
+	}
+	return nepse.IngestState{}, false
+}
+
+// GetStockData dawnloads daily stockdata for symbol(NABIL, ADBL...etc) for the last dayPeriod trading sessions.
+// dayPeriod counts NEPSE sessions(1 session, 30 sessions...etc), holidays and weekends are not counted.
+// With adj the prices are back-adjusted for the corporate actions of Default(),
+// otherwise the raw prices are returned.
+// The sessions are read from the source of Default(), a symbol the symbol master does not list
+// is rejected with listing.ErrUnknownSymbol, any other bad symbol gives an empty Quote.
+// The history of tickers symbol was renamed or merged from is included, see GetStitchedData.
 func GetStockData(symbol string, dayPeriod int, adj bool) (*quote.Quote, error) {
+	return Default().GetStockData(symbol, dayPeriod, adj)
+}
+
+// GetStitchedData is GetStockData also returning the raw symbols the sessions come from.
+// Sessions of the predecessors of symbol in the lineage of Default() are adjusted by the swap ratio,
+// with adj for the corporate actions of every predecessor as well as those of symbol.
+func GetStitchedData(symbol string, dayPeriod int, adj bool) (*quote.Quote, []nepse.Segment, error) {
+	return Default().GetStitchedData(symbol, dayPeriod, adj)
+}
+
+// GetTimeSeries returns the daily candles of symbol from from to to, both included, a zero from or to
+// leaving that end open, with the volume, trade count, VWAP and turnover of every session.
+// Like GetStitchedData, the history of the predecessors of symbol is included and, with adj,
+// the prices and volume are back-adjusted for the corporate actions of Default().
+// An unknown symbol is rejected with listing.ErrUnknownSymbol, a symbol without sessions gives an empty series.
+func GetTimeSeries(symbol string, from, to time.Time, adj bool) (*techan.TimeSeries, []nepse.Segment, error) {
+	return Default().GetTimeSeries(symbol, from, to, adj)
+}
+
+// GetStockData is GetStockData reading the source of c
+func (c *Client) GetStockData(symbol string, dayPeriod int, adj bool) (*quote.Quote, error) {
+	qt, _, err := c.GetStitchedData(symbol, dayPeriod, adj)
+	return qt, err
+}
+
+// GetStitchedData is GetStitchedData reading the source of c
+func (c *Client) GetStitchedData(symbol string, dayPeriod int, adj bool) (*quote.Quote, []nepse.Segment, error) {
+	if err := c.Check(symbol); err != nil {
+		return nil, nil, err
+	}
 	endDay := time.Now()
-	startDay := endDay.AddDate(0, 0, -dayPeriod)
-	engine, err := search.GetEngine[map[string]any]("stock")
+	startDay := c.calendar().SessionsBack(endDay, dayPeriod)
+	series, segments, ok, err := c.stitch(symbol, startDay, endDay)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	result, err := engine.Search(&search.Params{
-		Query:      symbol,
-		Properties: []string{"Symbol"},
-		Condition:  fmt.Sprintf("Date BETWEEN '%s' AND %s", startDay.Format(timeFormat), endDay.Format(timeFormat)),
-	})
+	if !ok {
+		qt := quote.NewQuote(symbol, 0)
+		return &qt, nil, nil
+	}
+	qt := series.Quote()
+	if adj {
+		return c.AdjustQuote(qt, segments), segments, nil
+	}
+	return qt, segments, nil
+}
+
+// AdjustQuote back-adjusts q, the raw sessions stitched from segments by GetStitchedData,
+// for the corporate actions of c of every symbol the sessions come from
+func (c *Client) AdjustQuote(q *quote.Quote, segments []nepse.Segment) *quote.Quote {
+	return nepse.AdjustQuote(c.actions(), q, segments)
+}
+
+// GetTimeSeries is GetTimeSeries reading the source of c
+func (c *Client) GetTimeSeries(symbol string, from, to time.Time, adj bool) (*techan.TimeSeries, []nepse.Segment, error) {
+	if err := c.Check(symbol); err != nil {
+		return nil, nil, err
+	}
+	series, segments, ok, err := c.stitch(symbol, from, to)
 	if err != nil {
-		return nil, err
-	}
-	return GetQuote[map[string]any](symbol, result), nil
-}
-
-func GetQuote[T any](symbol string, result search.Result[T]) *quote.Quote {
-	numrows := result.FilteredTotal
-	qt := quote.NewQuote(symbol, numrows)
-	for i, row := range result.Hits {
-		switch row := any(row.Data).(type) {
-		case map[string]any:
-			// Parse row of data
-			d, _ := time.Parse("2006-01-02", row["Date"].(string))
-			o, _ := row["OpenPrice"].(float64)
-			h, _ := row["HighPrice"].(float64)
-			l, _ := row["LowPrice"].(float64)
-			c, _ := row["ClosePrice"].(float64)
-			v, _ := row["Volume"].(float64)
-
-			qt.Date[i] = d
-			qt.Open[i] = o
-			qt.High[i] = h
-			qt.Low[i] = l
-			qt.Close[i] = c
-			qt.Volume[i] = v
+		return nil, nil, err
+	}
+	if !ok {
+		return techan.NewTimeSeries(), nil, nil
+	}
+	ts := series.TimeSeries()
+	if adj {
+		return nepse.AdjustTimeSeries(c.actions(), ts, segments), segments, nil
+	}
+	return ts, segments, nil
+}
+
+// stitch returns the sessions of symbol from from to to preceded by those of its predecessors,
+// see nepse.Store.Stitch. Sources backed by a store are stitched in place, the history of symbol
+// and of every ticker it comes from is read from the others.
+func (c *Client) stitch(symbol string, from, to time.Time) (*nepse.Series, []nepse.Segment, bool, error) {
+	lineage := c.lineage()
+	if s, ok := c.Source.(interface{ Store() *nepse.Store }); ok {
+		series, segments, ok := s.Store().Stitch(symbol, from, to, lineage)
+		return series, segments, ok, nil
+	}
+	store := nepse.NewStore()
+	pending, seen := []string{symbol}, map[string]bool{symbol: true}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		// earlier sessions of the predecessors are needed to tell which one has sessions
+		series, err := c.Source.History(current, time.Time{}, to)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		for i := 0; i < series.Len(); i++ {
+			store.Add(series.Row(i))
+		}
+		for _, link := range lineage.Predecessors(current) {
+			if !seen[link.Predecessor] {
+				seen[link.Predecessor] = true
+				pending = append(pending, link.Predecessor)
+			}
+		}
+	}
+	series, segments, ok := store.Stitch(symbol, from, to, lineage)
+	return series, segments, ok, nil
+}
+
+// ResampleQuote returns the sessions of q, in date order, merged into the candles of tf like techan.Resample,
+// each dated at the start of its period. q itself is not changed.
+func ResampleQuote(q *quote.Quote, tf techan.Timeframe) *quote.Quote {
+	bins := tf.Bins(q.Date)
+	resampled := quote.NewQuote(q.Symbol, len(bins))
+	resampled.Precision = q.Precision
+	for n, bin := range bins {
+		resampled.Date[n] = bin.Period.Start
+		resampled.Open[n] = q.Open[bin.From]
+		resampled.Close[n] = q.Close[bin.To-1]
+		resampled.High[n], resampled.Low[n] = q.High[bin.From], q.Low[bin.From]
+		for i := bin.From; i < bin.To; i++ {
+			resampled.High[n] = math.Max(resampled.High[n], q.High[i])
+			resampled.Low[n] = math.Min(resampled.Low[n], q.Low[i])
+			resampled.Volume[n] += q.Volume[i]
 		}
 	}
-	return &qt
+	return &resampled
 }
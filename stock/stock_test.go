@@ -2,21 +2,70 @@ package stock_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/markcheno/go-quote"
+	"github.com/oarkflow/nepse/calendar"
+	"github.com/oarkflow/nepse/corpaction"
+	"github.com/oarkflow/nepse/listing"
+	"github.com/oarkflow/nepse/nepse"
 	"github.com/oarkflow/nepse/stock"
+	"github.com/oarkflow/nepse/techan"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetStockDataa(t *testing.T) {
 	assert := assert.New(t)
-	stock1, err1 := stock.GetStockData("VOO", 10, true)
-	stock2, err2 := stock.GetStockData("TEST", 10, true)
+	// sessions up to today, the period counting back from now
+	var rows []nepse.StockData
+	cal := calendar.New()
+	for _, date := range cal.TradingDays(time.Now().AddDate(0, -1, 0), time.Now()) {
+		rows = append(rows, nepse.StockData{Symbol: "VOO", Date: date, OpenPrice: 100, HighPrice: 101, LowPrice: 99, ClosePrice: 100})
+	}
+	client := stock.NewClient(stock.NewMemorySource(rows...))
+	client.Calendar = cal
+	stock1, err1 := client.GetStockData("VOO", 10, true)
+	stock2, err2 := client.GetStockData("TEST", 10, true)
 
 	assert.Nil(err1)
 	assert.Equal("VOO", stock1.Symbol)
-	assert.NotEmpty(stock1.Date)
+	assert.Len(stock1.Date, 10)
 	// wrong symbol
 	// err is nil, even if symbol is wrong
 	assert.Nil(err2)
 	assert.Len(stock2.Date, 0)
 }
+
+func TestResampleQuote(t *testing.T) {
+	assert := assert.New(t)
+	q := quote.NewQuote("ADBL", 3)
+	for i, d := range []string{"2024-08-01", "2024-08-04", "2024-08-05"} {
+		q.Date[i], _ = time.Parse(time.DateOnly, d)
+		q.Open[i], q.High[i], q.Low[i], q.Close[i], q.Volume[i] = 100, 110+float64(i), 90-float64(i), 105, 10
+	}
+	weekly := stock.ResampleQuote(&q, techan.Weekly)
+	assert.Len(weekly.Date, 2)
+	assert.Equal(time.Date(2024, 8, 4, 0, 0, 0, 0, time.UTC), weekly.Date[1])
+	assert.Equal(112.0, weekly.High[1])
+	assert.Equal(88.0, weekly.Low[1])
+	assert.Equal(20.0, weekly.Volume[1])
+	assert.Len(q.Date, 3)
+}
+
+func TestGetTimeSeriesPredecessorActions(t *testing.T) {
+	assert := assert.New(t)
+	client := stock.NewClient(stock.NewMemorySource(row("OLD", "2024-07-01", 400), row("OLD", "2024-07-02", 200),
+		row("NEW", "2024-07-07", 100)))
+	client.Lineage = listing.NewLineage(listing.Link{Predecessor: "OLD", Successor: "NEW", Effective: day("2024-07-07"), Ratio: 2})
+	client.Actions = corpaction.NewStore(corpaction.Action{Symbol: "OLD", BookClose: day("2024-07-02"), BonusPercent: 100})
+
+	ts, segments, err := client.GetTimeSeries("NEW", time.Time{}, time.Time{}, true)
+	assert.Nil(err)
+	assert.Len(segments, 2)
+	// the bonus of OLD adjusts its own sessions
+	for _, candle := range ts.Candles {
+		assert.InDelta(100, candle.ClosePrice.Float(), 1e-9)
+	}
+	raw, _, _ := client.GetTimeSeries("NEW", time.Time{}, time.Time{}, false)
+	assert.InDelta(200, raw.Candles[0].ClosePrice.Float(), 1e-9)
+}
@@ -40,6 +40,23 @@ func (tps TotalProfitAnalysis) Analyze(record *TradingRecord) float64 {
 	return totalProfit.Float()
 }
 
+// NetProfitAnalysis analyzes the trading record for total profit after the fees and tax of its trades,
+// the trades without costs counting their gross profit.
+type NetProfitAnalysis struct{}
+
+// Analyze analyzes the trading record for total profit after fees and tax.
+func (npa NetProfitAnalysis) Analyze(record *TradingRecord) float64 {
+	net := 0.0
+	for _, trade := range record.Trades {
+		if costs := trade.Costs(); costs != nil {
+			net += costs.Net
+		} else {
+			net += TotalProfitAnalysis{}.Analyze(&TradingRecord{Trades: []*Position{trade}})
+		}
+	}
+	return net
+}
+
 // PercentGainAnalysis analyzes the trading record for the percentage profit gained relative to start
 type PercentGainAnalysis struct{}
 
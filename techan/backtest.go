@@ -48,9 +48,12 @@ func (b fixedEntryBacktest) Run(startingEquity big.Decimal) (big.Decimal, *Tradi
 			price := b.priceIndicator.Calculate(i)
 			percentEquityFraction := b.orderPlan.PercentEquity.Div(big.NewDecimal(100.0))
 			allocation := equity.Mul(percentEquityFraction)
-			amount := allocation.Div(price)
-
 			side := b.orderPlan.Side
+			costed := b.orderPlan.Costs != nil && side == BUY
+			if costed {
+				allocation = allocation.Sub(big.NewDecimal(b.orderPlan.Costs.BuyFees(allocation.Float())))
+			}
+			amount := allocation.Div(price)
 
 			entryOrder := Order{
 				Side:          side,
@@ -62,6 +65,9 @@ func (b fixedEntryBacktest) Run(startingEquity big.Decimal) (big.Decimal, *Tradi
 
 			b.TradingRecord.Operate(entryOrder)
 			equity = equity.Sub(allocation)
+			if costed {
+				equity = equity.Sub(big.NewDecimal(b.orderPlan.Costs.BuyFees(allocation.Float())))
+			}
 		} else if b.strategy.ShouldExit(i, b.TradingRecord) {
 			price := b.priceIndicator.Calculate(i)
 			amount := b.TradingRecord.CurrentPosition().EntranceOrder().Amount
@@ -80,7 +86,18 @@ func (b fixedEntryBacktest) Run(startingEquity big.Decimal) (big.Decimal, *Tradi
 			}
 
 			b.TradingRecord.Operate(exitOrder)
-			equity = equity.Add(b.TradingRecord.LastTrade().ExitValue())
+			trade := b.TradingRecord.LastTrade()
+			if b.orderPlan.Costs != nil && trade.IsLong() {
+				entrance := trade.EntranceOrder()
+				costs := b.orderPlan.Costs.Trade(amount.Float(), entrance.ExecutionTime, entrance.Price.Float(),
+					exitOrder.ExecutionTime, price.Float())
+				trade.costs = &costs
+				// the buy fees were paid on entry
+				buyFees := b.orderPlan.Costs.BuyFees(trade.CostBasis().Float())
+				equity = equity.Add(trade.CostBasis()).Add(big.NewDecimal(costs.Net + buyFees))
+			} else {
+				equity = equity.Add(trade.ExitValue())
+			}
 		}
 	}
 
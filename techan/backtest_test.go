@@ -2,6 +2,7 @@ package techan
 
 import (
 	"github.com/oarkflow/nepse/big"
+	"github.com/oarkflow/nepse/fees"
 	"testing"
 	"time"
 )
@@ -124,3 +125,45 @@ func TestNewFixedEntryBacktest(t *testing.T) {
 		t.Errorf("expected 2 trades, found %v", len(tradeRec.Trades))
 	}
 }
+
+func TestFixedEntryBacktestCosts(t *testing.T) {
+	ts := createTestTimeSeries(t)
+	priceInd := createTestPriceIndicator(t, ts)
+	strat := createTestStrategy(t, priceInd)
+	op := createTestOrderPlan(t)
+	op.Costs = fees.NEPSE()
+
+	frictionless, _ := NewFixedEntryBacktest("TEST", ts, priceInd, strat, createTestOrderPlan(t)).Run(startingEquity)
+	endingEquity, tradeRec := NewFixedEntryBacktest("TEST", ts, priceInd, strat, op).Run(startingEquity)
+
+	if len(tradeRec.Trades) != 2 {
+		t.Fatalf("expected 2 trades, found %v", len(tradeRec.Trades))
+	}
+	if !endingEquity.LT(frictionless) {
+		t.Errorf("ending equity with costs not lower than without. expected: <%v, got: %v", frictionless, endingEquity)
+	}
+
+	net := 0.0
+	for _, trade := range tradeRec.Trades {
+		costs := trade.Costs()
+		if costs == nil {
+			t.Fatal("expected the costs of the trade")
+		}
+		// each side pays at least the minimum commission, the sell also the DP charge
+		if costs.Fees < 2*op.Costs.BrokerMin+op.Costs.DP {
+			t.Errorf("expected fees of at least %v, got %v", 2*op.Costs.BrokerMin+op.Costs.DP, costs.Fees)
+		}
+		if costs.Net != costs.Gross-costs.Fees-costs.Tax {
+			t.Errorf("expected net %v, got %v", costs.Gross-costs.Fees-costs.Tax, costs.Net)
+		}
+		net += costs.Net
+	}
+
+	if got := (NetProfitAnalysis{}).Analyze(tradeRec); got != net {
+		t.Errorf("expected net profit %v, got %v", net, got)
+	}
+	// the equity left is what the trades made after fees and tax
+	if diff := endingEquity.Sub(startingEquity).Float() - net; diff > 1e-6 || diff < -1e-6 {
+		t.Errorf("expected ending equity %v, got %v", startingEquity.Float()+net, endingEquity)
+	}
+}
@@ -20,6 +20,9 @@ type Candle struct {
 	TradeCount uint
 	CTime      time.Time
 	Confirm    int
+	// VWAP is the volume weighted average price and Turnover the traded amount, when the source gives them
+	VWAP     big.Decimal
+	Turnover big.Decimal
 }
 
 var candlePool = sync.Pool{
@@ -38,6 +41,8 @@ func (c *Candle) ReturnToPool() {
 	c.MinPrice.ReturnToPool()
 	c.ClosePrice.ReturnToPool()
 	c.OpenPrice.ReturnToPool()
+	c.VWAP.ReturnToPool()
+	c.Turnover.ReturnToPool()
 
 	*c = Candle{} //nolint:exhaustivestruct
 	candlePool.Put(c)
@@ -56,6 +61,8 @@ func NewCandle(period TimePeriod) (c *Candle) {
 		MaxPrice:   big.ZERO,
 		MinPrice:   big.ZERO,
 		Volume:     big.ZERO,
+		VWAP:       big.ZERO,
+		Turnover:   big.ZERO,
 	}
 }
 
@@ -128,14 +135,31 @@ func (c *Candle) Complete(candle *Candle) {
 	if c.OpenPrice.NaN() {
 		c.OpenPrice = candle.OpenPrice
 	}
+
+	if c.VWAP.NaN() {
+		c.VWAP = candle.VWAP
+	}
+
+	if c.Turnover.NaN() {
+		c.Turnover = candle.Turnover
+	}
 }
 
+// MergeCandle returns a function merging the candles it is called with, in ascending order, into candles
+// of dur starting at begin, and returning the candle being merged into. The first window is the one of
+// the windows of dur from begin the first candle falls in.
+//
+// Deprecated: use Resample, which merges VWAP and turnover and aligns weeks and months to the calendar.
 func MergeCandle(begin time.Time, dur time.Duration) func(*Candle) Candle {
 	var lastCandle *Candle
 	return func(c *Candle) Candle {
 		if lastCandle == nil {
-			if c.Period.Start.After(begin.Add(dur)) {
-				panic(fmt.Sprintf("the first candle not between %s and %s", begin, begin.Add(dur)))
+			if offset := c.Period.Start.Sub(begin); dur > 0 && (offset < 0 || offset >= dur) {
+				windows := offset / dur
+				if offset < 0 && offset%dur != 0 {
+					windows--
+				}
+				begin = begin.Add(windows * dur)
 			}
 			lastCandle = &Candle{
 				Period:     NewTimePeriod(begin, dur),
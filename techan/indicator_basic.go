@@ -73,6 +73,32 @@ func (lpi lowPriceIndicator) Calculate(index int) big.Decimal {
 	return lpi.Candles[index].MinPrice
 }
 
+// NewVWAPIndicator returns an Indicator which returns the volume weighted average price of a candle for a given index
+func NewVWAPIndicator(series *TimeSeries) Indicator {
+	return vwapIndicator{series}
+}
+
+type vwapIndicator struct {
+	*TimeSeries
+}
+
+func (vi vwapIndicator) Calculate(index int) big.Decimal {
+	return vi.Candles[index].VWAP
+}
+
+// NewTurnoverIndicator returns an Indicator which returns the turnover, the amount traded, of a candle for a given index
+func NewTurnoverIndicator(series *TimeSeries) Indicator {
+	return turnoverIndicator{series}
+}
+
+type turnoverIndicator struct {
+	*TimeSeries
+}
+
+func (ti turnoverIndicator) Calculate(index int) big.Decimal {
+	return ti.Candles[index].Turnover
+}
+
 // NewTypicalPriceIndicator returns an Indicator which returns the typical price of a candle for a given index.
 // The typical price is an average of the high, low, and close prices for a given candle.
 func NewTypicalPriceIndicator(series *TimeSeries) Indicator {
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/oarkflow/nepse/big"
+	"github.com/oarkflow/nepse/fees"
 )
 
 // OrderSide is a simple enumeration representing the side of an Order (buy or sell)
@@ -27,7 +28,10 @@ type Order struct {
 // OrderPlan defines how to construct an Order object during execution of a Strategy.
 // The `PercentEquity` field should be between 0.00 and 100.00, corresponding to the
 // percent of the overall portfolio allocated to a given position.
+// Costs, when not nil, are the fees and tax paid by the long positions, the allocation
+// covering the shares and their buy fees.
 type OrderPlan struct {
 	Side          OrderSide
 	PercentEquity big.Decimal
+	Costs         *fees.Schedule
 }
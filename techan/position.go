@@ -1,10 +1,14 @@
 package techan
 
-import "github.com/oarkflow/nepse/big"
+import (
+	"github.com/oarkflow/nepse/big"
+	"github.com/oarkflow/nepse/fees"
+)
 
 // Position is a pair of two Order objects
 type Position struct {
 	orders [2]*Order
+	costs  *fees.Trade
 }
 
 // NewPosition returns a new Position with the passed-in order as the open order
@@ -76,3 +80,9 @@ func (p *Position) ExitValue() big.Decimal {
 
 	return big.ZERO
 }
+
+// Costs returns the gross, fees, tax and net of this position once closed by a backtest with costs,
+// nil otherwise
+func (p *Position) Costs() *fees.Trade {
+	return p.costs
+}
@@ -0,0 +1,200 @@
+package techan
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oarkflow/nepse/big"
+)
+
+// TimeframeUnit is what the candles of a Timeframe are counted in
+type TimeframeUnit string
+
+const (
+	// Sessions are trading sessions, the daily candles of a series
+	Sessions TimeframeUnit = "d"
+	// Weeks are NEPSE trading weeks, Sunday to Thursday
+	Weeks TimeframeUnit = "w"
+	// Months are calendar months
+	Months TimeframeUnit = "m"
+)
+
+// Timeframe is the span of the candles of a resampled series, N sessions, weeks or months
+type Timeframe struct {
+	Unit TimeframeUnit
+	N    int
+}
+
+var (
+	Daily   = Timeframe{Unit: Sessions, N: 1}
+	Weekly  = Timeframe{Unit: Weeks, N: 1}
+	Monthly = Timeframe{Unit: Months, N: 1}
+)
+
+// ParseTimeframe parses a timeframe like "5d" (5 sessions), "w" or "1w" (weekly), "2w" (two weeks), "m" or "1m" (monthly),
+// "daily", "weekly" and "monthly" are also accepted and an empty string is daily
+func ParseTimeframe(s string) (Timeframe, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "daily":
+		return Daily, nil
+	case "weekly":
+		return Weekly, nil
+	case "monthly":
+		return Monthly, nil
+	}
+	tf := Timeframe{Unit: TimeframeUnit(s[len(s)-1:]), N: 1}
+	if tf.Unit != Sessions && tf.Unit != Weeks && tf.Unit != Months {
+		return Timeframe{}, fmt.Errorf("bad timeframe %q: want a number of sessions (d), weeks (w) or months (m)", s)
+	}
+	if n := s[:len(s)-1]; n != "" {
+		var err error
+		if tf.N, err = strconv.Atoi(n); err != nil || tf.N < 1 {
+			return Timeframe{}, fmt.Errorf("bad timeframe %q: want a positive count", s)
+		}
+	}
+	return tf, nil
+}
+
+// String returns tf in the format of ParseTimeframe
+func (tf Timeframe) String() string {
+	return strconv.Itoa(tf.N) + string(tf.Unit)
+}
+
+// IsDaily reports whether tf leaves daily candles as they are
+func (tf Timeframe) IsDaily() bool {
+	return tf.Unit == Sessions && tf.N <= 1
+}
+
+// Bin is the sessions From to To, To excluded, of a daily series making one candle of a timeframe
+type Bin struct {
+	From, To int
+	// Period is the period of the candle, the whole weeks or months of the timeframe
+	// or the days from the first session to the last one
+	Period TimePeriod
+	// Complete is false for a bin that may still get sessions: a calendar bin no later session
+	// follows or a bin of fewer than N sessions
+	Complete bool
+}
+
+// weekAnchor is a Sunday, N week bins are counted from it
+var weekAnchor = time.Date(1970, time.January, 4, 0, 0, 0, 0, time.UTC)
+
+// Bins splits dates, the ascending start of the daily sessions of a series, into the bins of tf.
+// Weeks start on Sunday, the first day of the NEPSE week, and months on the first, both in the location
+// of the dates. Weeks or months without sessions, like those of long holidays or suspensions, give no bin,
+// the bins of a partial first or last period hold the sessions there are. N session bins count from the first session.
+func (tf Timeframe) Bins(dates []time.Time) []Bin {
+	n := tf.N
+	if n < 1 {
+		n = 1
+	}
+	var bins []Bin
+	for i := 0; i < len(dates); {
+		bin := Bin{From: i}
+		if tf.Unit == Sessions {
+			bin.To = i + n
+			if bin.To > len(dates) {
+				bin.To = len(dates)
+			}
+			last := dates[bin.To-1]
+			bin.Period = NewTimePeriod(dates[i], startOfDay(last).AddDate(0, 0, 1).Sub(dates[i]))
+			bin.Complete = bin.To-bin.From == n
+		} else {
+			start, end := tf.period(dates[i], n)
+			bin.Period = TimePeriod{Start: start, End: end}
+			bin.To = i + 1
+			for bin.To < len(dates) && dates[bin.To].Before(end) {
+				bin.To++
+			}
+			bin.Complete = bin.To < len(dates)
+		}
+		bins = append(bins, bin)
+		i = bin.To
+	}
+	return bins
+}
+
+// period returns the start and end of the n weeks or months date falls in
+func (tf Timeframe) period(date time.Time, n int) (time.Time, time.Time) {
+	day := startOfDay(date)
+	if tf.Unit == Months {
+		index := (day.Year()*12 + int(day.Month()) - 1) / n * n
+		start := time.Date(index/12, time.Month(index%12+1), 1, 0, 0, 0, 0, day.Location())
+		return start, start.AddDate(0, n, 0)
+	}
+	anchor := time.Date(weekAnchor.Year(), weekAnchor.Month(), weekAnchor.Day(), 0, 0, 0, 0, day.Location())
+	days := int(math.Round(day.Sub(anchor).Hours() / 24))
+	if days < 0 {
+		days -= 7*n - 1
+	}
+	start := anchor.AddDate(0, 0, days/(7*n)*7*n)
+	return start, start.AddDate(0, 0, 7*n)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Resample returns the candles of ts, daily candles in ascending order, merged into the candles of tf.
+// A candle opens at the open of its first session and closes at the close of its last one,
+// its high and low are the extremes of the sessions, volume, turnover and trade count their sums
+// and its VWAP the average of the session VWAPs weighted by volume. Confirm is 1 for a complete bin, see Bin.
+func Resample(ts *TimeSeries, tf Timeframe) *TimeSeries {
+	dates := make([]time.Time, len(ts.Candles))
+	for i, candle := range ts.Candles {
+		dates[i] = candle.Period.Start
+	}
+	resampled := NewTimeSeries()
+	for _, bin := range tf.Bins(dates) {
+		resampled.AddCandle(mergeCandles(bin, ts.Candles[bin.From:bin.To]))
+	}
+	return resampled
+}
+
+// mergeCandles merges the candles of bin into one
+func mergeCandles(bin Bin, candles []*Candle) *Candle {
+	first, last := candles[0], candles[len(candles)-1]
+	merged := NewCandle(bin.Period)
+	merged.OpenPrice = first.OpenPrice
+	merged.ClosePrice = last.ClosePrice
+	merged.MaxPrice = first.MaxPrice
+	merged.MinPrice = first.MinPrice
+	merged.CTime = last.CTime
+	if bin.Complete {
+		merged.Confirm = 1
+	}
+	var volume, weighted, turnover float64
+	for _, c := range candles {
+		if c.MaxPrice.GT(merged.MaxPrice) {
+			merged.MaxPrice = c.MaxPrice
+		}
+		if c.MinPrice.LT(merged.MinPrice) {
+			merged.MinPrice = c.MinPrice
+		}
+		merged.TradeCount += c.TradeCount
+		v := decimalFloat(c.Volume)
+		volume += v
+		weighted += v * decimalFloat(c.VWAP)
+		turnover += decimalFloat(c.Turnover)
+	}
+	merged.Volume = big.NewDecimal(volume)
+	merged.Turnover = big.NewDecimal(turnover)
+	if volume > 0 {
+		merged.VWAP = big.NewDecimal(weighted / volume)
+	} else {
+		merged.VWAP = last.VWAP
+	}
+	return merged
+}
+
+// decimalFloat returns d as a float64, zero when d is not set
+func decimalFloat(d big.Decimal) float64 {
+	if d.NaN() {
+		return 0
+	}
+	return d.Float()
+}
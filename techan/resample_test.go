@@ -0,0 +1,109 @@
+package techan_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/oarkflow/nepse/big"
+	"github.com/oarkflow/nepse/techan"
+)
+
+func TestParseTimeframe(t *testing.T) {
+	assert := assert.New(t)
+	for s, want := range map[string]techan.Timeframe{
+		"":       techan.Daily,
+		"1d":     techan.Daily,
+		"w":      techan.Weekly,
+		"weekly": techan.Weekly,
+		"M":      techan.Monthly,
+		"5d":     {Unit: techan.Sessions, N: 5},
+		"2w":     {Unit: techan.Weeks, N: 2},
+	} {
+		tf, err := techan.ParseTimeframe(s)
+		assert.Nil(err, s)
+		assert.Equal(want, tf, s)
+	}
+	for _, s := range []string{"h", "0w", "-1d", "xw"} {
+		_, err := techan.ParseTimeframe(s)
+		assert.NotNil(err, s)
+	}
+	assert.Equal("2w", techan.Timeframe{Unit: techan.Weeks, N: 2}.String())
+	assert.True(techan.Daily.IsDaily())
+	assert.False(techan.Weekly.IsDaily())
+}
+
+func TestResample(t *testing.T) {
+	assert := assert.New(t)
+	day := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+	ts := techan.NewTimeSeries()
+	// a Thursday, a Sunday to Thursday week, a week without sessions, a Monday and the next month
+	for i, d := range []string{"2024-08-01", "2024-08-04", "2024-08-05", "2024-08-08", "2024-08-19", "2024-09-01"} {
+		price := 100 + float64(i)
+		candle := techan.NewCandle(techan.NewTimePeriod(day(d), 24*time.Hour))
+		candle.OpenPrice = big.NewDecimal(price)
+		candle.MaxPrice = big.NewDecimal(price + 5)
+		candle.MinPrice = big.NewDecimal(price - 5)
+		candle.ClosePrice = big.NewDecimal(price + 1)
+		candle.Volume = big.NewDecimal(float64(10 * (i + 1)))
+		candle.VWAP = big.NewDecimal(price)
+		candle.Turnover = big.NewDecimal(price * float64(10*(i+1)))
+		candle.TradeCount = 2
+		ts.AddCandle(candle)
+	}
+
+	weekly := techan.Resample(ts, techan.Weekly)
+	assert.Len(weekly.Candles, 4)
+	assert.Equal(day("2024-07-28"), weekly.Candles[0].Period.Start)
+	week := weekly.Candles[1]
+	assert.Equal(day("2024-08-04"), week.Period.Start)
+	assert.Equal(day("2024-08-11"), week.Period.End)
+	assert.InDelta(101, week.OpenPrice.Float(), 1e-9)
+	assert.InDelta(104, week.ClosePrice.Float(), 1e-9)
+	assert.InDelta(108, week.MaxPrice.Float(), 1e-9)
+	assert.InDelta(96, week.MinPrice.Float(), 1e-9)
+	assert.InDelta(90, week.Volume.Float(), 1e-9)
+	assert.Equal(uint(6), week.TradeCount)
+	// (101*20 + 102*30 + 103*40) / 90
+	assert.InDelta(9200.0/90, week.VWAP.Float(), 1e-9)
+	assert.InDelta(9200, week.Turnover.Float(), 1e-9)
+	assert.Equal(1, week.Confirm)
+	assert.Equal(day("2024-08-18"), weekly.Candles[2].Period.Start)
+	// the last week may still get sessions
+	assert.Equal(0, weekly.LastCandle().Confirm)
+
+	monthly := techan.Resample(ts, techan.Monthly)
+	assert.Len(monthly.Candles, 2)
+	assert.Equal(day("2024-08-01"), monthly.Candles[0].Period.Start)
+	assert.Equal(day("2024-09-01"), monthly.Candles[0].Period.End)
+	assert.InDelta(105, monthly.Candles[0].ClosePrice.Float(), 1e-9)
+
+	sessions := techan.Resample(ts, techan.Timeframe{Unit: techan.Sessions, N: 4})
+	assert.Len(sessions.Candles, 2)
+	assert.Equal(day("2024-08-01"), sessions.Candles[0].Period.Start)
+	assert.Equal(day("2024-08-09"), sessions.Candles[0].Period.End)
+	assert.Equal(1, sessions.Candles[0].Confirm)
+	assert.Equal(0, sessions.Candles[1].Confirm)
+
+	assert.Len(techan.Resample(ts, techan.Daily).Candles, 6)
+	assert.Empty(techan.Resample(techan.NewTimeSeries(), techan.Weekly).Candles)
+
+	// two week bins are aligned to the same Sundays whatever the first session
+	bins := techan.Timeframe{Unit: techan.Weeks, N: 2}.Bins([]time.Time{day("2024-08-05"), day("2024-08-12")})
+	assert.Len(bins, 1)
+	assert.Equal(day("2024-08-04"), bins[0].Period.Start)
+}
+
+func TestMergeCandleOutOfWindow(t *testing.T) {
+	assert := assert.New(t)
+	candle := techan.NewCandle(techan.NewTimePeriod(time.Date(2024, 8, 10, 0, 0, 0, 0, time.UTC), 24*time.Hour))
+	merge := techan.MergeCandle(time.Date(2024, 8, 4, 0, 0, 0, 0, time.UTC), 7*24*time.Hour)
+	assert.NotPanics(func() {
+		merged := merge(candle)
+		assert.Equal(time.Date(2024, 8, 4, 0, 0, 0, 0, time.UTC), merged.Period.Start)
+	})
+}
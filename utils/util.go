@@ -3,7 +3,6 @@ package utils
 import (
 	"bytes"
 	"encoding/base64"
-	"fmt"
 	"io"
 	"log"
 	"os"
@@ -36,32 +35,6 @@ func FromCompressedString(data string) ([]byte, error) {
 	return rawBytes, nil
 }
 
-// GenerateGoFile generates a Go file with the CSV data.
-func GenerateGoFile(filename, packageName, varName string, dataMap map[string][]map[string]any) error {
-	var buf bytes.Buffer
-	buf.WriteString(fmt.Sprintf("package %s\n\n", packageName))
-	buf.WriteString(fmt.Sprintf("var %s = map[string][]map[string]any{\n", varName))
-	for date, dataList := range dataMap {
-		buf.WriteString(fmt.Sprintf("    \"%s\": {\n", date))
-		for _, entry := range dataList {
-			buf.WriteString("        {\n")
-			for key, value := range entry {
-				if strValue, ok := value.(string); ok {
-					compressedValue := ToCompressedString([]byte(strValue))
-					buf.WriteString(fmt.Sprintf("            \"%s\": \"%s\",\n", key, compressedValue))
-				} else {
-					buf.WriteString(fmt.Sprintf("            \"%s\": %v,\n", key, value))
-				}
-			}
-			buf.WriteString("        },\n")
-		}
-		buf.WriteString("    },\n")
-	}
-	buf.WriteString("}\n")
-
-	return os.WriteFile(filename, buf.Bytes(), 0644)
-}
-
 func GenerateBinaryContent(packageName, varName string, data []byte, fileName ...string) []byte {
 	encoded := ToCompressedString(data)
 	output := &bytes.Buffer{}